@@ -1,30 +1,114 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"warreth.dev/immich-sync/pkg/app"
 	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
 )
 
+// exitFormatChanged is the -once exit status when Google's page format broke the scraper,
+// so schedulers can tell it apart from network or Immich failures (status 1)
+const exitFormatChanged = 3
+
 func main() {
+	selfTest := flag.Bool("selftest", false, "Upload, confirm and delete a tiny test asset to verify Immich access, then exit")
+	scrapeBench := flag.Int("scrapebench", 0, "Scrape each configured album N times without uploading and report variance, then exit")
+	exportDir := flag.String("export", "", "Export every configured album as media files plus metadata.json into this directory, then exit")
+	diff := flag.Bool("diff", false, "Compare each configured album with its Immich album without changing anything, then exit")
+	verify := flag.Bool("verify", false, "Check that every item of each configured album is in Immich without changing anything, then exit")
+	status := flag.Bool("status", false, "Show each configured album's Immich album, asset count and last sync without syncing, then exit")
+	once := flag.Bool("once", false, "Sync every configured album once, ignoring sync intervals, then exit")
+	flag.Parse()
+
 	fmt.Println(">> Immich Sync Tool <<")
 
 	cfg, err := config.ReadConfig("config.json")
 	if err != nil {
 		fmt.Printf("Warning: %v\n", err)
-		if os.Getenv("IMMICH_API_KEY") == "" {
-			fmt.Println("Please provide config.json or environment variables.")
+		if cfg == nil {
+			fmt.Println("Please provide a valid config.json or environment variables.")
 			os.Exit(1)
 		}
 	}
 
-	application, err := app.New(cfg)
+	newApp := app.New
+	if *scrapeBench > 0 || *exportDir != "" {
+		newApp = app.NewOffline // Google only, Immich may not be configured
+	}
+	application, err := newApp(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing app: %v\n", err)
 		os.Exit(1)
 	}
 
-	application.Run()
+	if *selfTest {
+		if err := application.SelfTest(); err != nil {
+			fmt.Fprintf(os.Stderr, "Self-test failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *scrapeBench > 0 {
+		application.ScrapeBench(*scrapeBench)
+		return
+	}
+
+	if *exportDir != "" {
+		if err := application.Export(*exportDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *diff {
+		if err := application.Diff(); err != nil {
+			fmt.Fprintf(os.Stderr, "Diff failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *status {
+		if err := application.Status(); err != nil {
+			fmt.Fprintf(os.Stderr, "Status failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *verify {
+		if err := application.Verify(); err != nil {
+			fmt.Fprintf(os.Stderr, "Verify failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Stop gracefully on Ctrl+C / docker stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *once || cfg.RunOnce {
+		if err := application.RunOnce(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Sync failed: %v\n", err)
+			stop()
+			if errors.Is(err, googlephotos.ErrFormatChanged) {
+				os.Exit(exitFormatChanged)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	application.RunContext(ctx)
 }
@@ -1,9 +1,19 @@
 package app
 
 import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -11,7 +21,20 @@ import (
 	"warreth.dev/immich-sync/pkg/config"
 	"warreth.dev/immich-sync/pkg/googlephotos"
 	"warreth.dev/immich-sync/pkg/immich"
+	"warreth.dev/immich-sync/pkg/metrics"
+	"warreth.dev/immich-sync/pkg/netlimit"
 	"warreth.dev/immich-sync/pkg/progress"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+const (
+	defaultEmptyScrapeRetries    = 2
+	defaultEmptyScrapeRetryDelay = 30 * time.Second
+	defaultFailedRetryDelay      = 10 * time.Second
+	defaultPollInterval          = 1 * time.Minute
+	defaultMinPollInterval       = 1 * time.Second
+	defaultConnectBackoff        = 5 * time.Second
+	maxConnectBackoff            = 5 * time.Minute
 )
 
 type App struct {
@@ -19,13 +42,36 @@ type App struct {
 	Client   *immich.Client
 	GPClient *googlephotos.Client
 	Logger   *slog.Logger
+	State    *state.Store
+	Metrics  metrics.Recorder // nil when no metrics backend is configured
+
+	webhooks  sync.WaitGroup // Webhook POSTs in flight, waited for before returning from a run
+	checksums checksumCache  // Assets found or uploaded by checksum (ChecksumDedup)
+	health    *health        // State reported on /healthz
+
+	connectBackoff time.Duration // First wait between Immich connection attempts at startup, 0 means 5s
 }
 
 func New(cfg *config.Config) (*App, error) {
+	return newApp(cfg, cfg.Validate)
+}
+
+// NewOffline is New for the modes that only read from Google (-export,
+// -scrapebench), which run without the Immich settings
+func NewOffline(cfg *config.Config) (*App, error) {
+	return newApp(cfg, cfg.ValidateOffline)
+}
+
+// newLogger builds the main logger writing to w in the configured LogFormat
+func newLogger(w io.Writer, cfg *config.Config) *slog.Logger {
 	level := slog.LevelInfo
 	if cfg.Debug {
 		level = slog.LevelDebug
 	}
+	if cfg.LogFormat == "json" {
+		// Log shippers filter by level and need full timestamps, so keep both
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+	}
 	opts := &slog.HandlerOptions{
 		Level: level,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
@@ -39,152 +85,594 @@ func New(cfg *config.Config) (*App, error) {
 			return a
 		},
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, opts))
-	client := immich.NewClient(cfg.ApiURL, cfg.ApiKey)
-	gpClient := googlephotos.NewClient(logger)
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func newApp(cfg *config.Config, validate func() error) (*App, error) {
+	if err := validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	logger := newLogger(os.Stdout, cfg)
+	var scrapeCacheTTL time.Duration
+	if cfg.ScrapeCacheTTL != "" {
+		d, err := time.ParseDuration(cfg.ScrapeCacheTTL)
+		if err != nil {
+			logger.Warn("Invalid scrapeCacheTTL, scrape cache disabled", "value", cfg.ScrapeCacheTTL, "error", err)
+		} else {
+			scrapeCacheTTL = d
+		}
+	}
+	client := immich.NewClient(immich.JoinURL(cfg.ApiURL, cfg.ApiBasePath), cfg.ApiKey)
+	client.AlbumAddBatchSize = cfg.AlbumAddBatchSize
+	var gpTransport http.RoundTripper
+	if cfg.ProxyURL != "" {
+		proxy, _ := url.Parse(cfg.ProxyURL) // Checked by Validate
+		gpTransport = googlephotos.ProxyTransport(proxy)
+		logger.Info("Using proxy for Google Photos", "scheme", proxy.Scheme, "host", proxy.Host)
+	}
+	if cfg.MaxConnections > 0 {
+		// Each item worker holds a download and an upload at once, so the cap must allow two
+		if cfg.MaxConnections < 2 {
+			logger.Warn("maxConnections must be at least 2, using 2", "value", cfg.MaxConnections)
+			cfg.MaxConnections = 2
+		}
+		limiter := netlimit.New(cfg.MaxConnections)
+		client.Client.Transport = limiter.Wrap(client.Client.Transport)
+		gpTransport = limiter.Wrap(gpTransport)
+	}
+	gpClient := googlephotos.NewClient(logger, googlephotos.Options{
+		DownloadAccept:      cfg.DownloadAccept,
+		MaxVideoBytes:       cfg.MaxVideoBytes,
+		MaxRedirects:        cfg.MaxRedirects,
+		StopOnSignIn:        cfg.StopOnSignInRedirect,
+		MaxConcurrentProbes: cfg.MaxConcurrentProbes,
+		CacheDir:            cfg.ScrapeCacheDir,
+		CacheTTL:            scrapeCacheTTL,
+		Transport:           gpTransport,
+		VerifyMediaType:     cfg.VerifyMediaType,
+		Cookies:             cfg.GoogleCookies,
+		CookieFile:          cfg.GoogleCookieFile,
+		DownloadRetries:     cfg.DownloadRetries,
+		DownloadBackoff:     optionalDuration(logger, "downloadRetryDelay", cfg.DownloadRetryDelay),
+		SpoolThreshold:      cfg.SpoolThresholdBytes,
+		DownloadResumes:     cfg.DownloadResumes,
+		ImageSuffix:         cfg.ImageSuffix(),
+		SpoolDir:            cfg.SpoolDir,
+		MaxRetries:          cfg.GoogleMaxRetries,
+		BaseBackoff:         optionalDuration(logger, "googleRetryBackoff", cfg.GoogleRetryBackoff),
+		MinDelay:            pauseDuration(logger, "googleRequestDelay", cfg.GoogleRequestDelay),
+		Jitter:              pauseDuration(logger, "googleRequestJitter", cfg.GoogleRequestJitter),
+		RequestsPerSecond:   cfg.GoogleRequestsPerSecond,
+	})
+	for _, err := range cfg.CheckSyncIntervals() {
+		logger.Warn("Invalid sync interval, falling back to 24h", "error", err)
+	}
+	store, err := state.Load(cfg.StateFile)
+	if err != nil {
+		logger.Warn("Could not load sync state, starting fresh", "path", cfg.StateFile, "error", err)
+	}
 	return &App{
 		Cfg:      cfg,
 		Client:   client,
 		GPClient: gpClient,
 		Logger:   logger,
+		State:    store,
+		Metrics:  newMetrics(cfg, logger),
+		health:   newHealth(cfg, optionalDuration(logger, "healthStaleAfter", cfg.HealthStaleAfter)),
 	}, nil
 }
 
 func (a *App) Run() {
+	a.RunContext(context.Background())
+}
+
+// RunContext runs the sync loop until ctx is cancelled. On cancellation no new
+// albums or items are started; items in flight finish, albums in progress add
+// what was uploaded and save their state, then RunContext returns.
+func (a *App) RunContext(ctx context.Context) {
 	a.Logger.Info("Starting Immich Sync")
+	a.startServers()
 
-	id, name, err := a.Client.GetUser()
-	if err != nil {
-		a.Logger.Error("Failed to connect to Immich", "error", err)
+	if !a.connectImmich(ctx) {
+		a.Logger.Info("Stopping Immich Sync")
+		return
+	}
+	if err := a.checkLibraries(); err != nil {
+		a.Logger.Error("Invalid Immich library configuration", "error", err)
 		os.Exit(1)
 	}
-	a.Logger.Info("Connected to Immich", "user_id", id, "name", name)
 
 	if len(a.Cfg.GooglePhotos) == 0 {
 		a.Logger.Warn("No albums configured")
 		return
 	}
 
-	// Initialize schedule
+	// Initialize schedule, resuming from the last sync recorded in the state file
 	nextRun := make(map[string]time.Time)
 	for _, ac := range a.Cfg.GooglePhotos {
 		nextRun[ac.URL] = time.Now()
+		if last := a.State.Album(ac.URL).LastSync; !last.IsZero() {
+			if next := last.Add(syncInterval(ac)); next.After(time.Now()) {
+				nextRun[ac.URL] = next
+				a.Logger.Info("Resuming schedule from state file", "album", ac.URL, "next_run", next.Format("15:04:05"))
+			}
+		}
 	}
 
-	albumWorkers := a.Cfg.AlbumWorkers
-	if albumWorkers < 1 {
-		albumWorkers = 1
+	albumWorkers := a.albumWorkers()
+
+	var cycleCooldown time.Duration
+	if a.Cfg.CycleCooldown != "" {
+		var err error
+		cycleCooldown, err = time.ParseDuration(a.Cfg.CycleCooldown)
+		if err != nil {
+			a.Logger.Warn("Invalid cycleCooldown, no cooldown applied", "value", a.Cfg.CycleCooldown, "error", err)
+			cycleCooldown = 0
+		}
+	}
+	// Albums synced since the last cooldown; a cycle is complete once every album is in here
+	cycleDone := make(map[string]bool)
+
+	pollInterval := defaultPollInterval
+	if a.Cfg.PollInterval != "" {
+		d, err := time.ParseDuration(a.Cfg.PollInterval)
+		if err != nil || d <= 0 {
+			a.Logger.Warn("Invalid pollInterval, using default", "value", a.Cfg.PollInterval, "default", pollInterval)
+		} else {
+			pollInterval = d
+		}
+	}
+	minPollInterval := min(defaultMinPollInterval, pollInterval)
+	if d := optionalDuration(a.Logger, "minPollInterval", a.Cfg.MinPollInterval); d > 0 {
+		minPollInterval = min(d, pollInterval)
+	}
+
+	// Albums are dispatched as soon as they are due and rescheduled as soon as
+	// they finish, so a long album doesn't hold back the others' schedules
+	sem := make(chan struct{}, albumWorkers)
+	finished := make(chan string, len(a.Cfg.GooglePhotos))
+	running := make(map[string]bool)
+	var wg sync.WaitGroup
+	reschedule := func(url string) {
+		delete(running, url)
+		nextRun[url] = time.Now().Add(syncInterval(a.albumConfig(url)))
+		a.Logger.Info("Scheduled next sync", "album", url, "next_run", nextRun[url].Format("15:04:05"))
+		cycleDone[url] = true
 	}
 
 	for {
+		// Reschedule albums that finished since the last pass
+		for drained := false; !drained; {
+			select {
+			case url := <-finished:
+				reschedule(url)
+			default:
+				drained = true
+			}
+		}
+
+		if cycleCooldown > 0 && len(running) == 0 && len(cycleDone) >= len(nextRun) {
+			a.Logger.Info("Completed a full cycle, cooling down", "cooldown", cycleCooldown)
+			if !sleepContext(ctx, cycleCooldown) {
+				break
+			}
+			cycleDone = make(map[string]bool)
+			continue
+		}
+
 		// Collect albums due for sync
 		var due []config.GooglePhotosConfig
 		for _, ac := range a.Cfg.GooglePhotos {
-			if time.Now().After(nextRun[ac.URL]) {
+			if !running[ac.URL] && time.Now().After(nextRun[ac.URL]) {
 				due = append(due, ac)
 			}
 		}
 
 		if len(due) > 0 {
-			// Fetch album list from Immich once per sync cycle
+			// Fetch album list from Immich once per dispatch
 			albumCache, err := a.Client.GetAlbums()
 			if err != nil {
 				a.Logger.Warn("Failed to fetch Immich album list", "error", err)
 			}
 
-			a.Logger.Info("Processing due albums", "count", len(due), "album_workers", albumWorkers)
+			a.Logger.Info("Processing due albums", "count", len(due), "running", len(running), "album_workers", albumWorkers)
 
 			// Process due albums concurrently with bounded concurrency
-			sem := make(chan struct{}, albumWorkers)
-			var wg sync.WaitGroup
 			for _, ac := range due {
+				running[ac.URL] = true
 				wg.Add(1)
 				go func(ac config.GooglePhotosConfig) {
 					defer wg.Done()
 					sem <- struct{}{}
 					defer func() { <-sem }()
-					a.processAlbum(ac, albumCache)
+					a.processAlbum(ctx, ac, albumCache) // Errors are logged, the album is retried next interval
+					finished <- ac.URL
 				}(ac)
 			}
-			wg.Wait()
+		}
 
-			// Schedule next runs
-			for _, ac := range due {
-				interval, err := time.ParseDuration(ac.SyncInterval)
-				if err != nil || interval == 0 {
-					interval = 24 * time.Hour
-				}
-				nextRun[ac.URL] = time.Now().Add(interval)
-				a.Logger.Info("Scheduled next sync", "album", ac.URL, "next_run", nextRun[ac.URL].Format("15:04:05"))
-			}
+		// Sleep until the next album is due, waking early when an album finishes
+		t := time.NewTimer(nextWake(nextRun, running, time.Now(), minPollInterval, pollInterval))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+		case url := <-finished:
+			t.Stop()
+			reschedule(url)
+		case <-t.C:
+		}
+		if ctx.Err() != nil {
+			break
 		}
+	}
+	wg.Wait()
+	a.webhooks.Wait()
+	a.Logger.Info("Stopping Immich Sync")
+}
 
-		time.Sleep(1 * time.Minute)
+// connectImmich checks the Immich connection, retrying with a growing backoff
+// until it succeeds. Meanwhile /healthz reports the failure. Returns false when
+// ctx is cancelled first.
+func (a *App) connectImmich(ctx context.Context) bool {
+	backoff := a.connectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+	for {
+		id, name, err := a.Client.GetUser()
+		a.health.immichChecked(err)
+		if err == nil {
+			a.Logger.Info("Connected to Immich", "user_id", id, "name", name)
+			return true
+		}
+		a.Logger.Error("Failed to connect to Immich, retrying", "error", err, "retry_in", backoff)
+		if !sleepContext(ctx, backoff) {
+			return false
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
 }
 
+// mappedAlbum returns the Immich album recorded for an album URL in the state
+// file, "" when there is none or it no longer exists. Without an album list
+// (Immich didn't answer) the recorded ID is trusted.
+func (a *App) mappedAlbum(url string, albumCache []immich.Album, logger *slog.Logger) string {
+	id := a.State.Album(url).ImmichAlbumID
+	if id == "" || albumCache == nil {
+		return id
+	}
+	for _, alb := range albumCache {
+		if alb.Id == id {
+			return id
+		}
+	}
+	logger.Warn("Immich album recorded for this album no longer exists, matching by title", "album_id", id)
+	return ""
+}
+
+// albumByTitle returns the Immich album named title, "" when there is none. Albums
+// the state file maps to another URL are passed over, so two shared albums with
+// the same title don't end up in one Immich album.
+func (a *App) albumByTitle(url, title string, albumCache []immich.Album, logger *slog.Logger) string {
+	for _, alb := range albumCache {
+		if alb.AlbumName != title {
+			continue
+		}
+		if owner := a.State.AlbumOwner(alb.Id, url); owner != "" {
+			logger.Debug("Immich album with this title belongs to another album", "album_id", alb.Id, "owner_url", owner)
+			continue
+		}
+		return alb.Id
+	}
+	return ""
+}
+
+// newAlbumProgressLog sets up progress lines for an album sync. Without the
+// progress bar (barsOff) they default to every 100 items or 30s.
+func (a *App) newAlbumProgressLog(logger *slog.Logger, total, base int, barsOff bool) *progressLog {
+	every := a.Cfg.ProgressLogItems
+	interval := pauseDuration(logger, "progressLogInterval", a.Cfg.ProgressLogInterval)
+	if barsOff {
+		if every == 0 {
+			every = defaultProgressLogItems
+		}
+		if interval == 0 {
+			interval = defaultProgressLogInterval
+		}
+	}
+	return newProgressLog(logger, total, max(every, 0), max(interval, 0), base, time.Now())
+}
+
+// nextWake returns how long the scheduler can sleep: until the earliest next run
+// of an album that isn't running, clamped to [minWait, maxWait]. With every album
+// running it's maxWait, a finishing album wakes the scheduler anyway.
+func nextWake(nextRun map[string]time.Time, running map[string]bool, now time.Time, minWait, maxWait time.Duration) time.Duration {
+	wait := maxWait
+	for url, next := range nextRun {
+		if !running[url] {
+			wait = min(wait, next.Sub(now))
+		}
+	}
+	return max(wait, minWait)
+}
+
+// albumWorkers returns how many albums may sync at once
+func (a *App) albumWorkers() int {
+	albumWorkers := a.Cfg.AlbumWorkers
+	if albumWorkers < 1 {
+		albumWorkers = 1
+	}
+	if a.Cfg.MaxConnections > 0 && albumWorkers > a.Cfg.MaxConnections/2 {
+		albumWorkers = a.Cfg.MaxConnections / 2
+		a.Logger.Info("Reduced album workers to fit maxConnections", "album_workers", albumWorkers, "max_connections", a.Cfg.MaxConnections)
+	}
+	return albumWorkers
+}
+
+// albumConfig returns the configuration of the album with the given URL
+func (a *App) albumConfig(url string) config.GooglePhotosConfig {
+	for _, ac := range a.Cfg.GooglePhotos {
+		if ac.URL == url {
+			return ac
+		}
+	}
+	return config.GooglePhotosConfig{URL: url}
+}
+
+// maxItemWorkers returns how many item workers each album may run so that all
+// concurrent albums together never need more than MaxConnections. 0 means no limit.
+func (a *App) maxItemWorkers() int {
+	if a.Cfg.MaxConnections <= 0 {
+		return 0
+	}
+	albumWorkers := min(max(a.Cfg.AlbumWorkers, 1), a.Cfg.MaxConnections/2)
+	return max(a.Cfg.MaxConnections/(2*albumWorkers), 1)
+}
+
+// syncInterval returns the album's sync interval, 24h when unset, invalid or not positive
+func syncInterval(ac config.GooglePhotosConfig) time.Duration {
+	interval, err := time.ParseDuration(ac.SyncInterval)
+	if err != nil || interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	return interval
+}
+
+// optionalDuration parses an optional duration setting, 0 (the default) when unset or invalid
+func optionalDuration(logger *slog.Logger, name, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warn("Invalid "+name+", using default", "value", value, "error", err)
+		return 0
+	}
+	return d
+}
+
+// pauseDuration is optionalDuration for pauses that can be switched off: an explicit
+// zero ("0", "0s") becomes -1, which the clients read as disabled rather than default
+func pauseDuration(logger *slog.Logger, name, value string) time.Duration {
+	d := optionalDuration(logger, name, value)
+	if d == 0 && value != "" {
+		if _, err := time.ParseDuration(value); err == nil {
+			return -1
+		}
+	}
+	return d
+}
+
+// sleepContext waits for d or until ctx is cancelled. Returns false on cancellation.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// albumSync carries the per-album data shared by the processItem workers.
+// The maps are built before the workers start and are only read afterwards.
+type albumSync struct {
+	Title         string
+	URL           string
+	Key           string            // Scopes stable IDs, album media key or configured URL
+	ExistingFiles map[string]string // baseName / stable ID -> asset ID for assets already in the album
+	GlobalAssets  map[string]string // baseName / stable ID -> asset ID for all assets uploaded by this tool
+	AssetPixels   map[string]int    // asset ID -> width*height of album assets (ReplaceOnHigherRes)
+	Logger        *slog.Logger      // Album-scoped logger, also writes to the per-run log file when enabled
+	DateOffset    time.Duration     // Added to every known TakenAt before upload
+	LibraryID     string            // Immich library uploads go to, empty for the default
+
+	// URL suffixes tried in order when the original download fails
+	QualityFallback []string
+
+	// The album's assets were listed, so ExistingFiles is complete and an item
+	// missing from it was removed in Immich
+	AlbumListed bool
+
+	// Fresh media URLs from a re-scrape, fetched at most once per run (RefreshExpiredURLs)
+	refreshOnce sync.Once
+	freshURLs   map[string]string
+}
+
 type processResult struct {
+	PhotoID         string
 	ID              string
 	WasUploaded     bool
 	Error           error
+	Category        string // Summary category of Error (or of a strictMetadata or size skip), "" on success
 	BytesDownloaded int64
 	BytesUploaded   int64
 }
 
-func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Album) {
-	logger := a.Logger.With("album_url", ac.URL)
+// processAlbum syncs one album. The returned error only reports that the album
+// couldn't be scraped; per-item failures are logged and counted instead.
+func (a *App) processAlbum(ctx context.Context, ac config.GooglePhotosConfig, albumCache []immich.Album) error {
+	logger, closeLog := a.albumLogger(ac)
+	defer closeLog()
+	if ctx.Err() != nil {
+		logger.Info("Shutting down, album not synced")
+		return nil
+	}
 	logger.Info("Syncing Google Photos Album")
 
-	album, err := googlephotos.ScrapeAlbum(a.GPClient, ac.URL)
+	started := time.Now()
+	summary := syncSummary{Album: albumLogName(ac), URL: ac.URL}
+	if !a.Cfg.DryRun {
+		defer func() { a.notifyWebhook(summary, started, logger) }()
+		// Whichever way the sync ends, keep what it recorded
+		defer func() {
+			if err := a.State.Save(); err != nil {
+				logger.Warn("Failed to save sync state", "error", err)
+			}
+		}()
+	}
+
+	// The album deadline only stops new items from starting. Items already being
+	// downloaded or uploaded run to completion, bounded by the HTTP client timeouts,
+	// and everything after the item loop (album adds, state) still happens.
+	syncCtx := ctx
+	if ac.MaxSyncDuration != "" {
+		d, err := time.ParseDuration(ac.MaxSyncDuration)
+		if err != nil || d <= 0 {
+			logger.Warn("Invalid maxSyncDuration, no album deadline", "value", ac.MaxSyncDuration, "error", err)
+		} else {
+			var cancel context.CancelFunc
+			syncCtx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+	}
+
+	if a.Cfg.QuickCheck && a.albumUnchanged(ac, logger) {
+		a.health.syncSucceeded(time.Now())
+		return nil
+	}
+
+	album, err := a.scrapeAlbum(syncCtx, ac.URL, logger)
 	if err != nil {
-		logger.Error("Error scraping album", "error", err)
-		return
+		if syncCtx.Err() != nil {
+			logger.Info("Sync interrupted while waiting to re-scrape, album not synced")
+			summary.Error = err.Error()
+			return nil
+		}
+		if errors.Is(err, googlephotos.ErrFormatChanged) {
+			logger.Error("Google Photos page format may have changed, the scraper likely needs an update (enable debug for a dump of the page data)", "error", err)
+		} else if errors.Is(err, googlephotos.ErrAlbumUnavailable) {
+			logger.Error("Google Photos album unavailable, check the share link still works in a browser", "error", err)
+		} else {
+			logger.Error("Error scraping album", "error", err)
+		}
+		summary.Error = err.Error()
+		if a.Metrics != nil {
+			if err := a.Metrics.RecordScrapeError(albumLogName(ac)); err != nil {
+				logger.Warn("Failed to push metrics", "error", err)
+			}
+		}
+		return err
+	}
+	album.Photos = uniqueItems(album.Photos, logger)
+	scrapedCount := len(album.Photos)
+	lastItemID := ""
+	if scrapedCount > 0 {
+		lastItemID = album.Photos[scrapedCount-1].ID
+	}
+	if album.Incomplete {
+		logger.Warn("Album only partly scraped, syncing the items found but keeping the state of the rest", "items", scrapedCount)
+	}
+	if a.Cfg.QuickCheck && !album.Incomplete && a.scrapeUnchanged(ac, scrapedCount, lastItemID, logger) {
+		a.health.syncSucceeded(time.Now())
+		return nil
 	}
 
 	albumTitle := album.Title
+	if ac.RawTitle && album.RawTitle != "" {
+		albumTitle = album.RawTitle
+	}
 	if ac.AlbumName != "" {
 		albumTitle = ac.AlbumName
 	}
+	summary.Album = albumTitle
 	logger.Info("Found photos in album", "count", len(album.Photos), "title", albumTitle)
 
+	// Filtered and capped items are still part of the album, the state keeps what it knows about them
+	inAlbum := make(map[string]bool, len(album.Photos))
+	for _, p := range album.Photos {
+		inAlbum[p.ID] = true
+	}
+
+	album.Photos = selectItems(album.Photos, ac, logger)
 	if len(album.Photos) == 0 {
 		logger.Info("No photos found, skipping")
-		return
+		if album.Incomplete {
+			return nil
+		}
+		a.health.syncSucceeded(time.Now())
+		// Still a clean sync, the quick checks can skip the album next run
+		a.State.PruneProcessed(ac.URL, inAlbum)
+		a.recordCleanSync(ac, scrapedCount, lastItemID, 0)
+		return nil
 	}
 
 	// Resolve Immich album ID
 	var albumId string
 	if ac.ImmichAlbumID != "" {
 		albumId = ac.ImmichAlbumID
-	} else {
-		for _, a := range albumCache {
-			if a.AlbumName == albumTitle {
-				albumId = a.Id
-				break
+		if ac.NoCreate {
+			if _, err := a.Client.GetAlbum(albumId); err != nil {
+				logger.Error("Immich album not found and noCreate is set, skipping album", "album_id", albumId, "error", err)
+				return nil
 			}
 		}
+	} else {
+		albumId = a.mappedAlbum(ac.URL, albumCache, logger)
 		if albumId == "" {
+			albumId = a.albumByTitle(ac.URL, albumTitle, albumCache, logger)
+		}
+		if albumId == "" && ac.NoCreate {
+			logger.Error("No Immich album with this name and noCreate is set, skipping album", "title", albumTitle)
+			return nil
+		}
+		if albumId == "" && a.Cfg.DryRun {
+			logger.Info("Dry run: would create Immich album", "title", albumTitle)
+		} else if albumId == "" {
 			logger.Info("Creating Immich album", "title", albumTitle)
-			newAlbum, err := a.Client.CreateAlbum(albumTitle)
+			description := ac.AlbumDescription
+			if description == "" {
+				description = "Synced from " + ac.URL
+			}
+			newAlbum, err := a.Client.CreateAlbum(albumTitle, description)
 			if err == nil {
 				albumId = newAlbum.Id
 			} else {
 				logger.Error("Error creating album", "error", err)
 			}
 		}
+		if albumId != "" && !a.Cfg.DryRun {
+			a.State.UpdateAlbum(ac.URL, func(st *state.AlbumState) { st.ImmichAlbumID = albumId })
+		}
 	}
 
 	// Pre-fetch existing album assets for O(1) duplicate detection
 	existingFiles := make(map[string]string) // baseName (no extension) -> asset ID
+	albumListed := false
+	currentCover := "" // Album thumbnail before this sync
+	assetPixels := make(map[string]int)
 	if albumId != "" {
 		albumDetails, err := a.Client.GetAlbum(albumId)
+		albumListed = err == nil
 		if err == nil {
+			currentCover = albumDetails.AlbumThumbnailAssetId
+			if len(ac.ShareWithUsers) > 0 {
+				a.shareAlbum(albumDetails, ac, logger)
+			}
+			existingFiles = a.indexAlbumAssets(albumDetails, album.Photos)
 			for _, asset := range albumDetails.Assets {
-				name := asset.OriginalFileName
-				if dot := strings.LastIndex(name, "."); dot != -1 {
-					name = name[:dot]
-				}
-				existingFiles[name] = asset.Id
+				assetPixels[asset.Id] = asset.ExifInfo.ExifImageWidth * asset.ExifInfo.ExifImageHeight
 			}
 			logger.Debug("Pre-fetched album assets", "count", len(existingFiles))
 		}
@@ -200,56 +688,141 @@ func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Alb
 		logger.Debug("Pre-fetched global assets from Immich", "count", len(globalAssets))
 	}
 
+	// Stable IDs are scoped by album media key, falling back to the configured URL
+	albumKey := album.MediaKey
+	if albumKey == "" {
+		albumKey = ac.URL
+	}
+	job := &albumSync{
+		Title:         albumTitle,
+		URL:           ac.URL,
+		Key:           albumKey,
+		ExistingFiles: existingFiles,
+		GlobalAssets:  globalAssets,
+		AssetPixels:   assetPixels,
+		Logger:        logger,
+		AlbumListed:   albumListed,
+		LibraryID:     a.libraryID(ac),
+	}
+	for _, q := range ac.QualityFallback {
+		if q = strings.TrimSpace(q); q != "" && q != "=d" && q != "d" {
+			job.QualityFallback = append(job.QualityFallback, "="+strings.TrimPrefix(q, "="))
+		}
+	}
+	if ac.DateOffset != "" {
+		offset, err := time.ParseDuration(ac.DateOffset)
+		if err != nil {
+			logger.Warn("Invalid dateOffset, dates left unchanged", "value", ac.DateOffset, "error", err)
+		} else {
+			job.DateOffset = offset
+		}
+	}
+
+	// Queue the album cover first so it's available as soon as possible
+	coverID := ""
+	if ac.ImportCover {
+		coverID = moveCoverFirst(album)
+		if coverID == "" {
+			logger.Info("Album cover could not be identified, keeping Immich's default cover")
+		} else {
+			logger.Info("Importing album cover first", "id", coverID)
+		}
+	}
+	coverAssetId := ""
+
 	var newAssetIds []string
+	addedToAlbum := 0        // Confirmed by Immich, excludes duplicates and rejected IDs
+	var uploadedIds []string // Freshly uploaded assets (LockedFolder, PairLivePhotos, PersonID)
+	assetByPhoto := make(map[string]string)
 
 	total := len(album.Photos)
 	processed := 0
 	added := 0
 	skipped := 0
 	failed := 0
+	restricted := 0
+	categories := make(map[string]int) // Failed and metadata-skipped items by category
+	var bytesDownloaded, bytesUploaded int64
+
+	// Items already in the Immich album are settled without a worker, so repeat
+	// runs of a synced album cost no Google requests and no worker ramp-up
+	var pending, present []googlephotos.Photo
+	for _, p := range album.Photos {
+		if a.presentInAlbum(p, job) {
+			present = append(present, p)
+		} else {
+			pending = append(pending, p)
+		}
+	}
 
 	numWorkers := a.Cfg.Workers
 	if numWorkers < 1 {
 		numWorkers = 1
 	}
-	if numWorkers > total {
-		numWorkers = total
+	if numWorkers > len(pending) {
+		numWorkers = len(pending)
+	}
+	// Workers hold a download and an upload connection at once; more workers than
+	// the connection cap allows could wait on each other forever
+	if limit := a.maxItemWorkers(); limit > 0 && numWorkers > limit {
+		logger.Debug("Reduced workers to fit maxConnections", "workers", limit, "configured", numWorkers)
+		numWorkers = limit
 	}
 
-	logger.Info("Processing items", "total_items", total, "workers", numWorkers)
+	logger.Info("Processing items", "total_items", total, "already_in_album", len(present), "workers", numWorkers)
 
 	// Create and start progress tracker
-	tracker := progress.New(albumTitle, total, a.Cfg.Debug)
+	// Progress bars are plain text, JSON logs get the summary as a log line instead
+	jsonLogs := a.Cfg.LogFormat == "json"
+	tracker := progress.New(albumTitle, total, a.Cfg.Debug || jsonLogs)
 	tracker.Start()
 
-	jobs := make(chan googlephotos.Photo, numWorkers*2)
-	results := make(chan processResult, numWorkers*2)
-	var wg sync.WaitGroup
+	// Stagger worker start-up over the ramp window so they don't hit Google in one burst
+	rampUp := optionalDuration(logger, "workerRampUp", a.Cfg.WorkerRampUp)
 
-	for w := 0; w < numWorkers; w++ {
-		wg.Add(1)
+	// runItems processes items on up to numWorkers workers and streams their results
+	runItems := func(items []googlephotos.Photo) <-chan processResult {
+		workers := min(numWorkers, len(items))
+		jobs := make(chan googlephotos.Photo, workers*2)
+		results := make(chan processResult, workers*2)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				if rampUp > 0 && w > 0 {
+					sleepContext(syncCtx, rampUp*time.Duration(w)/time.Duration(workers))
+				}
+				for p := range jobs {
+					if syncCtx.Err() != nil {
+						continue // Shutting down or past the deadline: drain queued items without starting them
+					}
+					id, uploaded, bytesDown, bytesUp, err := a.processItem(p, job)
+					results <- processResult{PhotoID: p.ID, ID: id, WasUploaded: uploaded, Error: err, Category: resultCategory(err), BytesDownloaded: bytesDown, BytesUploaded: bytesUp}
+				}
+			}(w)
+		}
+
+		// Feed jobs until shutdown or the album deadline
 		go func() {
-			defer wg.Done()
-			for p := range jobs {
-				id, uploaded, bytesDown, bytesUp, err := a.processItem(p, albumTitle, ac.URL, existingFiles, globalAssets)
-				results <- processResult{ID: id, WasUploaded: uploaded, Error: err, BytesDownloaded: bytesDown, BytesUploaded: bytesUp}
+			defer close(jobs)
+			for _, p := range items {
+				select {
+				case jobs <- p:
+				case <-syncCtx.Done():
+					return
+				}
 			}
 		}()
-	}
-
-	// Feed jobs
-	go func() {
-		for _, p := range album.Photos {
-			jobs <- p
-		}
-		close(jobs)
-	}()
 
-	// Close results after all workers finish
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+		// Close results after all workers finish
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		return results
+	}
+	results := runItems(pending)
 
 	// Stream results as they arrive, flushing new assets to album every 10%
 	flushInterval := total / 10
@@ -257,137 +830,813 @@ func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Alb
 		flushInterval = 1
 	}
 	lastFlushCount := 0
+	var unsentToAlbum []string // Assets of failed album add batches
+	var plog *progressLog      // Started once the items already in the album are recorded
 
-	for res := range results {
+	record := func(res processResult) {
 		processed++
 		wasFailed := false
 		wasSkipped := false
 		wasAdded := false
 
-		if res.Error != nil {
-			logger.Error("Failed to process item", "error", res.Error)
+		if skipCategory(res.Category) {
+			categories[res.Category]++
+			skipped++
+			wasSkipped = true
+		} else if errors.Is(res.Error, googlephotos.ErrRestricted) {
+			logger.Warn("Item not downloadable, marking restricted", "id", res.PhotoID, "error", res.Error)
+			a.State.MarkRestricted(ac.URL, res.PhotoID)
+			restricted++
+			wasSkipped = true
+		} else if res.Error != nil {
+			logger.Error("Failed to process item", "error", res.Error, "category", res.Category)
+			categories[res.Category]++
 			failed++
 			wasFailed = true
 		} else {
 			if res.WasUploaded {
 				added++
 				wasAdded = true
+				if res.ID != "" { // Empty in dry run
+					uploadedIds = append(uploadedIds, res.ID)
+				}
 			} else if res.ID == "" {
 				skipped++
 				wasSkipped = true
 			}
 			if res.ID != "" {
 				newAssetIds = append(newAssetIds, res.ID)
+				assetByPhoto[res.PhotoID] = res.ID
+				a.State.MarkProcessed(ac.URL, res.PhotoID, res.ID)
+			}
+			if coverID != "" && res.PhotoID == coverID {
+				coverAssetId = res.ID
 			}
 		}
 
+		bytesDownloaded += res.BytesDownloaded
+		bytesUploaded += res.BytesUploaded
+
 		// Update progress tracker
 		tracker.RecordItem(res.BytesDownloaded, res.BytesUploaded, wasAdded, wasSkipped, wasFailed)
 
 		// Flush new assets to album every ~10% of total items
 		if albumId != "" && len(newAssetIds) > lastFlushCount && (processed%flushInterval == 0 || processed == total) {
-			batch := newAssetIds[lastFlushCount:]
+			batch := append(unsentToAlbum[:len(unsentToAlbum):len(unsentToAlbum)], newAssetIds[lastFlushCount:]...)
 			logger.Info("Adding assets to album (incremental)", "count", len(batch), "progress", fmt.Sprintf("%d/%d", processed, total))
-			if err := a.Client.AddAssetsToAlbum(albumId, batch); err != nil {
+			res, err := a.Client.AddAssetsToAlbum(albumId, batch)
+			addedToAlbum += logAlbumAdd(logger, res)
+			if err != nil {
 				logger.Error("Error adding assets to album", "error", err)
-			} else {
-				lastFlushCount = len(newAssetIds)
 			}
+			lastFlushCount = len(newAssetIds)
+			unsentToAlbum = res.Unsent // Retried with the next flush
+		}
+
+		if plog != nil {
+			plog.update(time.Now(), processed, added, skipped, failed)
+		}
+	}
+
+	// Hold back failed items for a second pass so transient errors don't end up in the summary
+	retryDelay := defaultFailedRetryDelay
+	if a.Cfg.FailedRetryDelay != "" {
+		retryDelay, _ = time.ParseDuration(a.Cfg.FailedRetryDelay) // Checked by Validate
+	}
+	photoByID := make(map[string]googlephotos.Photo, len(album.Photos))
+	for _, p := range album.Photos {
+		photoByID[p.ID] = p
+	}
+	var retryQueue []googlephotos.Photo
+
+	for _, p := range present {
+		if a.Cfg.DryRun {
+			logger.Info("Dry run: would skip (duplicate)", "id", strings.TrimPrefix(photoBaseName(p), "gp_"))
 		}
+		record(processResult{PhotoID: p.ID})
+	}
+	plog = a.newAlbumProgressLog(logger, total, processed, a.Cfg.Debug || jsonLogs)
+	for res := range results {
+		if retryDelay > 0 && res.Error != nil && !skipCategory(res.Category) && !errors.Is(res.Error, googlephotos.ErrRestricted) {
+			logger.Debug("Item failed, queued for retry", "id", res.PhotoID, "error", res.Error)
+			retryQueue = append(retryQueue, photoByID[res.PhotoID])
+			continue
+		}
+		record(res)
+	}
 
-		// Log progress every 100 items in debug mode
-		if a.Cfg.Debug && processed%100 == 0 {
-			logger.Debug("Progress", "processed", processed, "total", total, "added", added, "skipped", skipped, "failed", failed)
+	if len(retryQueue) > 0 && syncCtx.Err() == nil {
+		logger.Info("Retrying failed items", "count", len(retryQueue), "delay", retryDelay)
+		if sleepContext(syncCtx, retryDelay) {
+			for res := range runItems(retryQueue) {
+				record(res)
+			}
 		}
 	}
 
 	// Stop tracker and print final summary
 	tracker.Stop()
+	summary.Added, summary.Skipped, summary.Failed, summary.Restricted = added, skipped, failed, restricted
+	if ctx.Err() != nil {
+		logger.Warn("Sync interrupted, adding uploaded items and saving state", "processed", processed, "total", total)
+	} else if syncCtx.Err() != nil && processed < total {
+		logger.Warn(fmt.Sprintf("Album sync deadline exceeded, %d items left unprocessed", total-processed),
+			"max_sync_duration", ac.MaxSyncDuration, "processed", processed, "total", total)
+		summary.Error = fmt.Sprintf("sync deadline exceeded, %d items left unprocessed", total-processed)
+	}
 
 	// Flush any remaining assets not yet added
-	if albumId != "" && len(newAssetIds) > lastFlushCount {
-		batch := newAssetIds[lastFlushCount:]
+	if albumId != "" && (len(newAssetIds) > lastFlushCount || len(unsentToAlbum) > 0) {
+		batch := append(unsentToAlbum[:len(unsentToAlbum):len(unsentToAlbum)], newAssetIds[lastFlushCount:]...)
 		logger.Info("Adding remaining assets to album", "count", len(batch), "album", albumTitle)
-		err := a.Client.AddAssetsToAlbum(albumId, batch)
+		res, err := a.Client.AddAssetsToAlbum(albumId, batch)
+		addedToAlbum += logAlbumAdd(logger, res)
 		if err != nil {
 			logger.Error("Error adding assets to album", "error", err)
+		} else if len(res.Unsent) > 0 {
+			logger.Error("Some assets could not be added to the album", "count", len(res.Unsent))
+		}
+	}
+	if a.Cfg.DryRun {
+		// Nothing was changed in Immich, so there's nothing to link, tag or record
+		logger.Info("Sync finished (dry run)", "added", added, "skipped", skipped, "failed", failed, "restricted", restricted, "total", processed)
+		return nil
+	}
+	if albumId != "" && len(ac.AlsoAddTo) > 0 {
+		// Everything in the main album, including items that were already there
+		allIds := make([]string, 0, len(album.Photos))
+		for _, p := range album.Photos {
+			if id := assetByPhoto[p.ID]; id != "" {
+				allIds = append(allIds, id)
+			} else if id, ok := lookupAsset(existingFiles, albumKey, p); ok {
+				allIds = append(allIds, id)
+			} else if id, ok := a.State.Processed(ac.URL, p.ID); ok {
+				allIds = append(allIds, id)
+			}
+		}
+		a.fanOut(a.resolveExtraAlbums(ac, albumCache, logger), allIds, logger)
+	}
+	if a.Cfg.PairLivePhotos && len(uploadedIds) > 0 {
+		uploaded := make(map[string]bool, len(uploadedIds))
+		for _, id := range uploadedIds {
+			uploaded[id] = true
+		}
+		a.linkLivePhotos(album.Photos, assetByPhoto, uploaded, logger)
+	}
+	if ac.PersonID != "" && len(uploadedIds) > 0 {
+		a.tagPerson(ac.PersonID, album.Photos, assetByPhoto, uploadedIds, logger)
+	}
+	if len(ac.Tags) > 0 && len(newAssetIds) > 0 {
+		a.applyTags(ac.Tags, newAssetIds, logger)
+	}
+	if ac.LockedFolder && len(uploadedIds) > 0 {
+		if err := a.Client.SetAssetsVisibility(uploadedIds, "locked"); err != nil {
+			logger.Warn("Could not move uploaded assets to the locked folder, the Immich server may not support it", "count", len(uploadedIds), "error", err)
+		} else {
+			logger.Info("Moved uploaded assets to the locked folder", "count", len(uploadedIds))
+		}
+	}
+	// Only fresh uploads: existing assets keep whatever the user set on them
+	if ac.AutoFavorite && len(uploadedIds) > 0 {
+		if err := a.Client.SetAssetsFavorite(uploadedIds, true); err != nil {
+			logger.Warn("Could not mark uploaded assets as favorites", "count", len(uploadedIds), "error", err)
+		} else {
+			logger.Info("Marked uploaded assets as favorites", "count", len(uploadedIds))
+		}
+	}
+	if ac.AutoArchive && !ac.LockedFolder && len(uploadedIds) > 0 {
+		if err := a.Client.SetAssetsArchived(uploadedIds); err != nil {
+			logger.Warn("Could not archive uploaded assets", "count", len(uploadedIds), "error", err)
+		} else {
+			logger.Info("Archived uploaded assets", "count", len(uploadedIds))
+		}
+	}
+	if coverID != "" && albumId != "" {
+		a.setAlbumCover(albumId, coverID, coverAssetId, currentCover, job, logger)
+	} else if !ac.ImportCover && albumId != "" && albumListed && currentCover == "" && len(uploadedIds) > 0 {
+		// Album had no cover before this sync (e.g. just created): use the newest upload
+		if assetId := newestUpload(album.Photos, assetByPhoto, uploadedIds); assetId != "" {
+			if err := a.Client.UpdateAlbum(albumId, immich.AlbumUpdate{AlbumThumbnailAssetId: assetId}); err != nil {
+				logger.Warn("Error setting album cover", "error", err)
+			} else {
+				logger.Info("Set album cover to the newest uploaded item", "asset_id", assetId)
+			}
 		}
 	}
-	if a.Cfg.Debug {
-		logger.Info("Sync finished", "added", added, "skipped", skipped, "failed", failed, "total", processed)
+	if restricted > 0 {
+		logger.Warn("Some items could not be downloaded from Google (HTTP 403)", "restricted", restricted)
+	}
+	if a.Cfg.Debug || jsonLogs {
+		attrs := []any{"added", added, "added_to_album", addedToAlbum, "skipped", skipped, "failed", failed, "restricted", restricted, "total", processed}
+		logger.Info("Sync finished", append(attrs, categoryAttrs(categories)...)...)
+	} else if len(categories) > 0 {
+		logger.Info("Failed and skipped items by reason", categoryAttrs(categories)...)
+	}
+
+	// A sync cut short by shutdown or the deadline is not a clean sync: the items it
+	// never started count as failed, so neither quick check skips the album next run.
+	// Neither is one of a partly scraped album, whose missing items keep their state.
+	incomplete := syncCtx.Err() != nil && processed < total || album.Incomplete
+
+	if a.Metrics != nil {
+		err := a.Metrics.RecordAlbum(albumTitle, metrics.AlbumStats{
+			Added:           added,
+			Skipped:         skipped,
+			Failed:          failed,
+			Restricted:      restricted,
+			SkippedTooLarge: categories[categoryTooLarge],
+			BytesDownloaded: bytesDownloaded,
+			BytesUploaded:   bytesUploaded,
+			Complete:        !incomplete,
+		})
+		if err != nil {
+			logger.Warn("Failed to push metrics", "error", err)
+		}
+	}
+	if !incomplete {
+		a.health.syncSucceeded(time.Now())
+	}
+	if !album.Incomplete {
+		a.State.PruneProcessed(ac.URL, inAlbum)
+	}
+	if incomplete {
+		a.State.UpdateAlbum(ac.URL, func(st *state.AlbumState) { st.LastFailed = failed + total - processed })
+	} else {
+		a.recordCleanSync(ac, scrapedCount, lastItemID, failed)
 	}
+	return nil
 }
 
-func (a *App) processItem(p googlephotos.Photo, albumTitle, albumURL string, existingFiles map[string]string, globalAssets map[string]string) (string, bool, int64, int64, error) {
-	safeId := strings.ReplaceAll(p.ID, "/", "_")
-	safeId = strings.ReplaceAll(safeId, ":", "_")
-	baseName := fmt.Sprintf("gp_%s", safeId)
+// indexAlbumAssets maps an Immich album's assets by the keys lookupAsset tries:
+// filename without extension and deviceAssetId. With RecordSourceURL, assets whose
+// description names an item in its source marker are indexed under that item's
+// name too, so renamed assets still count as present. Items are matched by ID,
+// media URLs change over time and only count for markers without one.
+func (a *App) indexAlbumAssets(album *immich.Album, photos []googlephotos.Photo) map[string]string {
+	index := make(map[string]string, 2*len(album.Assets))
+	for _, asset := range album.Assets {
+		name := asset.OriginalFileName
+		if dot := strings.LastIndex(name, "."); dot != -1 {
+			name = name[:dot]
+		}
+		index[name] = asset.Id
+		if asset.DeviceAssetId != "" {
+			index[asset.DeviceAssetId] = asset.Id
+		}
+	}
+	if a.Cfg.RecordSourceURL {
+		baseByID := make(map[string]string, len(photos))
+		baseByURL := make(map[string]string, len(photos))
+		for _, p := range photos {
+			baseByID[p.ID] = photoBaseName(p)
+			baseByURL[p.URL] = photoBaseName(p)
+		}
+		for _, asset := range album.Assets {
+			src, id := parseSourceMarker(asset.ExifInfo.Description)
+			base, ok := baseByID[id]
+			if id == "" {
+				base, ok = baseByURL[src]
+			}
+			if ok {
+				index[base] = asset.Id
+			}
+		}
+	}
+	return index
+}
 
-	// O(1) check against pre-fetched album assets
-	if assetId, exists := existingFiles[baseName]; exists {
-		a.Logger.Debug("Asset already in album", "id", assetId, "filename", baseName)
-		return "", false, 0, 0, nil
+// logAlbumAdd reports Immich's per-asset album add results and returns how many were added
+func logAlbumAdd(logger *slog.Logger, res *immich.AlbumAddResult) int {
+	if res == nil {
+		return 0
+	}
+	if len(res.BatchErrors) > 0 && len(res.BatchErrors) < res.BatchesTotal {
+		for _, err := range res.BatchErrors {
+			logger.Warn("Album add batch failed", "error", err)
+		}
+		logger.Warn("Album add partially failed", "added", res.Added, "failed_batches", len(res.BatchErrors), "batches", res.BatchesTotal, "unsent", len(res.Unsent))
+	}
+	if res.Duplicates > 0 {
+		logger.Debug("Assets already in album", "count", res.Duplicates)
+	}
+	for id, reason := range res.Failed {
+		logger.Warn("Immich rejected asset for album", "id", id, "reason", reason)
+	}
+	return res.Added
+}
+
+// moveCoverFirst moves the album's cover item to the front of the photo list.
+// Returns the cover ID, or "" when the cover is unknown or was filtered out.
+func moveCoverFirst(album *googlephotos.Album) string {
+	if album.CoverID == "" {
+		return ""
+	}
+	for i, p := range album.Photos {
+		if p.ID == album.CoverID {
+			copy(album.Photos[1:i+1], album.Photos[:i])
+			album.Photos[0] = p
+			return p.ID
+		}
+	}
+	return ""
+}
+
+// newestUpload returns the asset of the most recently taken item uploaded in this sync
+func newestUpload(photos []googlephotos.Photo, assetByPhoto map[string]string, uploadedIds []string) string {
+	uploaded := make(map[string]bool, len(uploadedIds))
+	for _, id := range uploadedIds {
+		uploaded[id] = true
+	}
+	newest := ""
+	var newestAt time.Time
+	for _, p := range photos {
+		id := assetByPhoto[p.ID]
+		if !uploaded[id] {
+			continue
+		}
+		if newest == "" || p.TakenAt.After(newestAt) {
+			newest, newestAt = id, p.TakenAt
+		}
+	}
+	return newest
+}
+
+// setAlbumCover sets the imported cover item as the Immich album thumbnail.
+// When the cover was already in Immich this run didn't report its asset ID, so it's looked up.
+// An album already showing that asset is left alone.
+func (a *App) setAlbumCover(albumId, coverID, coverAssetId, currentCover string, job *albumSync, logger *slog.Logger) {
+	if coverAssetId == "" {
+		cover := googlephotos.Photo{ID: coverID}
+		if id, ok := lookupAsset(job.ExistingFiles, job.Key, cover); ok {
+			coverAssetId = id
+		} else if id, ok := lookupAsset(job.GlobalAssets, job.Key, cover); ok {
+			coverAssetId = id
+		}
+	}
+	if coverAssetId == "" {
+		logger.Warn("Album cover was not imported, keeping Immich's default cover", "id", coverID)
+		return
+	}
+	if coverAssetId == currentCover {
+		logger.Debug("Album cover already set", "id", coverID, "asset_id", coverAssetId)
+		return
+	}
+	if err := a.Client.UpdateAlbum(albumId, immich.AlbumUpdate{AlbumThumbnailAssetId: coverAssetId}); err != nil {
+		logger.Error("Error setting album cover", "error", err)
+		return
+	}
+	logger.Info("Set album cover", "id", coverID, "asset_id", coverAssetId)
+}
+
+// recordCleanSync stores what the quick checks compare against on the next run
+func (a *App) recordCleanSync(ac config.GooglePhotosConfig, scrapedCount int, lastItemID string, failed int) {
+	a.State.UpdateAlbum(ac.URL, func(st *state.AlbumState) {
+		st.LastSync = time.Now()
+		st.LastItemCount = scrapedCount
+		st.LastItemID = lastItemID
+		st.FilterHash = a.filterHash(ac)
+		st.LastFailed = failed
+	})
+}
+
+// albumUnchanged probes the first data segment of the album page and reports
+// whether the item count matches the last clean sync. Multi-page albums can't be
+// counted cheaply and always get a full scrape.
+func (a *App) albumUnchanged(ac config.GooglePhotosConfig, logger *slog.Logger) bool {
+	st := a.State.Album(ac.URL)
+	if st.LastSync.IsZero() || st.LastItemCount == 0 || st.LastFailed > 0 || a.filtersChanged(ac, st, logger) {
+		return false
+	}
+
+	probe, err := googlephotos.ProbeAlbum(a.GPClient, ac.URL)
+	if err != nil {
+		logger.Debug("Quick check failed, doing full sync", "error", err)
+		return false
+	}
+	if !probe.Complete {
+		logger.Debug("Album spans multiple pages, quick check not possible")
+		return false
+	}
+	if probe.ItemCount != st.LastItemCount {
+		logger.Info("Album item count changed", "previous", st.LastItemCount, "current", probe.ItemCount)
+		return false
+	}
+
+	logger.Info("Album unchanged since last sync, skipping", "count", probe.ItemCount, "last_sync", st.LastSync.Format(time.DateTime))
+	return true
+}
+
+// scrapeUnchanged reports whether a full scrape has the same item count and last
+// item as the last clean sync, so the per-item checks (HEAD requests included)
+// can be skipped. Covers the multi-page albums albumUnchanged can't probe.
+func (a *App) scrapeUnchanged(ac config.GooglePhotosConfig, count int, lastItemID string, logger *slog.Logger) bool {
+	st := a.State.Album(ac.URL)
+	if st.LastSync.IsZero() || st.LastFailed > 0 || st.LastItemID == "" || a.filtersChanged(ac, st, logger) {
+		return false
+	}
+	if count != st.LastItemCount || lastItemID != st.LastItemID {
+		return false
+	}
+	logger.Info("No changes detected since last sync, skipping items", "count", count, "last_sync", st.LastSync.Format(time.DateTime))
+	return true
+}
+
+// filtersChanged reports whether the settings selecting the album's items differ
+// from the last sync, which makes an unchanged item count meaningless
+func (a *App) filtersChanged(ac config.GooglePhotosConfig, st state.AlbumState, logger *slog.Logger) bool {
+	if st.FilterHash == a.filterHash(ac) {
+		return false
+	}
+	logger.Info("Item filter settings changed since last sync, doing full sync")
+	return true
+}
+
+// uniqueItems drops items a scrape listed more than once, keeping the first, and
+// items without an ID, which can't be tracked
+func uniqueItems(photos []googlephotos.Photo, logger *slog.Logger) []googlephotos.Photo {
+	photos, duplicates, idless := googlephotos.DeduplicatePhotos(photos)
+	if duplicates > 0 {
+		logger.Info("Collapsed duplicate items", "duplicates", duplicates, "remaining", len(photos))
+	}
+	if idless > 0 {
+		logger.Warn("Dropped items without an ID", "count", idless)
+	}
+	return photos
+}
+
+// scrapeAlbum scrapes an album, re-trying when an album that had items on the
+// previous run suddenly comes back empty (Google occasionally serves an empty data segment)
+func (a *App) scrapeAlbum(ctx context.Context, albumURL string, logger *slog.Logger) (*googlephotos.Album, error) {
+	album, err := googlephotos.ScrapeAlbum(a.GPClient, albumURL)
+	if err != nil {
+		return nil, err
+	}
+
+	lastCount := a.State.Album(albumURL).LastItemCount
+	retries := a.Cfg.EmptyScrapeRetries
+	if retries == 0 {
+		retries = defaultEmptyScrapeRetries
+	}
+	delay := defaultEmptyScrapeRetryDelay
+	if d := pauseDuration(logger, "emptyScrapeRetryDelay", a.Cfg.EmptyScrapeRetryDelay); d != 0 {
+		delay = max(d, 0)
+	}
+	for attempt := 1; len(album.Photos) == 0 && lastCount > 0 && attempt <= retries; attempt++ {
+		logger.Warn("Album scraped to 0 items but had items on the last run, retrying",
+			"last_count", lastCount, "attempt", attempt, "retries", retries, "delay", delay)
+		if !sleepContext(ctx, delay) {
+			return nil, ctx.Err()
+		}
+		album, err = googlephotos.RefetchAlbum(a.GPClient, albumURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(album.Photos) == 0 && lastCount > 0 {
+		logger.Warn("Album still empty after retries, treating as empty", "last_count", lastCount)
+	}
+	return album, nil
+}
+
+func (a *App) processItem(p googlephotos.Photo, job *albumSync) (string, bool, int64, int64, error) {
+	baseName := photoBaseName(p)
+	safeId := strings.TrimPrefix(baseName, "gp_")
+	externalId := stableID(job.Key, p.ID)
+
+	// Items already in the album never get here (presentInAlbum), unless the
+	// source has grown and the asset is to be replaced
+	var replaceId string
+	if assetId, exists := lookupAsset(job.ExistingFiles, job.Key, p); exists {
+		replaceId = assetId
+		job.Logger.Info("Source resolution increased, replacing asset", "id", assetId,
+			"old_pixels", job.AssetPixels[assetId], "new_width", p.Width, "new_height", p.Height)
+	}
+
+	// Synced in an earlier run (state file) but no longer in the album: deleted in Immich
+	if replaceId == "" {
+		if a.Cfg.RespectDeletions && a.State.Deleted(job.URL, p.ID) {
+			job.Logger.Info("Skipping previously-deleted item", "id", p.ID)
+			return "", false, 0, 0, nil
+		}
+		if assetId, ok := a.State.Processed(job.URL, p.ID); ok {
+			switch {
+			case !job.AlbumListed:
+				// Album contents unknown, trust the state file and skip without touching the network
+				job.Logger.Debug("Item synced in an earlier run", "id", p.ID, "asset_id", assetId)
+				if a.Cfg.DryRun {
+					job.Logger.Info("Dry run: would skip (duplicate)", "id", safeId, "asset_id", assetId)
+				}
+				return "", false, 0, 0, nil
+			case a.Cfg.RespectDeletions:
+				job.Logger.Info("Skipping previously-deleted item", "id", p.ID, "asset_id", assetId)
+				if !a.Cfg.DryRun {
+					a.State.MarkDeleted(job.URL, p.ID)
+				}
+				return "", false, 0, 0, nil
+			default:
+				job.Logger.Debug("Item removed from the Immich album since the last sync, syncing again", "id", p.ID, "asset_id", assetId)
+			}
+		}
 	}
 
 	// O(1) check against global Immich assets — avoids re-downloading and re-uploading
-	if assetId, exists := globalAssets[baseName]; exists {
-		a.Logger.Debug("Asset exists in Immich globally, adding to album", "id", assetId, "filename", baseName)
-		return assetId, false, 0, 0, nil
+	if replaceId == "" {
+		if assetId, exists := lookupAsset(job.GlobalAssets, job.Key, p); exists {
+			if a.Cfg.DryRun {
+				job.Logger.Info("Dry run: would skip (duplicate), adding the existing asset to the album", "id", safeId, "asset_id", assetId)
+				return "", false, 0, 0, nil
+			}
+			job.Logger.Debug("Asset exists in Immich globally, adding to album", "id", assetId, "external_id", externalId)
+			return assetId, false, 0, 0, nil
+		}
 	}
 
 	if a.Cfg.StrictMetadata && p.TakenAt.IsZero() {
-		a.Logger.Warn("Skipping item with missing metadata date",
+		if a.Cfg.DryRun {
+			job.Logger.Info("Dry run: would skip (missing metadata)", "id", safeId, "url", p.URL)
+			return "", false, 0, 0, nil
+		}
+		if a.Cfg.QuarantineDir != "" {
+			n, err := a.quarantineItem(p, job, "missing date")
+			if err != nil {
+				return "", false, n, 0, categorize(categoryOther, err)
+			}
+			return "", false, n, 0, categorize(categoryMetadata, errMissingMetadata)
+		}
+		job.Logger.Warn("Skipping item with missing metadata date",
 			"id", p.ID, "url", p.URL)
-		return "", false, 0, 0, nil
+		return "", false, 0, 0, categorize(categoryMetadata, errMissingMetadata)
+	}
+
+	if a.Cfg.DryRun {
+		// The extension is only known after downloading
+		takenAt := p.TakenAt
+		if !takenAt.IsZero() {
+			takenAt = takenAt.Add(job.DateOffset)
+		}
+		job.Logger.Info("Dry run: would upload", "id", safeId, "filename", baseName, "taken_at", takenAt, "replaces", replaceId)
+		return "", true, 0, 0, nil
 	}
 
 	// Download original media from Google Photos
-	a.Logger.Debug("Downloading item", "id", safeId)
-	r, size, ext, isVideo, err := googlephotos.DownloadMedia(a.GPClient, p.URL)
+	job.Logger.Debug("Downloading item", "id", safeId)
+	r, size, ext, isVideo, err := googlephotos.DownloadMedia(a.GPClient, p.URL, p.IsVideo)
+	if errors.Is(err, googlephotos.ErrRestricted) && a.Cfg.RefreshExpiredURLs {
+		// Base URLs can expire on long runs; retry once with a freshly scraped URL
+		if freshURL := a.refreshedURL(job, p.ID); freshURL != "" && freshURL != p.URL {
+			job.Logger.Info("Media URL rejected, retrying with refreshed URL", "id", p.ID)
+			p.URL = freshURL
+			r, size, ext, isVideo, err = googlephotos.DownloadMedia(a.GPClient, p.URL, p.IsVideo)
+		}
+	}
+	if errors.Is(err, googlephotos.ErrVideoTooLarge) {
+		job.Logger.Info("Skipping video exceeding size limit", "id", p.ID, "reason", err)
+		return "", false, 0, 0, categorize(categoryTooLarge, err)
+	}
+	quality := ""
+	if errors.Is(err, googlephotos.ErrRestricted) && !isVideo && !p.IsVideo && len(job.QualityFallback) > 0 {
+		// Original refused, walk the configured lower-quality renditions. Videos
+		// aren't swapped for a still, and other errors aren't worth more requests.
+		for _, suffix := range job.QualityFallback {
+			job.Logger.Debug("Download failed, trying lower quality", "id", safeId, "quality", suffix, "error", err)
+			var fbErr error
+			r, size, ext, fbErr = googlephotos.DownloadVariant(a.GPClient, p.URL, suffix)
+			if fbErr == nil {
+				job.Logger.Info("Downloaded item at reduced quality", "id", safeId, "quality", suffix, "original_error", err)
+				quality, isVideo, err = suffix, false, nil
+				break
+			}
+		}
+	}
 	if err != nil {
-		return "", false, 0, 0, fmt.Errorf("error downloading item: %w", err)
+		return "", false, 0, 0, categorize(categoryDownload, fmt.Errorf("error downloading item: %w", err))
 	}
 
 	bytesDownloaded := size
 
 	if isVideo && a.Cfg.SkipVideos {
 		r.Close()
-		a.Logger.Debug("Skipping video item", "id", p.ID)
+		job.Logger.Debug("Skipping video item", "id", p.ID)
 		return "", false, bytesDownloaded, 0, nil
 	}
 
+	takenAt := p.TakenAt
+	if !takenAt.IsZero() {
+		takenAt = takenAt.Add(job.DateOffset)
+	}
+
+	if a.Cfg.WriteExifDates && !isVideo && ext == ".jpg" && !takenAt.IsZero() {
+		r, size, err = a.writeExifDate(r, size, takenAt, job, safeId)
+		if err != nil {
+			return "", false, bytesDownloaded, 0, categorize(categoryDownload, err)
+		}
+	}
+
 	filename := baseName + ext
+	if a.Cfg.OriginalFilenames && p.FileName != "" {
+		// Dedup doesn't depend on the name: the stable ID travels as deviceAssetId
+		filename = originalFilename(p.FileName, ext)
+	}
+
+	// Same file already in Immich (e.g. from another shared album): link it instead of uploading
+	checksum := ""
+	if a.Cfg.ChecksumDedup && replaceId == "" {
+		checksum, r, err = checksumBody(r)
+		if err != nil {
+			return "", false, bytesDownloaded, 0, categorize(categoryDownload, err)
+		}
+		if assetId, ok := a.existingAssetByChecksum(checksum, job); ok {
+			r.Close()
+			job.Logger.Debug("Identical file already in Immich, adding to album", "id", safeId, "asset_id", assetId)
+			return assetId, false, bytesDownloaded, 0, nil
+		}
+	}
 
 	// Build description with source metadata
-	description := p.Description
-	sep := "\n"
-	if description != "" {
-		sep = "\n\n"
+	footer := a.descriptionFooter(job, p, quality)
+	maxLen := a.Cfg.MaxDescriptionLength
+	if maxLen == 0 {
+		maxLen = defaultMaxDescriptionLength
+	}
+	description, truncated := truncateDescription(p.Description, footer, maxLen)
+	if truncated {
+		job.Logger.Info("Truncated long description", "id", safeId, "max_length", maxLen)
 	}
-	description += fmt.Sprintf("%sSource Album: %s (%s)", sep, albumTitle, albumURL)
 
 	if p.TakenAt.IsZero() {
-		a.Logger.Warn("Uploading item with missing metadata date (using current time)",
+		job.Logger.Warn("Uploading item with missing metadata date (using current time)",
 			"id", safeId, "url", p.URL, "is_video", isVideo)
 	}
 
-	uploadedId, isDup, err := a.Client.UploadAssetStream(r, filename, size, p.TakenAt, description)
+	var body io.Reader = r
+	var uploadedHash hash.Hash
+	if a.Cfg.VerifyUploads {
+		// Hash exactly what goes over the wire, after any EXIF changes
+		uploadedHash = sha1.New()
+		body = io.TeeReader(r, uploadedHash)
+	}
+	uploadedId, isDup, err := a.Client.UploadAssetStream(body, filename, externalId, size, takenAt, description, job.LibraryID)
 	r.Close()
 	if err != nil {
-		return "", false, bytesDownloaded, 0, fmt.Errorf("error uploading %s: %w", filename, err)
+		return "", false, bytesDownloaded, 0, categorize(categoryUpload, fmt.Errorf("error uploading %s: %w", filename, err))
 	}
 	if uploadedId == "" {
-		return "", false, bytesDownloaded, 0, fmt.Errorf("upload returned empty ID for %s", filename)
+		return "", false, bytesDownloaded, 0, categorize(categoryUpload, fmt.Errorf("upload returned empty ID for %s", filename))
+	}
+	if uploadedHash != nil && !isDup {
+		// Immich dedups by checksum, a duplicate already has exactly these bytes
+		if err := a.verifyUpload(uploadedId, uploadedHash, job); err != nil {
+			return "", false, bytesDownloaded, size, categorize(categoryUpload, err)
+		}
 	}
 
 	bytesUploaded := size
+	if checksum != "" {
+		a.checksums.put(checksum, uploadedId)
+	}
 
 	if isDup {
-		a.Logger.Debug("Asset deduplicated by Immich", "filename", filename, "id", uploadedId)
+		job.Logger.Debug("Asset deduplicated by Immich", "filename", filename, "id", uploadedId)
 		return uploadedId, false, bytesDownloaded, bytesUploaded, nil
 	}
 
-	a.Logger.Debug("Uploaded item", "filename", filename, "id", uploadedId)
+	if replaceId != "" && replaceId != uploadedId {
+		// Old copy goes to the trash so it can still be restored
+		if err := a.Client.DeleteAssets([]string{replaceId}, false); err != nil {
+			job.Logger.Warn("Uploaded higher resolution copy but failed to remove old asset", "old_id", replaceId, "new_id", uploadedId, "error", err)
+		} else {
+			job.Logger.Info("Replaced asset with higher resolution copy", "old_id", replaceId, "new_id", uploadedId)
+		}
+	}
+
+	if p.Latitude != 0 || p.Longitude != 0 {
+		// Immich's upload endpoint takes no coordinates, set them on the new asset
+		if err := a.Client.SetAssetLocation(uploadedId, p.Latitude, p.Longitude); err != nil {
+			job.Logger.Warn("Failed to set asset location", "id", uploadedId, "error", err)
+		}
+	}
+
+	if p.IsMotionPhoto && !isVideo && !a.Cfg.SkipVideos {
+		down, up := a.uploadMotionPart(p, job, uploadedId, baseName, takenAt)
+		bytesDownloaded += down
+		bytesUploaded += up
+	}
+
+	job.Logger.Debug("Uploaded item", "filename", filename, "id", uploadedId)
 	return uploadedId, true, bytesDownloaded, bytesUploaded, nil
 }
 
+// presentInAlbum reports whether an item is already in the Immich album and
+// doesn't need replacing. It only consults the pre-fetched album assets.
+func (a *App) presentInAlbum(p googlephotos.Photo, job *albumSync) bool {
+	assetId, exists := lookupAsset(job.ExistingFiles, job.Key, p)
+	return exists && !(a.Cfg.ReplaceOnHigherRes && job.sourceIsLarger(assetId, p))
+}
+
+// refreshedURL returns a fresh media URL for a photo. The album is re-scraped
+// once per run on first use; later calls reuse that result.
+func (a *App) refreshedURL(job *albumSync, photoID string) string {
+	job.refreshOnce.Do(func() {
+		job.Logger.Info("Re-scraping album to refresh expired media URLs")
+		album, err := googlephotos.RefetchAlbum(a.GPClient, job.URL)
+		if err != nil {
+			job.Logger.Warn("Failed to refresh media URLs", "error", err)
+			return
+		}
+		job.freshURLs = make(map[string]string, len(album.Photos))
+		for _, p := range album.Photos {
+			job.freshURLs[p.ID] = p.URL
+		}
+	})
+	return job.freshURLs[photoID]
+}
+
+// sourceIsLarger reports whether the scraped photo has more pixels than the
+// stored Immich asset. Unknown dimensions on either side never trigger a replace.
+func (s *albumSync) sourceIsLarger(assetId string, p googlephotos.Photo) bool {
+	stored := s.AssetPixels[assetId]
+	return stored > 0 && p.Width > 0 && p.Height > 0 && p.Width*p.Height > stored
+}
+
+// stableID returns the external identifier stored as the Immich deviceAssetId.
+// It does not depend on the filename, so dedup survives naming changes.
+func stableID(albumKey, photoID string) string {
+	return fmt.Sprintf("gp:%s:%s", albumKey, photoID)
+}
+
+// lookupAsset finds a photo's asset by stable ID, falling back to the gp_ filename
+// (assets uploaded before stable IDs existed) in its current and legacy forms.
+// The legacy form is only tried when it has characters no current name has, so
+// it can't pick up the asset of another photo whose ID is that name.
+func lookupAsset(assets map[string]string, albumKey string, p googlephotos.Photo) (string, bool) {
+	if id, ok := assets[stableID(albumKey, p.ID)]; ok {
+		return id, true
+	}
+	if id, ok := assets[photoBaseName(p)]; ok {
+		return id, true
+	}
+	legacy := legacyBaseName(p)
+	if sanitizeID(p.ID) == p.ID || strings.IndexFunc(legacy, func(r rune) bool { return !safeIDRune(r) }) == -1 {
+		return "", false
+	}
+	id, ok := assets[legacy]
+	return id, ok
+}
+
+// originalFilename keeps the original filename, swapping in the downloaded file's
+// extension when they differ (e.g. a HEIC original served as JPEG)
+func originalFilename(name, ext string) string {
+	origExt := filepath.Ext(name)
+	if strings.EqualFold(origExt, ext) || ext == "" {
+		return name
+	}
+	return strings.TrimSuffix(name, origExt) + ext
+}
+
+// photoBaseName returns the Immich filename (without extension) used for a photo
+func photoBaseName(p googlephotos.Photo) string {
+	return "gp_" + sanitizeID(p.ID)
+}
+
+// maxSanitizedIDLen bounds the readable part of a sanitized ID
+const maxSanitizedIDLen = 100
+
+// sanitizeID makes a Google photo ID safe for filenames. IDs that are already
+// safe (letters, digits, '-', '_') and not too long are kept as they are, so
+// names of existing assets don't change. Anything else has its unsafe characters
+// replaced and a hash of the full original ID appended, so two IDs that only
+// differ in replaced characters, or beyond the length cap, stay distinct.
+func sanitizeID(id string) string {
+	var b strings.Builder
+	changed := len(id) > maxSanitizedIDLen
+	for i, r := range id {
+		if i >= maxSanitizedIDLen {
+			break
+		}
+		if safeIDRune(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+			changed = true
+		}
+	}
+	if !changed {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return b.String() + "_" + hex.EncodeToString(sum[:6])
+}
+
+// safeIDRune reports whether sanitizeID keeps r as it is
+func safeIDRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
+
+// legacyBaseName is the filename older versions derived from a photo ID,
+// still matched so their uploads are recognized
+func legacyBaseName(p googlephotos.Photo) string {
+	safeId := strings.ReplaceAll(p.ID, "/", "_")
+	safeId = strings.ReplaceAll(safeId, ":", "_")
+	return fmt.Sprintf("gp_%s", safeId)
+}
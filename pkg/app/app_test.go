@@ -0,0 +1,756 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+// testItems returns n plain photo items taken a second apart
+func testItems(n int) []fakeItem {
+	items := make([]fakeItem, n)
+	for i := range items {
+		items[i] = fakeItem{ID: fmt.Sprintf("item%02d", i), Width: 400, Height: 300, TakenAt: 1700000000000 + int64(i)*1000}
+	}
+	return items
+}
+
+func TestProcessAlbumDeadlineLeavesAlbumUnsynced(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(10)...)
+	g.delay = 50 * time.Millisecond
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{Workers: 1, QuickCheck: true}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL(), MaxSyncDuration: "100ms"}
+
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	uploaded := len(im.uploads())
+	if uploaded == 0 || uploaded == 10 {
+		t.Fatalf("uploaded %d items, want the deadline to stop the sync partway", uploaded)
+	}
+	st := a.State.Album(ac.URL)
+	if !st.LastSync.IsZero() || st.LastItemCount != 0 || st.LastItemID != "" {
+		t.Errorf("deadline-cut sync recorded as complete: %+v", st)
+	}
+	if st.LastFailed != 10-uploaded {
+		t.Errorf("LastFailed = %d, want the %d unstarted items", st.LastFailed, 10-uploaded)
+	}
+	if a.scrapeUnchanged(ac, 10, "item09", a.Logger) {
+		t.Error("album counts as unchanged after a deadline-cut sync")
+	}
+
+	// The next run picks up the rest
+	g.mu.Lock()
+	g.delay = 0
+	g.mu.Unlock()
+	ac.MaxSyncDuration = ""
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != 10 {
+		t.Errorf("uploaded %d items after the second run, want 10", n)
+	}
+	st = a.State.Album(ac.URL)
+	if st.LastSync.IsZero() || st.LastFailed != 0 || st.LastItemCount != 10 {
+		t.Errorf("complete sync not recorded: %+v", st)
+	}
+}
+
+func TestProcessAlbumInterruptedLeavesAlbumUnsynced(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(10)...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gets := 0
+	g.onGet = func(id, suffix string) {
+		if gets++; gets == 3 {
+			cancel() // SIGINT while the third item downloads
+		}
+	}
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{Workers: 1}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL()}
+
+	if err := syncAlbum(t, ctx, a, ac); err != nil {
+		t.Fatal(err)
+	}
+	uploaded := len(im.uploads())
+	if uploaded != 3 {
+		t.Errorf("uploaded %d items, want the 3 started before the interrupt", uploaded)
+	}
+	if added := len(im.album(im.albumNamed("Trip")).Assets); added != uploaded {
+		t.Errorf("%d assets added to the album, want all %d uploads", added, uploaded)
+	}
+	st := a.State.Album(ac.URL)
+	if !st.LastSync.IsZero() || st.LastFailed != 10-uploaded {
+		t.Errorf("interrupted sync recorded as complete: %+v", st)
+	}
+	if len(st.Processed) != uploaded {
+		t.Errorf("%d items recorded as processed, want %d", len(st.Processed), uploaded)
+	}
+}
+
+func TestProcessAlbumAfterShutdownDoesNothing(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(3)...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, nil, im)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := a.processAlbum(ctx, config.GooglePhotosConfig{URL: g.albumURL()}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if g.pages != 0 || len(im.calls) != 0 {
+		t.Errorf("album scraped (%d pages) or Immich called (%d requests) after shutdown", g.pages, len(im.calls))
+	}
+	if st := a.State.Album(g.albumURL()); !st.LastSync.IsZero() {
+		t.Errorf("album marked as synced: %+v", st)
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"0", 24 * time.Hour},
+		{"-1h", 24 * time.Hour},
+		{"daily", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := syncInterval(config.GooglePhotosConfig{SyncInterval: tt.value}); got != tt.want {
+			t.Errorf("syncInterval(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNextWake(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const minWait, maxWait = time.Second, time.Hour
+	tests := []struct {
+		name    string
+		hourly  time.Duration // Until the hourly album's next run
+		daily   time.Duration // Until the daily album's next run
+		running string        // Album currently syncing, "" for none
+		want    time.Duration
+	}{
+		{"hourly album first", 20 * time.Minute, 5 * time.Hour, "", 20 * time.Minute},
+		{"daily album first", 50 * time.Minute, 7 * time.Minute, "", 7 * time.Minute},
+		{"nearest album running", 20 * time.Minute, 45 * time.Minute, "hourly", 45 * time.Minute},
+		{"both running", 20 * time.Minute, 45 * time.Minute, "both", maxWait},
+		{"both far away", 2 * time.Hour, 20 * time.Hour, "", maxWait},
+		{"overdue", -3 * time.Minute, 5 * time.Hour, "", minWait},
+	}
+	for _, tt := range tests {
+		nextRun := map[string]time.Time{"hourly": now.Add(tt.hourly), "daily": now.Add(tt.daily)}
+		running := map[string]bool{tt.running: true}
+		if tt.running == "both" {
+			running = map[string]bool{"hourly": true, "daily": true}
+		}
+		if got := nextWake(nextRun, running, now, minWait, maxWait); got != tt.want {
+			t.Errorf("%s: nextWake = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyScrapeRetry(t *testing.T) {
+	items := []fakeItem{{ID: "a", Width: 400, Height: 300}, {ID: "b", Width: 400, Height: 300}}
+	tests := []struct {
+		name       string
+		delay      string
+		refillOn   int  // Page after which the album has its items again, 0 for never
+		cancelOn   int  // Page after which the sync is cancelled, 0 for never
+		wantPages  int  // Album pages fetched
+		wantUpload int  // Items uploaded
+		wantSynced bool // Album recorded as synced
+	}{
+		{"items back on retry", "10ms", 1, 0, 2, 2, true},
+		{"still empty after retries", "0", 0, 0, 3, 0, true},
+		{"shutdown while waiting", "1h", 0, 1, 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip")
+			im := newFakeImmich(t)
+			a := newTestApp(t, &config.Config{EmptyScrapeRetryDelay: tt.delay}, im)
+			a.State.UpdateAlbum(g.albumURL(), func(st *state.AlbumState) { st.LastItemCount = len(items) })
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			g.mu.Lock()
+			g.onPage = func(n int) {
+				if n == tt.refillOn {
+					g.setItems(items...)
+				}
+				if n == tt.cancelOn {
+					cancel()
+				}
+			}
+			g.mu.Unlock()
+
+			start := time.Now()
+			if err := syncAlbum(t, ctx, a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("sync took %v", elapsed)
+			}
+			if pages := g.pageCount(); pages != tt.wantPages {
+				t.Errorf("fetched the album page %d times, want %d", pages, tt.wantPages)
+			}
+			if n := len(im.uploads()); n != tt.wantUpload {
+				t.Errorf("uploaded %d items, want %d", n, tt.wantUpload)
+			}
+			if synced := !a.State.Album(g.albumURL()).LastSync.IsZero(); synced != tt.wantSynced {
+				t.Errorf("album recorded as synced: %v, want %v", synced, tt.wantSynced)
+			}
+		})
+	}
+}
+
+func TestPartlyScrapedAlbumKeepsState(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(2)...)
+	g.paging = true
+	im := newFakeImmich(t)
+	a := newTestApp(t, nil, im)
+	a.GPClient = g.contentClient()
+	url := g.albumURL()
+	a.State.MarkProcessed(url, "later-page-item", "asset-9")
+
+	if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != 2 {
+		t.Errorf("uploaded %d items, want the 2 scraped", n)
+	}
+	if _, ok := a.State.Processed(url, "later-page-item"); !ok {
+		t.Error("state of an item beyond the failed page was pruned")
+	}
+	if st := a.State.Album(url); !st.LastSync.IsZero() || !a.health.lastSync.IsZero() {
+		t.Errorf("partly scraped album recorded as a clean sync: %+v", st)
+	}
+
+	g.mu.Lock()
+	g.paging = false
+	g.mu.Unlock()
+	if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.State.Processed(url, "later-page-item"); ok {
+		t.Error("complete scrape kept the state of an item no longer in the album")
+	}
+	if a.State.Album(url).LastSync.IsZero() {
+		t.Error("complete scrape not recorded as a clean sync")
+	}
+}
+
+func TestRenamedAlbumKeepsSyncingIntoIt(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(2)...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL()}
+
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	albumID := im.albumNamed("Trip")
+	if albumID == "" {
+		t.Fatal("album not created")
+	}
+
+	im.renameAlbum(albumID, "Japan 2024")
+	g.setItems(testItems(3)...)
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if n := im.albumCount(); n != 1 {
+		t.Errorf("%d albums after rename, want the original only", n)
+	}
+	if n := len(im.album(albumID).Assets); n != 3 {
+		t.Errorf("renamed album has %d assets, want 3", n)
+	}
+}
+
+func TestSameTitleAlbumsGetTheirOwnImmichAlbums(t *testing.T) {
+	first := newFakeGoogle(t, "Trip", testItems(2)...)
+	second := newFakeGoogle(t, "Trip", fakeItem{ID: "other", Width: 400, Height: 300})
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+
+	for _, g := range []*fakeGoogle{first, second, first} {
+		if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := im.albumCount(); n != 2 {
+		t.Fatalf("%d Immich albums, want one per shared album", n)
+	}
+	for _, g := range []*fakeGoogle{first, second} {
+		id := a.State.Album(g.albumURL()).ImmichAlbumID
+		want := len(g.items)
+		if n := len(im.album(id).Assets); n != want {
+			t.Errorf("album of %s has %d assets, want %d", g.albumURL(), n, want)
+		}
+	}
+}
+
+func TestRetryPassRunsOnWorkerPool(t *testing.T) {
+	items := testItems(4)
+	g := newFakeGoogle(t, "Trip", items...)
+	for _, it := range items {
+		g.status[it.ID] = []int{http.StatusInternalServerError}
+	}
+
+	// Every retry waits for the others: one at a time would never get past this
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	retrying := 0
+	allRetrying := make(chan struct{})
+	g.onGet = func(id, suffix string) {
+		mu.Lock()
+		attempts[id]++
+		retry := attempts[id] == 2
+		if retry {
+			retrying++
+			if retrying == len(items) {
+				close(allRetrying)
+			}
+		}
+		mu.Unlock()
+		if retry {
+			select {
+			case <-allRetrying:
+			case <-time.After(5 * time.Second):
+				t.Errorf("retry of %s ran without the others", id)
+			}
+		}
+	}
+
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{Workers: 4, FailedRetryDelay: "10ms"}, im)
+	if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != len(items) {
+		t.Errorf("uploaded %d items after the retry pass, want %d", n, len(items))
+	}
+	if st := a.State.Album(g.albumURL()); st.LastFailed != 0 {
+		t.Errorf("LastFailed = %d, want 0", st.LastFailed)
+	}
+}
+
+func TestDuplicateItemsUploadOnce(t *testing.T) {
+	items := testItems(2)
+	g := newFakeGoogle(t, "Trip", items[0], items[1], items[0])
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+
+	if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != 2 {
+		t.Errorf("%d uploads, want 2", n)
+	}
+	if n := g.getCount(items[0].ID + "=d"); n != 1 {
+		t.Errorf("repeated item downloaded %d times, want once", n)
+	}
+	if st := a.State.Album(g.albumURL()); st.LastItemCount != 2 {
+		t.Errorf("LastItemCount = %d, want 2", st.LastItemCount)
+	}
+}
+
+func TestTimeZoneOffsetKeepsLocalDate(t *testing.T) {
+	// 08:30 on New Year's Day in Tokyo is still the 31st in UTC
+	taken := time.Date(2024, 1, 1, 8, 30, 0, 0, time.FixedZone("JST", 9*3600))
+	g := newFakeGoogle(t, "Trip", fakeItem{ID: "item00", Width: 400, Height: 300, TakenAt: taken.UnixMilli(), TZ: 9 * 3600 * 1000})
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+
+	album, err := googlephotos.ScrapeAlbum(a.GPClient, g.albumURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := album.Photos[0].TakenAt.Format(time.RFC3339); got != "2024-01-01T08:30:00+09:00" {
+		t.Errorf("scraped TakenAt = %s, want 2024-01-01T08:30:00+09:00", got)
+	}
+
+	if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+		t.Fatal(err)
+	}
+	ups := im.uploads()
+	if len(ups) != 1 {
+		t.Fatalf("uploaded %d items, want 1", len(ups))
+	}
+	if ups[0].CreatedAt != "2024-01-01T08:30:00+09:00" {
+		t.Errorf("fileCreatedAt = %s, want 2024-01-01T08:30:00+09:00", ups[0].CreatedAt)
+	}
+}
+
+func TestAutoFavoriteAndArchiveOnlyNewUploads(t *testing.T) {
+	existingBody := append([]byte(nil), jpegBytes...)
+	g := newFakeGoogle(t, "Trip",
+		fakeItem{ID: "item00", Width: 400, Height: 300},
+		fakeItem{ID: "item01", Width: 400, Height: 300, Body: existingBody}, // Already in Immich, deduplicated on upload
+	)
+	im := newFakeImmich(t)
+	existing := im.addAsset(fakeAsset{Name: "IMG_0001.jpg", Checksum: checksumOf(existingBody)})
+	a := newTestApp(t, &config.Config{}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL(), AutoFavorite: true, AutoArchive: true}
+
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	ups := im.uploads()
+	if len(ups) != 1 {
+		t.Fatalf("uploaded %d items, want 1", len(ups))
+	}
+
+	updates := map[string][]string{} // Field=value -> asset IDs
+	for _, c := range im.callsTo("PUT", "assets") {
+		if c.Path != "assets" {
+			continue
+		}
+		var req map[string]interface{}
+		if err := json.Unmarshal(c.Body, &req); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range req {
+			if k == "ids" {
+				continue
+			}
+			for _, id := range req["ids"].([]interface{}) {
+				key := fmt.Sprintf("%s=%v", k, v)
+				updates[key] = append(updates[key], id.(string))
+			}
+		}
+	}
+	want := map[string][]string{
+		"isFavorite=true":    {ups[0].ID},
+		"visibility=archive": {ups[0].ID},
+	}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("bulk updates %v, want %v (existing asset %s untouched)", updates, want, existing)
+	}
+}
+
+func TestProcessAlbumSavesStateOnEveryExit(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		ac     config.GooglePhotosConfig
+		broken bool // Album page fails to scrape
+		saved  bool
+	}{
+		{"full sync", config.Config{}, config.GooglePhotosConfig{}, false, true},
+		{"all filtered", config.Config{}, config.GooglePhotosConfig{MinWidth: 1000}, false, true},
+		{"noCreate without album", config.Config{}, config.GooglePhotosConfig{NoCreate: true}, false, true},
+		{"scrape error", config.Config{}, config.GooglePhotosConfig{}, true, true},
+		{"dry run", config.Config{DryRun: true}, config.GooglePhotosConfig{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", testItems(2)...)
+			im := newFakeImmich(t)
+			cfg := tt.cfg
+			cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+			a := newTestApp(t, &cfg, im)
+			ac := tt.ac
+			ac.URL = g.albumURL()
+			if tt.broken {
+				gone := httptest.NewServer(http.NotFoundHandler())
+				defer gone.Close()
+				ac.URL = gone.URL + "/share/KEY"
+			}
+
+			syncAlbum(t, context.Background(), a, ac)
+			_, err := os.Stat(cfg.StateFile)
+			if saved := err == nil; saved != tt.saved {
+				t.Errorf("state file written: %v, want %v", saved, tt.saved)
+			}
+		})
+	}
+}
+
+func TestWorkerRampUp(t *testing.T) {
+	tests := []struct {
+		name    string
+		rampUp  string
+		minSpan time.Duration // Between the first and the last worker's first download
+		warns   bool
+	}{
+		{"off", "", 0, false},
+		{"staggered", "150ms", 80 * time.Millisecond, false},
+		{"invalid", "soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", testItems(3)...)
+			g.delay = 250 * time.Millisecond // Longer than the ramp-up, so every worker takes one item
+			var mu sync.Mutex
+			var starts []time.Time
+			g.onGet = func(id, suffix string) {
+				mu.Lock()
+				starts = append(starts, time.Now())
+				mu.Unlock()
+			}
+			im := newFakeImmich(t)
+			a := newTestApp(t, &config.Config{Workers: 3, WorkerRampUp: tt.rampUp}, im)
+			var logs bytes.Buffer
+			a.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+				t.Fatal(err)
+			}
+			if len(starts) != 3 {
+				t.Fatalf("%d downloads, want 3", len(starts))
+			}
+			if span := starts[2].Sub(starts[0]); span < tt.minSpan || (tt.minSpan == 0 && span > 50*time.Millisecond) {
+				t.Errorf("workers started over %v, want at least %v", span, tt.minSpan)
+			}
+			if warned := strings.Contains(logs.String(), "Invalid workerRampUp"); warned != tt.warns {
+				t.Errorf("warned about workerRampUp: %v, want %v", warned, tt.warns)
+			}
+		})
+	}
+}
+
+func TestImportCoverPatchesOnlyWhenChanged(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(3)...)
+	g.cover = "item02"
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL(), ImportCover: true}
+
+	coverPatches := func() int {
+		n := 0
+		for _, c := range im.callsTo("PATCH", "albums/") {
+			if strings.Contains(string(c.Body), "albumThumbnailAssetId") {
+				n++
+			}
+		}
+		return n
+	}
+	coverName := func() string {
+		thumb := im.album(im.albumNamed("Trip")).Thumbnail
+		for _, up := range im.uploads() {
+			if up.ID == thumb {
+				return up.Name
+			}
+		}
+		return thumb
+	}
+
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if coverPatches() != 1 || coverName() != "gp_item02.jpg" {
+		t.Fatalf("first run: %d cover updates, cover %q, want 1 and gp_item02.jpg", coverPatches(), coverName())
+	}
+
+	// Same cover: nothing to change
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if n := coverPatches(); n != 1 {
+		t.Errorf("unchanged cover updated again (%d updates)", n)
+	}
+
+	// Google's cover changed
+	g.cover = "item00"
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if coverPatches() != 2 || coverName() != "gp_item00.jpg" {
+		t.Errorf("after the cover changed: %d cover updates, cover %q, want 2 and gp_item00.jpg", coverPatches(), coverName())
+	}
+}
+
+func TestAlbumDescriptionAndCover(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    *fakeAlbum // Album already in Immich, nil to let the sync create it
+		description string     // albumDescription setting
+		wantDesc    string     // "URL" stands for "Synced from <album URL>"
+		newestCover bool       // Cover set to the newest upload
+	}{
+		{"created", nil, "", "URL", true},
+		{"created with description", nil, "Family trip", "Family trip", true},
+		{"existing, edited description", &fakeAlbum{Description: "Edited by hand"}, "Family trip", "Edited by hand", true},
+		{"existing with cover", &fakeAlbum{Thumbnail: "chosen"}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", testItems(3)...)
+			im := newFakeImmich(t)
+			if tt.existing != nil {
+				id := im.addAlbum("Trip")
+				im.mu.Lock()
+				im.albums[id].Description = tt.existing.Description
+				im.albums[id].Thumbnail = tt.existing.Thumbnail
+				im.mu.Unlock()
+			}
+			a := newTestApp(t, &config.Config{}, im)
+			ac := config.GooglePhotosConfig{URL: g.albumURL(), AlbumDescription: tt.description}
+
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			alb := im.album(im.albumNamed("Trip"))
+			wantDesc := tt.wantDesc
+			if wantDesc == "URL" {
+				wantDesc = "Synced from " + ac.URL
+			}
+			if alb.Description != wantDesc {
+				t.Errorf("description %q, want %q", alb.Description, wantDesc)
+			}
+
+			newest := ""
+			for _, up := range im.uploads() {
+				if up.Name == "gp_item02.jpg" {
+					newest = up.ID
+				}
+			}
+			if tt.newestCover && alb.Thumbnail != newest {
+				t.Errorf("cover %q, want the newest upload %q", alb.Thumbnail, newest)
+			}
+			if !tt.newestCover && alb.Thumbnail != tt.existing.Thumbnail {
+				t.Errorf("cover changed to %q, want %q kept", alb.Thumbnail, tt.existing.Thumbnail)
+			}
+		})
+	}
+}
+
+func TestDuplicateAlbumMakesNoProbes(t *testing.T) {
+	tests := []struct {
+		name      string
+		fresh     bool // Second sync by a new App without the first one's state
+		replace   bool // replaceOnHigherRes on the second sync
+		albumName string
+	}{
+		{"same state", false, false, ""},
+		{"fresh state", true, false, ""},
+		{"replaceOnHigherRes", true, true, ""},
+		{"assets in another album", true, false, "Copy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := testItems(12)
+			g := newFakeGoogle(t, "Trip", items...)
+			im := newFakeImmich(t)
+			a := newTestApp(t, &config.Config{Workers: 4}, im)
+			if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+				t.Fatal(err)
+			}
+			heads, gets := g.headCount(), g.mediaGets()
+
+			if tt.fresh {
+				a = newTestApp(t, &config.Config{Workers: 4, ReplaceOnHigherRes: tt.replace}, im)
+			}
+			if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL(), AlbumName: tt.albumName}); err != nil {
+				t.Fatal(err)
+			}
+			if n, m := g.headCount()-heads, g.mediaGets()-gets; n != 0 || m != 0 {
+				t.Errorf("second sync made %d HEAD and %d media GET requests, want none", n, m)
+			}
+			if n := len(im.uploads()); n != len(items) {
+				t.Errorf("%d uploads, want %d", n, len(items))
+			}
+			name := orDefault(tt.albumName, "Trip")
+			if n := len(im.album(im.albumNamed(name)).Assets); n != len(items) {
+				t.Errorf("album %s has %d assets, want %d", name, n, len(items))
+			}
+		})
+	}
+}
+
+func TestLogFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		debug  bool
+	}{
+		{"json", false},
+		{"json", true},
+		{"text", true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s debug=%v", tt.format, tt.debug), func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", testItems(3)...)
+			im := newFakeImmich(t)
+			cfg := &config.Config{Debug: tt.debug}
+			a := newTestApp(t, cfg, im)
+			cfg.LogFormat = tt.format
+			var buf bytes.Buffer
+			a.Logger = newLogger(&buf, cfg)
+			if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			msgs := make(map[string]map[string]interface{})
+			for _, line := range lines {
+				if tt.format == "text" {
+					if strings.Contains(line, "level=") || !regexp.MustCompile(`^time=\d\d:\d\d:\d\d `).MatchString(line) {
+						t.Errorf("text line %q, want a short time and no level", line)
+					}
+					continue
+				}
+				var rec map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &rec); err != nil {
+					t.Fatalf("log line %q is not JSON: %v", line, err)
+				}
+				if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(rec["time"])); err != nil {
+					t.Errorf("line %q: time isn't a full timestamp", line)
+				}
+				if rec["level"] == nil || rec["msg"] == nil || rec["album_url"] != g.albumURL() {
+					t.Errorf("line %q lacks level, msg or album_url", line)
+				}
+				msgs[rec["msg"].(string)] = rec
+			}
+			if tt.format == "text" {
+				return
+			}
+
+			want := map[string][]string{"Syncing Google Photos Album": {"level", "album_url"}}
+			if tt.debug {
+				want["Sync finished"] = []string{"added", "skipped", "failed", "total"}
+				want["Uploaded item"] = []string{"filename", "id"}
+			}
+			for msg, keys := range want {
+				rec, ok := msgs[msg]
+				if !ok {
+					t.Errorf("no %q line", msg)
+					continue
+				}
+				for _, k := range keys {
+					if _, ok := rec[k]; !ok {
+						t.Errorf("%q line has no %s: %v", msg, k, rec)
+					}
+				}
+			}
+			if tt.debug && msgs["Sync finished"]["added"] != 3.0 {
+				t.Errorf("Sync finished reports added=%v, want 3", msgs["Sync finished"]["added"])
+			}
+			if rec := msgs["Syncing Google Photos Album"]; rec != nil && rec["level"] != "INFO" {
+				t.Errorf("level %v, want INFO", rec["level"])
+			}
+		})
+	}
+}
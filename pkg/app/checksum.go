@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+// checksumCache remembers the Immich asset of every checksum seen during this
+// run, so an item shared by several albums is looked up in Immich only once
+type checksumCache struct {
+	mu  sync.Mutex
+	ids map[string]string // Base64 SHA-1 -> Immich asset ID
+}
+
+func (c *checksumCache) get(sum string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.ids[sum]
+	return id, ok
+}
+
+func (c *checksumCache) put(sum, assetId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ids == nil {
+		c.ids = make(map[string]string)
+	}
+	c.ids[sum] = assetId
+}
+
+// checksumBody returns the base64 SHA-1 of a downloaded item (Immich's dedup key)
+// and a reader positioned at the start of the content again
+func checksumBody(r io.ReadCloser) (string, io.ReadCloser, error) {
+	h := sha1.New()
+	if seeker, ok := r.(io.Seeker); ok {
+		if _, err := io.Copy(h, r); err != nil {
+			r.Close()
+			return "", nil, fmt.Errorf("error reading downloaded item: %w", err)
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			r.Close()
+			return "", nil, fmt.Errorf("error reading downloaded item: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(h.Sum(nil)), r, nil
+	}
+
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading downloaded item: %w", err)
+	}
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// errChecksumMismatch is returned when Immich stored different bytes than were uploaded
+var errChecksumMismatch = errors.New("uploaded file doesn't match Immich's checksum")
+
+// verifyUpload compares the SHA-1 of the uploaded bytes with the checksum Immich
+// reports for the new asset. A mismatching asset is deleted for good, otherwise
+// the next run would find it by name and never upload the item again. With
+// KeepMismatchedUploads it stays in Immich for inspection.
+func (a *App) verifyUpload(assetId string, uploaded hash.Hash, job *albumSync) error {
+	asset, err := a.Client.GetAsset(assetId)
+	if err != nil {
+		// Can't tell, the upload itself succeeded
+		job.Logger.Warn("Could not fetch uploaded asset to verify its checksum", "asset_id", assetId, "error", err)
+		return nil
+	}
+	sum := base64.StdEncoding.EncodeToString(uploaded.Sum(nil))
+	if asset.Checksum == sum {
+		return nil
+	}
+
+	if a.Cfg.KeepMismatchedUploads {
+		job.Logger.Error("Uploaded asset doesn't match the downloaded file, keeping it", "asset_id", assetId, "uploaded", sum, "immich", asset.Checksum)
+		return fmt.Errorf("%w (asset %s)", errChecksumMismatch, assetId)
+	}
+	job.Logger.Error("Uploaded asset doesn't match the downloaded file, deleting it", "asset_id", assetId, "uploaded", sum, "immich", asset.Checksum)
+	if err := a.Client.DeleteAssets([]string{assetId}, true); err != nil {
+		job.Logger.Warn("Failed to delete mismatched asset", "asset_id", assetId, "error", err)
+	}
+	return fmt.Errorf("%w (asset %s)", errChecksumMismatch, assetId)
+}
+
+// existingAssetByChecksum returns the Immich asset that already holds this exact
+// file, from any album or upload source. Trashed assets don't count.
+func (a *App) existingAssetByChecksum(sum string, job *albumSync) (string, bool) {
+	if id, ok := a.checksums.get(sum); ok {
+		return id, true
+	}
+	results, err := a.Client.BulkUploadCheck([]immich.UploadCheck{{Id: sum, Checksum: sum}})
+	if err != nil {
+		job.Logger.Warn("Checksum lookup failed, uploading", "error", err)
+		return "", false
+	}
+	for _, res := range results {
+		if res.Action == "reject" && res.Reason == "duplicate" && res.AssetId != "" && !res.IsTrashed {
+			a.checksums.put(sum, res.AssetId)
+			return res.AssetId, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+func TestVerifyUploads(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum string // Reported by Immich, the real one when empty
+		keep     bool
+		ok       bool // Item marked processed
+		deleted  bool
+	}{
+		{"matching", "", false, true, false},
+		{"mismatching", "bm90IHRoZSBzYW1lIGZpbGU=", false, false, true},
+		{"mismatching kept", "bm90IHRoZSBzYW1lIGZpbGU=", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", fakeItem{ID: "item00", Width: 400, Height: 300})
+			im := newFakeImmich(t)
+			im.checksum = tt.checksum
+			a := newTestApp(t, &config.Config{VerifyUploads: true, KeepMismatchedUploads: tt.keep}, im)
+			ac := config.GooglePhotosConfig{URL: g.albumURL()}
+
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := a.State.Processed(ac.URL, "item00"); ok != tt.ok {
+				t.Errorf("item processed: %v, want %v", ok, tt.ok)
+			}
+			if len(im.callsTo("GET", "assets/")) == 0 {
+				t.Error("uploaded asset never fetched to verify it")
+			}
+			if deleted := len(im.uploads()) == 0; deleted != tt.deleted {
+				t.Errorf("asset deleted: %v, want %v", deleted, tt.deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"strings"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// defaultMaxDescriptionLength caps composed descriptions when maxDescriptionLength is unset
+const defaultMaxDescriptionLength = 2000
+
+// Default provenance formatting, overridable in config
+const (
+	defaultCaptionSeparator = "\n\n"
+	defaultLineSeparator    = "\n"
+	defaultSourceAlbumLabel = "Source Album: "
+	defaultSharedByLabel    = "Shared by: "
+)
+
+// sourceMarkerPrefix starts the machine-readable provenance line appended to
+// asset descriptions when RecordSourceURL is enabled
+const sourceMarkerPrefix = "gp-source: "
+
+// sourceMarker builds the provenance line for a Google Photos item: its URL and
+// its ID, which stays the same when Google hands out a new media URL
+func sourceMarker(p googlephotos.Photo) string {
+	return sourceMarkerPrefix + p.URL + " id=" + p.ID
+}
+
+// parseSourceMarker extracts the Google Photos item URL and ID from a description
+// previously written with sourceMarker. The ID is "" for markers written before
+// it was recorded; both are "" when no marker is present.
+func parseSourceMarker(description string) (itemURL, id string) {
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, sourceMarkerPrefix) {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, sourceMarkerPrefix))
+		if len(fields) == 0 {
+			return "", ""
+		}
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(f, "id="); ok {
+				id = v
+			}
+		}
+		return fields[0], id
+	}
+	return "", ""
+}
+
+// truncateDescription shortens caption so that caption+footer fits in maxLen runes.
+// The footer (provenance lines) is kept intact whenever it fits, otherwise its
+// source marker line is kept if that fits, so RecordSourceURL matching still
+// works. The start is preserved and an ellipsis marks the cut.
+func truncateDescription(caption, footer string, maxLen int) (string, bool) {
+	full := caption + footer
+	if maxLen <= 0 || len([]rune(full)) <= maxLen {
+		return full, false
+	}
+
+	const ellipsis = "…"
+	head, tail := caption, footer
+	if len([]rune(footer)) >= maxLen {
+		head, tail = full, ""
+		if i := strings.LastIndex(footer, sourceMarkerPrefix); i != -1 && len([]rune(footer[i:]))+1 < maxLen {
+			head, tail = caption+footer[:i], "\n"+footer[i:]
+		}
+	}
+	room := maxLen - len([]rune(tail)) - 1
+	return strings.TrimSpace(string([]rune(head)[:room])) + ellipsis + tail, true
+}
+
+// descriptionFooter builds the provenance lines appended to an item's caption.
+// The source marker always goes on its own line so parseSourceMarker can find it.
+// quality is the URL suffix of a reduced-quality fallback download, "" for originals.
+// Returns "" when there is nothing to append, so uncaptioned items get no description.
+func (a *App) descriptionFooter(job *albumSync, p googlephotos.Photo, quality string) string {
+	captionSep := orDefault(a.Cfg.DescriptionCaptionSeparator, defaultCaptionSeparator)
+	lineSep := orDefault(a.Cfg.DescriptionLineSeparator, defaultLineSeparator)
+
+	var lines []string
+	if orTrue(a.Cfg.AddSourceFooter) {
+		lines = append(lines, orDefault(a.Cfg.SourceAlbumLabel, defaultSourceAlbumLabel)+job.Title+" ("+job.URL+")")
+	}
+	if p.Uploader != "" && orTrue(a.Cfg.AddUploaderLine) {
+		lines = append(lines, orDefault(a.Cfg.SharedByLabel, defaultSharedByLabel)+p.Uploader)
+	}
+	if quality != "" {
+		lines = append(lines, "Downloaded quality: "+strings.TrimPrefix(quality, "="))
+	}
+	footer := strings.Join(lines, lineSep)
+	if a.Cfg.RecordSourceURL {
+		if footer != "" {
+			footer += "\n"
+		}
+		footer += sourceMarker(p)
+	}
+	if footer == "" {
+		return ""
+	}
+
+	// Without a caption the footer starts with a plain line break
+	if p.Description != "" {
+		return captionSep + footer
+	}
+	return lineSep + footer
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// orTrue reads an optional flag that is on unless set to false
+func orTrue(flag *bool) bool {
+	return flag == nil || *flag
+}
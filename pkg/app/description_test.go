@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+func TestTruncateDescription(t *testing.T) {
+	const url = "https://lh3.googleusercontent.com/pw/abc"
+	marker := sourceMarker(googlephotos.Photo{ID: "item1", URL: url})
+	footer := "\n\nSource Album: Trip (https://photos.app.goo.gl/x)\nShared by: Alice"
+	withMarker := footer + "\n" + marker
+	caption := strings.Repeat("Sunset über dem Meer. ", 10)
+
+	tests := []struct {
+		name       string
+		caption    string
+		footer     string
+		maxLen     int
+		truncated  bool
+		keepFooter bool // Whole footer at the end
+		keepMarker bool // Source marker still parseable
+	}{
+		{"fits", "Beach", footer, 200, false, true, false},
+		{"unlimited", caption, withMarker, 0, false, true, true},
+		{"caption cut", caption, footer, 100, true, true, false},
+		{"caption cut, marker", caption, withMarker, 160, true, true, true},
+		{"footer too long", caption, footer, 40, true, false, false},
+		{"footer too long, marker kept", caption, withMarker, 80, true, false, true},
+		{"marker too long", caption, withMarker, 30, true, false, false},
+		{"no caption", "", withMarker, 70, true, false, true},
+		{"exactly the footer", caption, footer, utf8.RuneCountInString(footer), true, false, false},
+		{"one rune", caption, withMarker, 1, true, false, false},
+	}
+	for _, tt := range tests {
+		got, truncated := truncateDescription(tt.caption, tt.footer, tt.maxLen)
+		if n := utf8.RuneCountInString(got); tt.maxLen > 0 && n > tt.maxLen {
+			t.Errorf("%s: %d runes, want at most %d: %q", tt.name, n, tt.maxLen, got)
+		}
+		if truncated != tt.truncated {
+			t.Errorf("%s: truncated = %v, want %v", tt.name, truncated, tt.truncated)
+		}
+		if truncated && !strings.Contains(got, "…") {
+			t.Errorf("%s: cut without an ellipsis: %q", tt.name, got)
+		}
+		if kept := strings.HasSuffix(got, tt.footer); kept != tt.keepFooter {
+			t.Errorf("%s: footer kept: %v, want %v: %q", tt.name, kept, tt.keepFooter, got)
+		}
+		if src, id := parseSourceMarker(got); (src == url && id == "item1") != tt.keepMarker {
+			t.Errorf("%s: source marker kept: %v, want %v: %q", tt.name, !tt.keepMarker, tt.keepMarker, got)
+		}
+		if tt.caption != "" && tt.maxLen > 1 && !strings.HasPrefix(got, tt.caption[:5]) {
+			t.Errorf("%s: caption start lost: %q", tt.name, got)
+		}
+	}
+}
+
+func TestParseSourceMarker(t *testing.T) {
+	tests := []struct {
+		description string
+		url, id     string
+	}{
+		{"Beach\n\nSource Album: Trip\ngp-source: https://lh3.googleusercontent.com/pw/a id=AF1Qip1", "https://lh3.googleusercontent.com/pw/a", "AF1Qip1"},
+		{"gp-source: https://lh3.googleusercontent.com/pw/a", "https://lh3.googleusercontent.com/pw/a", ""}, // Written before IDs were recorded
+		{"  gp-source: https://lh3.googleusercontent.com/pw/a   id=AF1Qip1  ", "https://lh3.googleusercontent.com/pw/a", "AF1Qip1"},
+		{"Mentions gp-source: inline", "", ""},
+		{"gp-source: ", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if url, id := parseSourceMarker(tt.description); url != tt.url || id != tt.id {
+			t.Errorf("parseSourceMarker(%q) = %q, %q, want %q, %q", tt.description, url, id, tt.url, tt.id)
+		}
+	}
+}
+
+func TestSharedByLineInUploads(t *testing.T) {
+	items := []fakeItem{
+		{ID: "jane1", Width: 400, Height: 300, Extra: contributor("101", "Jane Doe")},
+		{ID: "owner1", Width: 400, Height: 300},
+	}
+	on, off := true, false
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string // Line expected in jane1's description, "" for none
+	}{
+		{"default label", config.Config{}, "Shared by: Jane Doe"},
+		{"custom label", config.Config{SharedByLabel: "Added by "}, "Added by Jane Doe"},
+		{"enabled", config.Config{AddUploaderLine: &on}, "Shared by: Jane Doe"},
+		{"disabled", config.Config{AddUploaderLine: &off}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", items...)
+			im := newFakeImmich(t)
+			cfg := tt.cfg
+			a := newTestApp(t, &cfg, im)
+			if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+				t.Fatal(err)
+			}
+
+			descs := make(map[string]string)
+			for _, up := range im.uploads() {
+				descs[up.Name] = up.Description
+			}
+			if len(descs) != 2 {
+				t.Fatalf("uploaded %d items, want 2", len(descs))
+			}
+			jane := strings.Split(descs["gp_jane1.jpg"], "\n")
+			if tt.want != "" && !contains(jane, tt.want) {
+				t.Errorf("description %q has no %q line", descs["gp_jane1.jpg"], tt.want)
+			}
+			for name, desc := range descs {
+				if strings.Contains(desc, "Jane Doe") && (tt.want == "" || name != "gp_jane1.jpg") {
+					t.Errorf("%s: description %q names the contributor", name, desc)
+				}
+			}
+		})
+	}
+}
+
+func TestDescriptionFooter(t *testing.T) {
+	const (
+		src = "Source Album: Trip (https://photos.app.goo.gl/x)"
+		by  = "Shared by: Jane"
+	)
+	tests := []struct {
+		caption, uploader          string
+		addSource, addUploaderLine bool
+		want                       string // Composed description
+	}{
+		{"Beach", "Jane", true, true, "Beach\n\n" + src + "\n" + by},
+		{"Beach", "Jane", false, true, "Beach\n\n" + by},
+		{"Beach", "Jane", true, false, "Beach\n\n" + src},
+		{"Beach", "Jane", false, false, "Beach"},
+		{"Beach", "", true, true, "Beach\n\n" + src},
+		{"Beach", "", false, true, "Beach"},
+		{"Beach", "", true, false, "Beach\n\n" + src},
+		{"Beach", "", false, false, "Beach"},
+		{"", "Jane", true, true, "\n" + src + "\n" + by},
+		{"", "Jane", false, true, "\n" + by},
+		{"", "Jane", true, false, "\n" + src},
+		{"", "Jane", false, false, ""},
+		{"", "", true, true, "\n" + src},
+		{"", "", false, true, ""},
+		{"", "", true, false, "\n" + src},
+		{"", "", false, false, ""},
+	}
+	job := &albumSync{Title: "Trip", URL: "https://photos.app.goo.gl/x"}
+	for _, tt := range tests {
+		a := &App{Cfg: &config.Config{AddSourceFooter: &tt.addSource, AddUploaderLine: &tt.addUploaderLine}}
+		p := googlephotos.Photo{Description: tt.caption, Uploader: tt.uploader}
+		got, _ := truncateDescription(p.Description, a.descriptionFooter(job, p, ""), defaultMaxDescriptionLength)
+		if got != tt.want {
+			t.Errorf("caption %q, uploader %q, addSourceFooter %v, addUploaderLine %v: description %q, want %q",
+				tt.caption, tt.uploader, tt.addSource, tt.addUploaderLine, got, tt.want)
+		}
+	}
+}
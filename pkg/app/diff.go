@@ -0,0 +1,188 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+// albumDiff is the read-only comparison between a Google album and its Immich album
+type albumDiff struct {
+	Title        string
+	AlbumID      string               // Immich album ID, empty if the album doesn't exist yet
+	GoogleCount  int                  // Items scraped from Google that pass the album's filters
+	SkippedCount int                  // Of those, items a sync leaves out (restricted, deleted, ...)
+	ImmichCount  int                  // Assets in the Immich album matched to an item or imported by this tool
+	OtherCount   int                  // Assets in the Immich album not imported by this tool
+	OnlyInGoogle []googlephotos.Photo // In Google, not in Immich
+	OnlyInImmich []string             // Immich asset names with no matching Google item
+}
+
+// Diff scrapes every configured album and compares it with the matching Immich
+// album without uploading, adding or deleting anything.
+func (a *App) Diff() error {
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		return fmt.Errorf("error fetching Immich albums: %w", err)
+	}
+
+	var failedAlbums int
+	for _, ac := range a.Cfg.GooglePhotos {
+		d, err := a.diffAlbum(ac, albumCache)
+		if err != nil {
+			a.Logger.Error("Error comparing album", "album_url", ac.URL, "error", err)
+			failedAlbums++
+			continue
+		}
+		printDiff(ac.URL, d)
+	}
+
+	if failedAlbums > 0 {
+		return fmt.Errorf("%d album(s) could not be compared", failedAlbums)
+	}
+	return nil
+}
+
+func (a *App) diffAlbum(ac config.GooglePhotosConfig, albumCache []immich.Album) (*albumDiff, error) {
+	album, err := googlephotos.ScrapeAlbum(a.GPClient, ac.URL)
+	if err != nil {
+		return nil, err
+	}
+	d := &albumDiff{Title: album.Title}
+	if ac.RawTitle && album.RawTitle != "" {
+		d.Title = album.RawTitle
+	}
+	if ac.AlbumName != "" {
+		d.Title = ac.AlbumName
+	}
+
+	// Compare against what a sync would upload, not everything Google lists
+	logger := a.Logger.With("album", d.Title)
+	photos := selectItems(uniqueItems(album.Photos, logger), ac, logger)
+	d.GoogleCount = len(photos)
+
+	d.AlbumID = ac.ImmichAlbumID
+	if d.AlbumID == "" {
+		d.AlbumID = a.mappedAlbum(ac.URL, albumCache, a.Logger)
+	}
+	if d.AlbumID == "" {
+		d.AlbumID = a.albumByTitle(ac.URL, d.Title, albumCache, logger)
+	}
+	if d.AlbumID == "" {
+		for _, p := range photos {
+			if a.skipsItem(ac, p) {
+				d.SkippedCount++
+			} else {
+				d.OnlyInGoogle = append(d.OnlyInGoogle, p)
+			}
+		}
+		return d, nil
+	}
+
+	details, err := a.Client.GetAlbum(d.AlbumID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching Immich album: %w", err)
+	}
+
+	albumKey := album.MediaKey
+	if albumKey == "" {
+		albumKey = ac.URL
+	}
+
+	// Match items the way processItem does for dedup, including assets found by
+	// checksum or source URL that carry another name
+	index := a.indexAlbumAssets(details, photos)
+	inAlbum := make(map[string]bool, len(details.Assets))
+	for _, asset := range details.Assets {
+		inAlbum[asset.Id] = true
+	}
+	matched := make(map[string]bool, len(photos))
+	for _, p := range photos {
+		id, ok := lookupAsset(index, albumKey, p)
+		if !ok {
+			if id, ok = a.State.Processed(ac.URL, p.ID); ok && !inAlbum[id] {
+				ok = false
+			}
+		}
+		if ok {
+			matched[id] = true
+			continue
+		}
+		if a.skipsItem(ac, p) {
+			d.SkippedCount++
+			continue
+		}
+		d.OnlyInGoogle = append(d.OnlyInGoogle, p)
+	}
+
+	for _, asset := range details.Assets {
+		name := asset.OriginalFileName
+		if dot := strings.LastIndex(name, "."); dot != -1 {
+			name = name[:dot]
+		}
+		// The video half of a motion photo has no item of its own
+		if strings.HasSuffix(asset.DeviceAssetId, "-motion") || strings.HasSuffix(name, "_motion") {
+			continue
+		}
+		switch {
+		case matched[asset.Id]:
+			d.ImmichCount++
+		case strings.HasPrefix(name, "gp_") || strings.HasPrefix(asset.DeviceAssetId, "gp:"):
+			d.ImmichCount++
+			d.OnlyInImmich = append(d.OnlyInImmich, asset.OriginalFileName)
+		default:
+			d.OtherCount++
+		}
+	}
+	return d, nil
+}
+
+// skipsItem reports whether a sync leaves an item out of the Immich album on
+// purpose, so its absence isn't a mismatch
+func (a *App) skipsItem(ac config.GooglePhotosConfig, p googlephotos.Photo) bool {
+	switch {
+	case a.State.Restricted(ac.URL, p.ID):
+		return true
+	case a.Cfg.RespectDeletions && a.State.Deleted(ac.URL, p.ID):
+		return true
+	case a.Cfg.SkipVideos && p.IsVideo:
+		return true
+	case a.Cfg.StrictMetadata && p.TakenAt.IsZero():
+		return true
+	}
+	return false
+}
+
+func printDiff(albumURL string, d *albumDiff) {
+	fmt.Printf("\n%s (%s)\n", d.Title, albumURL)
+	if d.AlbumID == "" {
+		fmt.Println("  Immich album:       not created yet")
+	} else {
+		fmt.Printf("  Immich album:       %s\n", d.AlbumID)
+	}
+	fmt.Printf("  Google items:       %d", d.GoogleCount)
+	if d.SkippedCount > 0 {
+		fmt.Printf(" (%d skipped by sync)", d.SkippedCount)
+	}
+	fmt.Println()
+	fmt.Printf("  Immich assets:      %d", d.ImmichCount)
+	if want := d.GoogleCount - d.SkippedCount; want != d.ImmichCount {
+		fmt.Printf(" (count mismatch: %+d)", d.ImmichCount-want)
+	}
+	fmt.Println()
+	if d.OtherCount > 0 {
+		fmt.Printf("  Other assets:       %d (not imported by this tool, ignored)\n", d.OtherCount)
+	}
+
+	fmt.Printf("  In Google, not in Immich: %d\n", len(d.OnlyInGoogle))
+	for _, p := range d.OnlyInGoogle {
+		fmt.Printf("    + %s\n", photoBaseName(p))
+	}
+	fmt.Printf("  In Immich, not in Google: %d\n", len(d.OnlyInImmich))
+	for _, name := range d.OnlyInImmich {
+		fmt.Printf("    - %s\n", name)
+	}
+}
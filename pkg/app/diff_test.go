@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+func TestDiffAlbumReportsMismatchesBothWays(t *testing.T) {
+	items := []fakeItem{
+		{ID: "itemA", Width: 400, Height: 300},
+		{ID: "itemB", Width: 400, Height: 300}, // Never uploaded
+		{ID: "itemC", Width: 400, Height: 300}, // Deduplicated onto an existing asset by checksum
+		{ID: "itemD", Width: 40, Height: 30},   // Filtered as too small
+		{ID: "itemE", Width: 400, Height: 300}, // Restricted by Google
+		{ID: "itemF", Width: 400, Height: 300}, // Deleted in Immich
+	}
+	g := newFakeGoogle(t, "Trip", items...)
+	im := newFakeImmich(t)
+	assetA := im.addAsset(fakeAsset{Name: "gp_itemA.jpg", DeviceAssetID: "gp:KEY:itemA"})
+	motionA := im.addAsset(fakeAsset{Name: "gp_itemA_motion.mp4", DeviceAssetID: "gp:KEY:itemA-motion", Type: "VIDEO"})
+	orphan := im.addAsset(fakeAsset{Name: "gp_gone.jpg", DeviceAssetID: "gp:KEY:gone"})
+	assetC := im.addAsset(fakeAsset{Name: "IMG_0001.jpg"})
+	other := im.addAsset(fakeAsset{Name: "holiday.jpg"})
+	im.addAlbum("Trip", assetA, motionA, orphan, assetC, other)
+
+	a := newTestApp(t, &config.Config{RespectDeletions: true}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL(), MinWidth: 100}
+	a.State.MarkProcessed(ac.URL, "itemC", assetC)
+	a.State.MarkRestricted(ac.URL, "itemE")
+	a.State.MarkDeleted(ac.URL, "itemF")
+
+	albums, err := a.Client.GetAlbums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := a.diffAlbum(ac, albums)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var missing []string
+	for _, p := range d.OnlyInGoogle {
+		missing = append(missing, p.ID)
+	}
+	if !reflect.DeepEqual(missing, []string{"itemB"}) {
+		t.Errorf("missing in Immich = %v, want [itemB]", missing)
+	}
+	if !reflect.DeepEqual(d.OnlyInImmich, []string{"gp_gone.jpg"}) {
+		t.Errorf("orphans = %v, want [gp_gone.jpg]", d.OnlyInImmich)
+	}
+	got := [4]int{d.GoogleCount, d.SkippedCount, d.ImmichCount, d.OtherCount}
+	if want := [4]int{5, 2, 3, 1}; got != want {
+		t.Errorf("google, skipped, immich, other = %v, want %v", got, want)
+	}
+}
+
+func TestDiffAlbumMatchesRenamedAssetBySourceURL(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", fakeItem{ID: "itemA", Width: 400, Height: 300})
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{RecordSourceURL: true}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL()}
+
+	album, err := googlephotos.ScrapeAlbum(a.GPClient, ac.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := im.addAsset(fakeAsset{Name: "renamed.jpg", Description: "Beach day\n" + sourceMarker(album.Photos[0])})
+	im.addAlbum("Trip", renamed)
+
+	albums, err := a.Client.GetAlbums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := a.diffAlbum(ac, albums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.OnlyInGoogle) != 0 || len(d.OnlyInImmich) != 0 {
+		t.Errorf("missing %d, orphans %v, want none", len(d.OnlyInGoogle), d.OnlyInImmich)
+	}
+}
+
+func TestVerifyFailsOnlyOnMissingItems(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(2)...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+	a.Cfg.GooglePhotos = []config.GooglePhotosConfig{{URL: g.albumURL()}}
+
+	// Nothing synced yet, every item is missing
+	if err := a.Verify(); err == nil {
+		t.Fatal("Verify passed before the album was synced")
+	}
+
+	if err := syncAlbum(t, context.Background(), a, a.Cfg.GooglePhotos[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Verify(); err != nil {
+		t.Fatalf("Verify after sync: %v", err)
+	}
+
+	// An item removed from Google leaves an orphan, which is reported but not fatal
+	g.setItems(testItems(1)...)
+	if err := a.Verify(); err != nil {
+		t.Fatalf("Verify with an orphan: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/exif"
+)
+
+// writeExifDate embeds takenAt into a downloaded JPEG without an EXIF capture
+// date, so Immich can't fall back to the upload time. On any problem the
+// original bytes are returned unchanged.
+func (a *App) writeExifDate(r io.ReadCloser, size int64, takenAt time.Time, job *albumSync, id string) (io.ReadCloser, int64, error) {
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading downloaded item: %w", err)
+	}
+	out, changed, err := exif.SetDateTaken(data, takenAt)
+	if err != nil {
+		job.Logger.Debug("Could not write EXIF date, uploading unchanged", "id", id, "error", err)
+		return io.NopCloser(bytes.NewReader(data)), size, nil
+	}
+	if changed {
+		job.Logger.Debug("Wrote EXIF capture date", "id", id, "taken_at", takenAt)
+	}
+	return io.NopCloser(bytes.NewReader(out)), int64(len(out)), nil
+}
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// exportItem is one entry of an exported album's metadata.json
+type exportItem struct {
+	ID          string     `json:"id"`
+	File        string     `json:"file,omitempty"`
+	TakenAt     *time.Time `json:"takenAt,omitempty"` // Nil when the date is unknown
+	Description string     `json:"description,omitempty"`
+	Uploader    string     `json:"uploader,omitempty"`
+	Latitude    float64    `json:"latitude,omitempty"`
+	Longitude   float64    `json:"longitude,omitempty"`
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	IsVideo     bool       `json:"isVideo,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// exportManifest is the metadata.json written next to the exported media
+type exportManifest struct {
+	Title      string       `json:"title"`
+	URL        string       `json:"url"`
+	ExportedAt time.Time    `json:"exportedAt"`
+	Items      []exportItem `json:"items"`
+}
+
+// Export scrapes every configured album and writes it to dir as a portable
+// bundle: one sub-directory per album with the media files and a metadata.json.
+// Files already present from a previous export are not downloaded again.
+func (a *App) Export(dir string) error {
+	var failedAlbums int
+	for _, ac := range a.Cfg.GooglePhotos {
+		logger := a.Logger.With("album_url", ac.URL)
+
+		album, err := googlephotos.ScrapeAlbum(a.GPClient, ac.URL)
+		if err != nil {
+			logger.Error("Error scraping album", "error", err)
+			failedAlbums++
+			continue
+		}
+		album.Photos = uniqueItems(album.Photos, logger)
+		title := album.Title
+		if ac.AlbumName != "" {
+			title = ac.AlbumName
+		}
+
+		albumDir := filepath.Join(dir, slugify(title))
+		if err := os.MkdirAll(albumDir, 0o755); err != nil {
+			return fmt.Errorf("error creating export directory: %w", err)
+		}
+		logger.Info("Exporting album", "title", title, "count", len(album.Photos), "dir", albumDir)
+
+		items := a.exportPhotos(album.Photos, albumDir)
+
+		exported := 0
+		for _, it := range items {
+			if it.Error == "" {
+				exported++
+			}
+		}
+
+		manifest := exportManifest{Title: title, URL: ac.URL, ExportedAt: time.Now(), Items: items}
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding metadata: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(albumDir, "metadata.json"), data, 0o644); err != nil {
+			return fmt.Errorf("error writing metadata: %w", err)
+		}
+		logger.Info("Exported album", "title", title, "exported", exported, "failed", len(items)-exported)
+	}
+
+	if failedAlbums > 0 {
+		return fmt.Errorf("%d album(s) could not be scraped", failedAlbums)
+	}
+	return nil
+}
+
+// exportPhotos downloads photos into albumDir using the configured worker count.
+// Items keep the scraped order in the returned slice.
+func (a *App) exportPhotos(photos []googlephotos.Photo, albumDir string) []exportItem {
+	items := make([]exportItem, len(photos))
+
+	numWorkers := a.Cfg.Workers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				items[i] = a.exportPhoto(photos[i], albumDir)
+			}
+		}()
+	}
+	for i := range photos {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return items
+}
+
+func (a *App) exportPhoto(p googlephotos.Photo, albumDir string) exportItem {
+	item := exportItem{
+		ID:          p.ID,
+		Description: p.Description,
+		Uploader:    p.Uploader,
+		Latitude:    p.Latitude,
+		Longitude:   p.Longitude,
+		Width:       p.Width,
+		Height:      p.Height,
+	}
+	if !p.TakenAt.IsZero() {
+		item.TakenAt = &p.TakenAt
+	}
+	baseName := photoBaseName(p)
+
+	// Resume: keep files from a previous export
+	matches, _ := filepath.Glob(filepath.Join(albumDir, baseName+".*"))
+	for _, m := range matches {
+		if !strings.HasSuffix(m, ".part") {
+			item.File = filepath.Base(m)
+			return item
+		}
+	}
+
+	r, _, ext, isVideo, err := googlephotos.DownloadMedia(a.GPClient, p.URL, p.IsVideo)
+	if err != nil {
+		a.Logger.Error("Failed to download item", "id", p.ID, "error", err)
+		item.Error = err.Error()
+		return item
+	}
+	defer r.Close()
+
+	item.File = baseName + ext
+	item.IsVideo = isVideo
+	if err := writeFile(filepath.Join(albumDir, item.File), r); err != nil {
+		a.Logger.Error("Failed to write item", "id", p.ID, "error", err)
+		item.File = ""
+		item.Error = err.Error()
+		return item
+	}
+	if !p.TakenAt.IsZero() {
+		// Mirror the taken date on the file for tools that sort by mtime
+		_ = os.Chtimes(filepath.Join(albumDir, item.File), p.TakenAt, p.TakenAt)
+	}
+	a.Logger.Debug("Exported item", "id", p.ID, "file", item.File)
+	return item
+}
+
+// writeFile writes r to path via a temp file so interrupted exports leave no partial media
+func writeFile(path string, r io.Reader) error {
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// slugify turns an album title into a safe directory/file name
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		slug = "album"
+	}
+	return slug
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+func TestExportOmitsUnknownDates(t *testing.T) {
+	g := newFakeGoogle(t, "Trip",
+		fakeItem{ID: "dated", Width: 400, Height: 300, TakenAt: 1704065400000, TZ: 9 * 3600 * 1000},
+		fakeItem{ID: "undated", Width: 400, Height: 300},
+	)
+	a := newTestApp(t, &config.Config{}, nil)
+	a.Cfg.GooglePhotos = []config.GooglePhotosConfig{{URL: g.albumURL()}}
+	dir := t.TempDir()
+
+	if err := a.Export(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "trip", "metadata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	takenAt := map[string]interface{}{}
+	for _, it := range manifest.Items {
+		if v, ok := it["takenAt"]; ok {
+			takenAt[it["id"].(string)] = v
+		}
+	}
+	want := map[string]interface{}{"dated": "2024-01-01T08:30:00+09:00"}
+	if len(takenAt) != 1 || takenAt["dated"] != want["dated"] {
+		t.Errorf("takenAt in metadata.json = %v, want %v", takenAt, want)
+	}
+}
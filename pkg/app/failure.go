@@ -0,0 +1,72 @@
+package app
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+)
+
+// Categories of items that didn't make it into Immich, reported in the album summary
+const (
+	categoryDownload = "download_failed"   // Google download or reading the downloaded bytes
+	categoryUpload   = "upload_failed"     // Immich rejected or didn't answer the upload
+	categoryMetadata = "metadata_skipped"  // Skipped (or quarantined) for a missing date with strictMetadata
+	categoryTooLarge = "too_large_skipped" // Video above maxVideoBytes
+	categoryOther    = "other_failed"
+)
+
+// errMissingMetadata marks items skipped by strictMetadata. Counted as skipped, not failed.
+var errMissingMetadata = errors.New("item has no date")
+
+// itemError tags a processItem error with its summary category
+type itemError struct {
+	category string
+	err      error
+}
+
+func (e *itemError) Error() string { return e.err.Error() }
+func (e *itemError) Unwrap() error { return e.err }
+
+// categorize tags err with a summary category; nil stays nil
+func categorize(category string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &itemError{category: category, err: err}
+}
+
+// failureCategory returns the summary category of a processItem error
+func failureCategory(err error) string {
+	var ie *itemError
+	if errors.As(err, &ie) {
+		return ie.category
+	}
+	return categoryOther
+}
+
+// skipCategory reports whether items of a category count as skipped, not failed
+func skipCategory(category string) bool {
+	return category == categoryMetadata || category == categoryTooLarge
+}
+
+// resultCategory is the processResult category for a processItem error
+func resultCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+	return failureCategory(err)
+}
+
+// categoryAttrs turns category counts into sorted log attributes
+func categoryAttrs(counts map[string]int) []any {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Int(k, counts[k]))
+	}
+	return attrs
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+	"warreth.dev/immich-sync/pkg/metrics"
+)
+
+func TestFailureCategory(t *testing.T) {
+	base := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"no error", nil, ""},
+		{"uncategorized", base, categoryOther},
+		{"download", categorize(categoryDownload, base), categoryDownload},
+		{"wrapped upload", fmt.Errorf("uploading: %w", categorize(categoryUpload, base)), categoryUpload},
+		{"metadata", categorize(categoryMetadata, errMissingMetadata), categoryMetadata},
+		{"too large", categorize(categoryTooLarge, base), categoryTooLarge},
+	}
+	for _, tt := range tests {
+		if got := resultCategory(tt.err); got != tt.want {
+			t.Errorf("%s: category %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.err != nil && !errors.Is(tt.err, base) && !errors.Is(tt.err, errMissingMetadata) {
+			t.Errorf("%s: categorized error no longer wraps the cause", tt.name)
+		}
+	}
+	if categorize(categoryDownload, nil) != nil {
+		t.Error("categorize(nil) isn't nil")
+	}
+	for _, c := range []string{categoryMetadata, categoryTooLarge} {
+		if !skipCategory(c) {
+			t.Errorf("%s doesn't count as skipped", c)
+		}
+	}
+	if skipCategory(categoryDownload) {
+		t.Errorf("%s counts as skipped", categoryDownload)
+	}
+}
+
+func TestSyncSummaryFailureCounts(t *testing.T) {
+	items := testItems(8)
+	items[6].TakenAt = 0                                                                 // Skipped by strictMetadata
+	items[7].Body, items[7].Type = append(bytes.Clone(mp4Bytes), "long"...), "video/mp4" // Above maxVideoBytes
+	g := newFakeGoogle(t, "Trip", items...)
+	for _, id := range []string{"item01", "item02"} {
+		g.status[id] = []int{500, 500, 500, 500}
+	}
+	g.status["item03"] = []int{http.StatusNotFound, http.StatusNotFound}
+	im := newFakeImmich(t)
+	im.rejects["gp_item04.jpg"] = http.StatusBadRequest
+
+	cfg := &config.Config{Workers: 2, StrictMetadata: true}
+	a := newTestApp(t, cfg, im)
+	a.GPClient = googlephotos.NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), googlephotos.Options{
+		MinDelay:          -1,
+		Jitter:            -1,
+		RequestsPerSecond: -1,
+		MaxRetries:        1,
+		DownloadRetries:   -1,
+		MaxVideoBytes:     int64(len(mp4Bytes)),
+	})
+	prom := metrics.NewPrometheus(defaultStatsDPrefix)
+	a.Metrics = prom
+	var buf bytes.Buffer
+	a.Logger = newLogger(&buf, cfg)
+	syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()})
+
+	var summary map[string]interface{}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, `"msg":"Sync finished"`) {
+			if err := json.Unmarshal([]byte(line), &summary); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if summary == nil {
+		t.Fatalf("no Sync finished line in\n%s", buf.String())
+	}
+	want := map[string]float64{
+		"added":             2, // item00 and item05
+		"skipped":           2,
+		"failed":            4,
+		"total":             8,
+		"download_failed":   3,
+		"upload_failed":     1,
+		"metadata_skipped":  1,
+		"too_large_skipped": 1,
+	}
+	for k, v := range want {
+		if summary[k] != v {
+			t.Errorf("%s = %v, want %v", k, summary[k], v)
+		}
+	}
+	if _, ok := summary[categoryOther]; ok {
+		t.Errorf("summary reports %s=%v, want every failure classified", categoryOther, summary[categoryOther])
+	}
+	if v := scrapeMetrics(t, prom)[`immich_sync_assets_skipped_too_large_total{album="Trip"}`]; v != "1" {
+		t.Errorf("skipped too large metric = %q, want 1", v)
+	}
+}
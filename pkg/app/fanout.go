@@ -0,0 +1,61 @@
+package app
+
+import (
+	"log/slog"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+// resolveExtraAlbums maps the configured alsoAddTo entries (Immich album IDs or
+// names) to album IDs. Missing names are created unless NoCreate is set. A nil
+// albumCache means the album list couldn't be fetched: nothing is resolved then,
+// as every target would look missing and be created again on each run.
+func (a *App) resolveExtraAlbums(ac config.GooglePhotosConfig, albumCache []immich.Album, logger *slog.Logger) []string {
+	if albumCache == nil && len(ac.AlsoAddTo) > 0 {
+		logger.Warn("Immich album list unavailable, skipping additional albums this run", "albums", len(ac.AlsoAddTo))
+		return nil
+	}
+	var ids []string
+	for _, target := range ac.AlsoAddTo {
+		id := ""
+		for _, alb := range albumCache {
+			if alb.Id == target || alb.AlbumName == target {
+				id = alb.Id
+				break
+			}
+		}
+		if id == "" && ac.NoCreate {
+			logger.Warn("Additional Immich album not found and noCreate is set, skipping it", "album", target)
+			continue
+		}
+		if id == "" {
+			logger.Info("Creating additional Immich album", "title", target)
+			created, err := a.Client.CreateAlbum(target, "")
+			if err != nil {
+				logger.Error("Error creating additional album", "album", target, "error", err)
+				continue
+			}
+			id = created.Id
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// fanOut adds the album's assets to every additional target album. Items are
+// downloaded and uploaded once for the main album; the targets only get the IDs.
+func (a *App) fanOut(targets []string, assetIds []string, logger *slog.Logger) {
+	if len(assetIds) == 0 {
+		return
+	}
+	for _, target := range targets {
+		res, err := a.Client.AddAssetsToAlbum(target, assetIds)
+		added := logAlbumAdd(logger, res)
+		if err != nil {
+			logger.Error("Error adding assets to additional album", "album_id", target, "error", err)
+			continue
+		}
+		logger.Info("Added assets to additional album", "album_id", target, "added", added, "already_present", res.Duplicates)
+	}
+}
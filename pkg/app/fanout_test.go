@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+func TestFanOutTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		listed    bool // Whether the Immich album list could be fetched, the main album is known from the state file
+		noCreate  bool
+		wantAdded map[string]int // Album name -> assets after the sync
+	}{
+		{"created and filled", true, false, map[string]int{"Trip": 1, "Family": 1, "Favorites": 1}},
+		{"noCreate", true, true, map[string]int{"Trip": 1, "Family": 1}},
+		{"album list unavailable", false, false, map[string]int{"Trip": 1, "Family": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", fakeItem{ID: "a", Width: 400, Height: 300})
+			im := newFakeImmich(t)
+			tripID := im.addAlbum("Trip")
+			familyID := im.addAlbum("Family")
+			a := newTestApp(t, nil, im)
+			a.GPClient = g.contentClient()
+			a.State.UpdateAlbum(g.albumURL(), func(st *state.AlbumState) { st.ImmichAlbumID = tripID })
+
+			var albumCache []immich.Album
+			if tt.listed {
+				var err error
+				if albumCache, err = a.Client.GetAlbums(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ac := config.GooglePhotosConfig{URL: g.albumURL(), AlsoAddTo: []string{familyID, "Favorites"}, NoCreate: tt.noCreate}
+			if err := a.processAlbum(context.Background(), ac, albumCache); err != nil {
+				t.Fatal(err)
+			}
+
+			if n := im.albumCount(); n != len(tt.wantAdded) {
+				t.Errorf("%d albums in Immich, want %d", n, len(tt.wantAdded))
+			}
+			for name, want := range tt.wantAdded {
+				id := im.albumNamed(name)
+				if id == "" {
+					t.Errorf("no %q album", name)
+					continue
+				}
+				if got := len(im.album(id).Assets); got != want {
+					t.Errorf("%q holds %d assets, want %d", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// selectItems applies the album's filters, variant collapsing and item cap, in the
+// order the sync uses them, and logs what each step removed
+func selectItems(photos []googlephotos.Photo, ac config.GooglePhotosConfig, logger *slog.Logger) []googlephotos.Photo {
+	if kept, filtered := filterByAspectRatio(photos, ac); filtered > 0 {
+		logger.Info("Filtered items by aspect ratio", "filtered", filtered, "remaining", len(kept),
+			"min", ac.MinAspectRatio, "max", ac.MaxAspectRatio)
+		photos = kept
+	}
+
+	if kept, filtered := filterBySize(photos, ac); filtered > 0 {
+		logger.Info("Filtered items as too small", "filtered", filtered, "remaining", len(kept),
+			"min_width", ac.MinWidth, "min_height", ac.MinHeight)
+		photos = kept
+	}
+
+	if kept, filtered := filterByDate(photos, ac); filtered > 0 {
+		logger.Info("Filtered items by date range", "filtered", filtered, "remaining", len(kept),
+			"start", ac.StartDate, "end", ac.EndDate)
+		photos = kept
+	}
+
+	if kept, filtered := filterByUploader(photos, ac); filtered > 0 {
+		logger.Info("Filtered items by uploader", "filtered", filtered, "remaining", len(kept),
+			"uploaders", strings.Join(ac.OnlyUploaders, ", "))
+		photos = kept
+	}
+
+	if kept, collapsed := collapseVariants(photos, ac.PreferVariant); collapsed > 0 {
+		logger.Info("Collapsed original/edited variants", "collapsed", collapsed, "remaining", len(kept),
+			"prefer", ac.PreferVariant)
+		photos = kept
+	}
+
+	if ac.MaxItems > 0 && len(photos) > ac.MaxItems {
+		logger.Info("Limited items", "max_items", ac.MaxItems, "total", len(photos))
+		photos = photos[:ac.MaxItems]
+	}
+	return photos
+}
+
+// filterByAspectRatio drops photos whose width/height ratio falls outside the
+// configured range. Returns the kept photos and how many were filtered.
+func filterByAspectRatio(photos []googlephotos.Photo, ac config.GooglePhotosConfig) ([]googlephotos.Photo, int) {
+	if ac.MinAspectRatio <= 0 && ac.MaxAspectRatio <= 0 {
+		return photos, 0
+	}
+
+	kept := make([]googlephotos.Photo, 0, len(photos))
+	for _, p := range photos {
+		if p.Width <= 0 || p.Height <= 0 {
+			if !ac.ExcludeUnknownAspect {
+				kept = append(kept, p)
+			}
+			continue
+		}
+		ratio := float64(p.Width) / float64(p.Height)
+		if ac.MinAspectRatio > 0 && ratio < ac.MinAspectRatio {
+			continue
+		}
+		if ac.MaxAspectRatio > 0 && ratio > ac.MaxAspectRatio {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, len(photos) - len(kept)
+}
+
+// filterBySize drops photos smaller than the configured minimum width or height.
+// Photos exactly at the threshold are kept. Photos with missing dimensions are
+// kept unless ExcludeUnknownSize is set. Returns the kept photos and how many were filtered.
+func filterBySize(photos []googlephotos.Photo, ac config.GooglePhotosConfig) ([]googlephotos.Photo, int) {
+	if ac.MinWidth <= 0 && ac.MinHeight <= 0 {
+		return photos, 0
+	}
+
+	kept := make([]googlephotos.Photo, 0, len(photos))
+	for _, p := range photos {
+		if p.Width <= 0 || p.Height <= 0 {
+			if !ac.ExcludeUnknownSize {
+				kept = append(kept, p)
+			}
+			continue
+		}
+		if p.Width < ac.MinWidth || p.Height < ac.MinHeight {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, len(photos) - len(kept)
+}
+
+// filterByDate drops photos taken outside the album's startDate/endDate. Photos
+// without a date are kept unless ExcludeUnknownDate is set. Returns the kept
+// photos and how many were filtered.
+func filterByDate(photos []googlephotos.Photo, ac config.GooglePhotosConfig) ([]googlephotos.Photo, int) {
+	start, end, err := ac.DateRange()
+	if err != nil || (start.IsZero() && end.IsZero()) {
+		return photos, 0 // Invalid ranges are rejected by Config.Validate
+	}
+
+	kept := make([]googlephotos.Photo, 0, len(photos))
+	for _, p := range photos {
+		if p.TakenAt.IsZero() {
+			if !ac.ExcludeUnknownDate {
+				kept = append(kept, p)
+			}
+			continue
+		}
+		if !start.IsZero() && p.TakenAt.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !p.TakenAt.Before(end) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, len(photos) - len(kept)
+}
+
+// filterByUploader keeps only photos added by one of the configured contributors
+// (case-insensitive). Photos without a known uploader are kept unless
+// ExcludeUnknownUploader is set. Returns the kept photos and how many were filtered.
+func filterByUploader(photos []googlephotos.Photo, ac config.GooglePhotosConfig) ([]googlephotos.Photo, int) {
+	if len(ac.OnlyUploaders) == 0 {
+		return photos, 0
+	}
+
+	allowed := make(map[string]bool, len(ac.OnlyUploaders))
+	for _, u := range ac.OnlyUploaders {
+		allowed[strings.ToLower(strings.TrimSpace(u))] = true
+	}
+
+	kept := make([]googlephotos.Photo, 0, len(photos))
+	for _, p := range photos {
+		if p.Uploader == "" {
+			if !ac.ExcludeUnknownUploader {
+				kept = append(kept, p)
+			}
+			continue
+		}
+		if allowed[strings.ToLower(p.Uploader)] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, len(photos) - len(kept)
+}
+
+// collapseVariants keeps a single copy of photos that appear twice because of an edit.
+//
+// The shared album data has no explicit link between an original and its edited
+// copy. When an edit is saved as a copy, Google lists it as a separate item with
+// its own ID and URL, but it keeps the original capture timestamp to the
+// millisecond and is listed after the original. Two items sharing the exact same
+// capture time are therefore treated as original (first) and edited (second), as
+// long as they also look alike: both images or both videos, with the same
+// dimensions (or swapped, for a rotation) or filenames where one stem extends the
+// other (IMG_1234.jpg and IMG_1234-edited.jpg). A crop with an unknown filename is
+// thus kept twice rather than risk dropping an unrelated shot. Groups of any other
+// size, such as burst shots, and items without a timestamp are left untouched.
+//
+// prefer is "original" or "edited"; any other value disables collapsing.
+// Returns the kept photos and how many were dropped.
+func collapseVariants(photos []googlephotos.Photo, prefer string) ([]googlephotos.Photo, int) {
+	prefer = strings.ToLower(strings.TrimSpace(prefer))
+	if prefer != "original" && prefer != "edited" {
+		return photos, 0
+	}
+
+	groups := make(map[int64][]int)
+	for i, p := range photos {
+		if !p.TakenAt.IsZero() {
+			ms := p.TakenAt.UnixMilli()
+			groups[ms] = append(groups[ms], i)
+		}
+	}
+
+	drop := make(map[int]bool)
+	for _, idx := range groups {
+		if len(idx) != 2 || !sameShot(photos[idx[0]], photos[idx[1]]) {
+			continue
+		}
+		if prefer == "original" {
+			drop[idx[1]] = true
+		} else {
+			drop[idx[0]] = true
+		}
+	}
+	if len(drop) == 0 {
+		return photos, 0
+	}
+
+	kept := make([]googlephotos.Photo, 0, len(photos)-len(drop))
+	for i, p := range photos {
+		if !drop[i] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, len(drop)
+}
+
+// sameShot reports whether two items with the same capture time look like an
+// original and its edited copy (see collapseVariants)
+func sameShot(a, b googlephotos.Photo) bool {
+	if a.IsVideo != b.IsVideo {
+		return false // e.g. the halves of a live photo
+	}
+	if a.Width > 0 && a.Height > 0 &&
+		(a.Width == b.Width && a.Height == b.Height || a.Width == b.Height && a.Height == b.Width) {
+		return true
+	}
+	stemA, stemB := fileStem(a.FileName), fileStem(b.FileName)
+	return stemA != "" && stemB != "" && (strings.HasPrefix(stemA, stemB) || strings.HasPrefix(stemB, stemA))
+}
+
+// filterHash fingerprints every setting that decides which of an album's items
+// are synced: the filters above, the item cap and the global video and metadata
+// skips. The quick checks compare it with the last sync's, since the same album
+// item count means nothing once the selection changed.
+func (a *App) filterHash(ac config.GooglePhotosConfig) string {
+	key, _ := json.Marshal([]interface{}{
+		ac.MinAspectRatio, ac.MaxAspectRatio, ac.ExcludeUnknownAspect,
+		ac.MinWidth, ac.MinHeight, ac.ExcludeUnknownSize,
+		ac.StartDate, ac.EndDate, ac.ExcludeUnknownDate,
+		ac.OnlyUploaders, ac.ExcludeUnknownUploader,
+		ac.PreferVariant, ac.MaxItems,
+		a.Cfg.SkipVideos, a.Cfg.StrictMetadata,
+	})
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
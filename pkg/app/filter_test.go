@@ -0,0 +1,365 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+func TestFilterHash(t *testing.T) {
+	a := &App{Cfg: &config.Config{}}
+	base := config.GooglePhotosConfig{URL: "https://photos.app.goo.gl/x", MaxItems: 10}
+	want := a.filterHash(base)
+
+	changed := map[string]func(ac *config.GooglePhotosConfig){
+		"minAspectRatio":         func(ac *config.GooglePhotosConfig) { ac.MinAspectRatio = 1 },
+		"excludeUnknownAspect":   func(ac *config.GooglePhotosConfig) { ac.ExcludeUnknownAspect = true },
+		"minWidth":               func(ac *config.GooglePhotosConfig) { ac.MinWidth = 800 },
+		"minHeight":              func(ac *config.GooglePhotosConfig) { ac.MinHeight = 600 },
+		"startDate":              func(ac *config.GooglePhotosConfig) { ac.StartDate = "2024-01-01" },
+		"endDate":                func(ac *config.GooglePhotosConfig) { ac.EndDate = "2024-12-31" },
+		"onlyUploaders":          func(ac *config.GooglePhotosConfig) { ac.OnlyUploaders = []string{"Ann"} },
+		"excludeUnknownUploader": func(ac *config.GooglePhotosConfig) { ac.ExcludeUnknownUploader = true },
+		"preferVariant":          func(ac *config.GooglePhotosConfig) { ac.PreferVariant = "edited" },
+		"maxItems":               func(ac *config.GooglePhotosConfig) { ac.MaxItems = 0 },
+	}
+	for name, change := range changed {
+		ac := base
+		change(&ac)
+		if a.filterHash(ac) == want {
+			t.Errorf("changing %s keeps the filter hash", name)
+		}
+	}
+
+	// Settings that don't select items leave it alone
+	ac := base
+	ac.Tags, ac.AlbumName, ac.SyncInterval = []string{"trip"}, "Other", "1h"
+	if a.filterHash(ac) != want {
+		t.Error("settings unrelated to item selection change the filter hash")
+	}
+
+	a.Cfg.SkipVideos = true
+	if a.filterHash(base) == want {
+		t.Error("changing skipVideos keeps the filter hash")
+	}
+}
+
+func TestQuickCheckSkipsUnchangedAlbumUntilFiltersChange(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(5)...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{QuickCheck: true}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL(), MaxItems: 2}
+
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != 2 {
+		t.Fatalf("first run uploaded %d items, want 2", n)
+	}
+
+	// Second run with the same settings: nothing to do, no media requests
+	gets, heads := g.mediaGets(), g.headCount()
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if g.mediaGets() != gets || g.headCount() != heads || len(im.uploads()) != 2 {
+		t.Errorf("unchanged second run touched media (%d GETs, %d HEADs) or uploaded", g.mediaGets()-gets, g.headCount()-heads)
+	}
+
+	// Lifting the cap selects more items from the same, unchanged album
+	ac.MaxItems = 0
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != 5 {
+		t.Errorf("run after lifting maxItems uploaded %d items in total, want 5", n)
+	}
+}
+
+func TestDeletedItemStaysDeletedWhileFilteredOut(t *testing.T) {
+	items := testItems(3)
+	items[1].Width, items[1].Height = 100, 100
+	g := newFakeGoogle(t, "Trip", items...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{RespectDeletions: true}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL()}
+
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	albumID := im.albumNamed("Trip")
+	deletedAsset, _ := a.State.Processed(ac.URL, "item01")
+	im.removeFromAlbum(albumID, deletedAsset)
+
+	// Present -> deleted: the removal is noticed and recorded
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if !a.State.Deleted(ac.URL, "item01") {
+		t.Fatal("removal from the Immich album not recorded")
+	}
+
+	// A filter hides the item for a run; the deletion must not be forgotten
+	ac.MinWidth = 200
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if !a.State.Deleted(ac.URL, "item01") {
+		t.Fatal("deletion forgotten while the item was filtered out")
+	}
+
+	// Deleted -> skipped once the filter is gone again
+	ac.MinWidth = 0
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if contains(im.album(albumID).Assets, deletedAsset) {
+		t.Error("deleted item was added back to the album")
+	}
+	if n := len(im.album(albumID).Assets); n != 2 {
+		t.Errorf("album has %d assets, want 2", n)
+	}
+}
+
+// contributor is the page-data array naming who added an item in a shared album
+func contributor(actorID, name string) string {
+	return fmt.Sprintf(`[null,[%q,%q,"https://lh3.googleusercontent.com/a/ACg8oc%s=s64-c"]]`, actorID, name, actorID)
+}
+
+func TestOnlyUploadersFiltersScrapedContributors(t *testing.T) {
+	items := []fakeItem{
+		{ID: "ann1", Extra: contributor("101", "Ann Lee")},
+		{ID: "bob1", Extra: contributor("102", "Bob Stone")},
+		{ID: "ann2", Extra: contributor("101", "Ann Lee")},
+		{ID: "unknown"},
+	}
+	for i := range items {
+		items[i].Width, items[i].Height = 400, 300
+	}
+
+	tests := []struct {
+		name    string
+		exclude bool
+		want    []string
+	}{
+		{"unknown kept", false, []string{"gp_ann1", "gp_ann2", "gp_unknown"}},
+		{"unknown excluded", true, []string{"gp_ann1", "gp_ann2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", items...)
+			im := newFakeImmich(t)
+			a := newTestApp(t, &config.Config{}, im)
+			ac := config.GooglePhotosConfig{URL: g.albumURL(), OnlyUploaders: []string{" ann lee"}, ExcludeUnknownUploader: tt.exclude}
+
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, up := range im.uploads() {
+				got = append(got, strings.TrimSuffix(up.Name, ".jpg"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uploaded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollapseVariants(t *testing.T) {
+	at := time.UnixMilli(1700000000000)
+	photo := func(id string, w, h int, name string) googlephotos.Photo {
+		return googlephotos.Photo{ID: id, Width: w, Height: h, FileName: name, TakenAt: at}
+	}
+	tests := []struct {
+		name     string
+		photos   []googlephotos.Photo
+		prefer   string
+		wantKept []string
+	}{
+		{"same size", []googlephotos.Photo{photo("orig", 4000, 3000, ""), photo("edit", 4000, 3000, "")}, "original", []string{"orig"}},
+		{"same size, edited", []googlephotos.Photo{photo("orig", 4000, 3000, ""), photo("edit", 4000, 3000, "")}, "edited", []string{"edit"}},
+		{"rotated", []googlephotos.Photo{photo("orig", 4000, 3000, ""), photo("edit", 3000, 4000, "")}, "original", []string{"orig"}},
+		{"cropped, related names", []googlephotos.Photo{photo("orig", 4000, 3000, "IMG_1.jpg"), photo("edit", 2000, 1500, "IMG_1-edited.jpg")}, "original", []string{"orig"}},
+		{"cropped, no names", []googlephotos.Photo{photo("a", 4000, 3000, ""), photo("b", 2000, 1500, "")}, "original", []string{"a", "b"}},
+		{"unrelated names", []googlephotos.Photo{photo("a", 4000, 3000, "IMG_1.jpg"), photo("b", 1200, 1600, "IMG_2.jpg")}, "original", []string{"a", "b"}},
+		{"live photo halves", []googlephotos.Photo{
+			photo("still", 1920, 1440, "IMG_1.HEIC"),
+			{ID: "clip", Width: 1920, Height: 1440, FileName: "IMG_1.MOV", TakenAt: at, IsVideo: true},
+		}, "original", []string{"still", "clip"}},
+		{"burst", []googlephotos.Photo{photo("a", 400, 300, ""), photo("b", 400, 300, ""), photo("c", 400, 300, "")}, "original", []string{"a", "b", "c"}},
+		{"disabled", []googlephotos.Photo{photo("orig", 400, 300, ""), photo("edit", 400, 300, "")}, "", []string{"orig", "edit"}},
+	}
+	for _, tt := range tests {
+		kept, dropped := collapseVariants(tt.photos, tt.prefer)
+		var ids []string
+		for _, p := range kept {
+			ids = append(ids, p.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.wantKept) || dropped != len(tt.photos)-len(tt.wantKept) {
+			t.Errorf("%s: kept %v (dropped %d), want %v", tt.name, ids, dropped, tt.wantKept)
+		}
+	}
+}
+
+func TestSelectItemsMaxItems(t *testing.T) {
+	var photos []googlephotos.Photo
+	for i := 0; i < 5; i++ {
+		photos = append(photos, googlephotos.Photo{ID: fmt.Sprintf("item%d", i), Width: 400, Height: 300})
+	}
+	tests := []struct {
+		name     string
+		maxItems int
+		want     int
+		logged   bool
+	}{
+		{"unlimited", 0, 5, false},
+		{"truncated", 2, 2, true},
+		{"exact", 5, 5, false},
+		{"above total", 10, 5, false},
+	}
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&logs, nil))
+		got := selectItems(photos, config.GooglePhotosConfig{MaxItems: tt.maxItems}, logger)
+		if len(got) != tt.want {
+			t.Errorf("%s: kept %d items, want %d", tt.name, len(got), tt.want)
+		}
+		for i, p := range got {
+			if p.ID != photos[i].ID {
+				t.Errorf("%s: item %d is %s, want %s (album order)", tt.name, i, p.ID, photos[i].ID)
+			}
+		}
+
+		var line struct {
+			Msg      string `json:"msg"`
+			MaxItems int    `json:"max_items"`
+			Total    int    `json:"total"`
+		}
+		logged := false
+		for _, l := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			if json.Unmarshal([]byte(l), &line) == nil && line.Msg == "Limited items" {
+				logged = true
+				if line.MaxItems != tt.maxItems || line.Total != len(photos) {
+					t.Errorf("%s: logged max_items=%d total=%d, want %d and %d", tt.name, line.MaxItems, line.Total, tt.maxItems, len(photos))
+				}
+			}
+		}
+		if logged != tt.logged {
+			t.Errorf("%s: logged the limit: %v, want %v", tt.name, logged, tt.logged)
+		}
+	}
+}
+
+func TestAllItemsFilteredRecordsCleanSync(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(3)...)
+	im := newFakeImmich(t)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	a := newTestApp(t, &config.Config{QuickCheck: true, StateFile: stateFile}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL(), MinWidth: 1000}
+
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != 0 {
+		t.Fatalf("uploaded %d filtered items", n)
+	}
+
+	// Written to disk, not only kept in memory
+	saved, err := state.Load(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := saved.Album(ac.URL)
+	if st.LastSync.IsZero() || st.LastItemCount != 3 || st.LastItemID != "item02" || st.FilterHash != a.filterHash(ac) {
+		t.Errorf("saved state %+v, want the clean sync of 3 items ending with item02", st)
+	}
+
+	// The next run only probes the page
+	pages := g.pageCount()
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if n := g.pageCount() - pages; n != 1 {
+		t.Errorf("second run fetched the album page %d times, want 1 (the quick check)", n)
+	}
+}
+
+func TestFilterByDate(t *testing.T) {
+	day := func(y int, m time.Month, d, h, min, s, ns int) time.Time {
+		return time.Date(y, m, d, h, min, s, ns, time.Local) // Date-only bounds are local midnight
+	}
+	utc := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		name           string
+		start, end     string
+		excludeUnknown bool
+		takenAt        time.Time
+		kept           bool
+	}{
+		{"on the start date", "2024-03-01", "", false, day(2024, 3, 1, 0, 0, 0, 0), true},
+		{"just before the start date", "2024-03-01", "", false, day(2024, 2, 29, 23, 59, 59, 999999999), false},
+		{"last moment of the end date", "", "2024-03-31", false, day(2024, 3, 31, 23, 59, 59, 999999999), true},
+		{"day after the end date", "", "2024-03-31", false, day(2024, 4, 1, 0, 0, 0, 0), false},
+		{"single day range", "2024-03-01", "2024-03-01", false, day(2024, 3, 1, 12, 0, 0, 0), true},
+		{"exactly the RFC3339 start", "2024-03-01T10:00:00Z", "", false, utc("2024-03-01T10:00:00Z"), true},
+		{"before the RFC3339 start", "2024-03-01T10:00:00Z", "", false, utc("2024-03-01T09:59:59Z"), false},
+		{"exactly the RFC3339 end", "", "2024-03-01T10:00:00+02:00", false, utc("2024-03-01T08:00:00Z"), true},
+		{"after the RFC3339 end", "", "2024-03-01T10:00:00+02:00", false, utc("2024-03-01T08:00:00.000000001Z"), false},
+		{"unknown date kept", "2024-03-01", "2024-03-31", false, time.Time{}, true},
+		{"unknown date excluded", "2024-03-01", "2024-03-31", true, time.Time{}, false},
+		{"unknown date without a range", "", "", true, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		ac := config.GooglePhotosConfig{StartDate: tt.start, EndDate: tt.end, ExcludeUnknownDate: tt.excludeUnknown}
+		kept, filtered := filterByDate([]googlephotos.Photo{{ID: "p", TakenAt: tt.takenAt}}, ac)
+		if got := len(kept) == 1; got != tt.kept || filtered != 1-len(kept) {
+			t.Errorf("%s: kept %v (filtered %d), want kept %v", tt.name, got, filtered, tt.kept)
+		}
+	}
+}
+
+func TestFilterBySize(t *testing.T) {
+	tests := []struct {
+		name                string
+		minWidth, minHeight int
+		excludeUnknown      bool
+		width, height       int
+		kept                bool
+	}{
+		{"exactly the minimum", 640, 480, false, 640, 480, true},
+		{"one pixel too narrow", 640, 480, false, 639, 480, false},
+		{"one pixel too short", 640, 480, false, 640, 479, false},
+		{"width only", 640, 0, false, 640, 10, true},
+		{"height only", 0, 480, false, 10, 479, false},
+		{"no minimum", 0, 0, true, 1, 1, true},
+		{"unknown size kept", 640, 480, false, 0, 0, true},
+		{"unknown size excluded", 640, 480, true, 0, 0, false},
+		{"unknown height excluded", 640, 480, true, 4000, 0, false},
+		{"unknown size without a minimum", 0, 0, true, 0, 0, true},
+	}
+	for _, tt := range tests {
+		ac := config.GooglePhotosConfig{MinWidth: tt.minWidth, MinHeight: tt.minHeight, ExcludeUnknownSize: tt.excludeUnknown}
+		kept, filtered := filterBySize([]googlephotos.Photo{{ID: "p", Width: tt.width, Height: tt.height}}, ac)
+		if got := len(kept) == 1; got != tt.kept || filtered != 1-len(kept) {
+			t.Errorf("%s: kept %v (filtered %d), want kept %v", tt.name, got, filtered, tt.kept)
+		}
+	}
+}
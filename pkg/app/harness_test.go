@@ -0,0 +1,697 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+	"warreth.dev/immich-sync/pkg/immich"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+// jpegBytes is enough of a JPEG for content sniffing
+var jpegBytes = append([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F', 0}, bytes.Repeat([]byte{0x42}, 64)...)
+
+// mp4Bytes is enough of an MP4 for content sniffing
+var mp4Bytes = append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}, bytes.Repeat([]byte{0x17}, 64)...)
+
+// fakeItem is one item of a fakeGoogle album
+type fakeItem struct {
+	ID      string
+	Width   int
+	Height  int
+	TakenAt int64  // Epoch ms, 0 leaves the field empty
+	TZ      int64  // UTC offset of TakenAt in ms, 0 leaves the field empty
+	Extra   string // Raw JSON appended to the item array, e.g. metadata sub-arrays
+	Body    []byte // Media bytes, jpegBytes followed by the ID when nil
+	Type    string // Content-Type of the media, image/jpeg when empty
+	Motion  bool   // Motion photo: the page data names a clip, served as <id>-motion
+}
+
+// fakeGoogle serves a single-page shared album and its media
+type fakeGoogle struct {
+	*httptest.Server
+	t *testing.T
+
+	mu     sync.Mutex
+	title  string
+	items  []fakeItem
+	cover  string                  // Item ID the page metadata names as the album cover
+	delay  time.Duration           // Added to every media GET
+	status map[string][]int        // Item ID -> statuses answered to its next media GETs, in order
+	onGet  func(id, suffix string) // Called on every media GET
+	onPage func(n int)             // Called after serving the nth album page
+	paging bool                    // Page data names a continuation page, whose request fails
+	heads  int
+	gets   map[string]int // "id=suffix" -> media GETs
+	pages  int
+}
+
+func newFakeGoogle(t *testing.T, title string, items ...fakeItem) *fakeGoogle {
+	t.Helper()
+	g := &fakeGoogle{t: t, title: title, items: items, status: make(map[string][]int), gets: make(map[string]int)}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+	t.Cleanup(g.Close)
+	return g
+}
+
+// motionURL is the clip URL of a motion item as it appears in the page data.
+// Only content hosts count as clip URLs, so a client built by contentClient is
+// needed to download it.
+func motionURL(id string) string {
+	return "https://" + fakeContentHost + "/m/" + id + "-motion"
+}
+
+// fakeContentHost stands in for Google's media host in page data
+const fakeContentHost = "lh3.googleusercontent.com"
+
+// contentClient returns a Google client like newTestApp's that sends requests for
+// fakeContentHost and photos.google.com (continuation pages) to the fake instead
+func (g *fakeGoogle) contentClient() *googlephotos.Client {
+	target := g.URL
+	return newTestGPClient(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if h := r.URL.Hostname(); h == fakeContentHost || h == "photos.google.com" {
+			u, err := url.Parse(target + r.URL.RequestURI())
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(r.Context())
+			r.URL, r.Host = u, u.Host
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	}))
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// albumURL is the share URL of the album, its media key is "KEY"
+func (g *fakeGoogle) albumURL() string {
+	return g.URL + "/share/KEY"
+}
+
+func (g *fakeGoogle) setItems(items ...fakeItem) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.items = items
+}
+
+func (g *fakeGoogle) headCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.heads
+}
+
+func (g *fakeGoogle) pageCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pages
+}
+
+func (g *fakeGoogle) getCount(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.gets[key]
+}
+
+func (g *fakeGoogle) mediaGets() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for _, c := range g.gets {
+		n += c
+	}
+	return n
+}
+
+func (g *fakeGoogle) serve(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/data/batchexecute") {
+		http.Error(w, "paging unavailable", http.StatusInternalServerError)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/share/") {
+		g.mu.Lock()
+		g.pages++
+		n, page, onPage := g.pages, g.page(), g.onPage
+		g.mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+		if onPage != nil {
+			onPage(n)
+		}
+		return
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/m/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id, suffix, _ := strings.Cut(rest, "=")
+	id, motion := strings.CutSuffix(id, "-motion")
+
+	g.mu.Lock()
+	var item *fakeItem
+	for i := range g.items {
+		if g.items[i].ID == id {
+			item = &g.items[i]
+		}
+	}
+	status := http.StatusOK
+	if r.Method == http.MethodHead {
+		g.heads++
+	} else {
+		g.gets[id+"="+suffix]++
+		if queued := g.status[id]; len(queued) > 0 {
+			status, g.status[id] = queued[0], queued[1:]
+		}
+	}
+	delay, onGet := g.delay, g.onGet
+	g.mu.Unlock()
+
+	if item == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodHead {
+		if onGet != nil {
+			onGet(id, suffix)
+		}
+		time.Sleep(delay)
+	}
+	body, ct := item.Body, item.Type
+	if body == nil {
+		body = append(bytes.Clone(jpegBytes), id...) // Distinct checksums per item
+	}
+	if ct == "" {
+		ct = "image/jpeg"
+	}
+	if motion {
+		if !item.Motion {
+			http.NotFound(w, r)
+			return
+		}
+		body, ct = append(bytes.Clone(mp4Bytes), id...), "video/mp4"
+	}
+	w.Header().Set("Content-Type", ct)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// page renders the album page with the items in its ds:1 data block
+func (g *fakeGoogle) page() string {
+	var items []string
+	for _, it := range g.items {
+		takenAt := "null"
+		if it.TakenAt != 0 {
+			takenAt = fmt.Sprint(it.TakenAt)
+		}
+		tz := "null"
+		if it.TZ != 0 {
+			tz = fmt.Sprint(it.TZ)
+		}
+		item := fmt.Sprintf(`[%q,[%q,%d,%d],%s,%q,%s`, it.ID, g.URL+"/m/"+it.ID, it.Width, it.Height, takenAt, "dedup-"+it.ID, tz)
+		if it.Motion {
+			item += fmt.Sprintf(`,[[%q]]`, motionURL(it.ID))
+		}
+		if it.Extra != "" {
+			item += "," + it.Extra
+		}
+		items = append(items, item+"]")
+	}
+	token := "null"
+	if g.paging {
+		token = `"CONTINUE-TOKEN-1"`
+	}
+	meta := ""
+	if g.cover != "" {
+		meta = fmt.Sprintf(`,[null,[%q]]`, g.cover)
+	}
+	return fmt.Sprintf(`<html><head><meta property="og:title" content=%q></head><body>`+
+		`<script>AF_initDataCallback({key: 'ds:1', hash: '2', data:[null,[%s],%s%s]});</script></body></html>`,
+		g.title, strings.Join(items, ","), token, meta)
+}
+
+// fakeAsset is an asset stored by fakeImmich
+type fakeAsset struct {
+	ID            string
+	Name          string
+	DeviceAssetID string
+	Description   string
+	Checksum      string
+	Type          string
+	CreatedAt     string
+	LibraryID     string
+}
+
+// fakeAlbum is an album stored by fakeImmich
+type fakeAlbum struct {
+	ID          string
+	Name        string
+	Description string
+	Thumbnail   string
+	Assets      []string
+	Users       []immich.AlbumUser
+}
+
+// fakeCall is a request received by fakeImmich
+type fakeCall struct {
+	Method string
+	Path   string
+	Body   []byte // Empty for uploads
+}
+
+// fakeImmich implements the parts of the Immich API the sync uses, in memory
+type fakeImmich struct {
+	*httptest.Server
+	t *testing.T
+
+	mu        sync.Mutex
+	nextID    int
+	albums    map[string]*fakeAlbum
+	assets    map[string]*fakeAsset
+	users     []immich.User
+	libraries []immich.Library
+	calls     []fakeCall
+	failures  map[string]int // "METHOD path" -> status answered instead of handling the request
+	rejects   map[string]int // Upload filename -> status answered instead of storing it
+	checksum  string         // Reported by GET assets/{id} instead of the real one when set
+}
+
+func newFakeImmich(t *testing.T) *fakeImmich {
+	t.Helper()
+	im := &fakeImmich{
+		t:        t,
+		albums:   make(map[string]*fakeAlbum),
+		assets:   make(map[string]*fakeAsset),
+		failures: make(map[string]int),
+		rejects:  make(map[string]int),
+	}
+	im.Server = httptest.NewServer(http.HandlerFunc(im.serve))
+	t.Cleanup(im.Close)
+	return im
+}
+
+func (im *fakeImmich) id(prefix string) string {
+	im.nextID++
+	return fmt.Sprintf("%s%d", prefix, im.nextID)
+}
+
+// addAlbum creates an album directly, as if made in the Immich UI
+func (im *fakeImmich) addAlbum(name string, assetIDs ...string) string {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	id := im.id("album")
+	im.albums[id] = &fakeAlbum{ID: id, Name: name, Assets: assetIDs}
+	return id
+}
+
+// addAsset stores an asset directly, as if uploaded earlier
+func (im *fakeImmich) addAsset(a fakeAsset) string {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if a.ID == "" {
+		a.ID = im.id("asset")
+	}
+	if a.Type == "" {
+		a.Type = "IMAGE"
+	}
+	im.assets[a.ID] = &a
+	return a.ID
+}
+
+func (im *fakeImmich) album(id string) fakeAlbum {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if alb, ok := im.albums[id]; ok {
+		return *alb
+	}
+	return fakeAlbum{}
+}
+
+// albumNamed returns the ID of the album with the given name, "" when there is none
+func (im *fakeImmich) albumNamed(name string) string {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for id, alb := range im.albums {
+		if alb.Name == name {
+			return id
+		}
+	}
+	return ""
+}
+
+func (im *fakeImmich) albumCount() int {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	return len(im.albums)
+}
+
+// renameAlbum changes an album's name, as a user would in the Immich UI
+func (im *fakeImmich) renameAlbum(id, name string) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.albums[id].Name = name
+}
+
+// removeFromAlbum takes an asset out of an album, as a user would in the Immich UI
+func (im *fakeImmich) removeFromAlbum(albumID, assetID string) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	alb := im.albums[albumID]
+	for i, id := range alb.Assets {
+		if id == assetID {
+			alb.Assets = append(alb.Assets[:i], alb.Assets[i+1:]...)
+			return
+		}
+	}
+}
+
+// uploads returns the assets uploaded through the API, ordered by name
+func (im *fakeImmich) uploads() []fakeAsset {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	var list []fakeAsset
+	for _, a := range im.assets {
+		if a.CreatedAt != "" {
+			list = append(list, *a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// callsTo returns the requests received for a method and path prefix
+func (im *fakeImmich) callsTo(method, path string) []fakeCall {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	var list []fakeCall
+	for _, c := range im.calls {
+		if c.Method == method && strings.HasPrefix(c.Path, path) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+func (im *fakeImmich) failWith(method, path string, status int) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.failures[method+" "+path] = status
+}
+
+func (im *fakeImmich) assetJSON(a *fakeAsset) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               a.ID,
+		"originalFileName": a.Name,
+		"deviceAssetId":    a.DeviceAssetID,
+		"checksum":         a.Checksum,
+		"type":             a.Type,
+		"exifInfo":         map[string]interface{}{"description": a.Description},
+	}
+}
+
+func (im *fakeImmich) albumJSON(alb *fakeAlbum, withAssets bool) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":                    alb.ID,
+		"albumName":             alb.Name,
+		"description":           alb.Description,
+		"albumThumbnailAssetId": alb.Thumbnail,
+		"albumUsers":            alb.Users,
+		"assetCount":            len(alb.Assets),
+		"ownerId":               "owner",
+	}
+	if withAssets {
+		assets := []map[string]interface{}{}
+		for _, id := range alb.Assets {
+			if a, ok := im.assets[id]; ok {
+				assets = append(assets, im.assetJSON(a))
+			}
+		}
+		out["assets"] = assets
+	}
+	return out
+}
+
+func (im *fakeImmich) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	var body []byte
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		body, _ = io.ReadAll(r.Body)
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.calls = append(im.calls, fakeCall{Method: r.Method, Path: path, Body: body})
+	if status := im.failures[r.Method+" "+path]; status != 0 {
+		http.Error(w, `{"message":"injected failure"}`, status)
+		return
+	}
+
+	reply := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	parts := strings.Split(path, "/")
+
+	switch {
+	case r.Method == "GET" && path == "users/me":
+		reply(map[string]string{"id": "owner", "name": "Owner"})
+	case r.Method == "GET" && path == "users":
+		reply(im.users)
+	case r.Method == "GET" && path == "libraries":
+		reply(append([]immich.Library{}, im.libraries...))
+
+	case r.Method == "GET" && path == "albums":
+		list := []map[string]interface{}{}
+		for _, alb := range im.albums {
+			list = append(list, im.albumJSON(alb, false))
+		}
+		reply(list)
+	case r.Method == "POST" && path == "albums":
+		var req struct{ AlbumName, Description string }
+		json.Unmarshal(body, &req)
+		alb := &fakeAlbum{ID: im.id("album"), Name: req.AlbumName, Description: req.Description}
+		im.albums[alb.ID] = alb
+		reply(im.albumJSON(alb, true))
+	case len(parts) == 2 && parts[0] == "albums":
+		alb, ok := im.albums[parts[1]]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusBadRequest)
+			return
+		}
+		if r.Method == "PATCH" {
+			var req immich.AlbumUpdate
+			json.Unmarshal(body, &req)
+			if req.AlbumName != "" {
+				alb.Name = req.AlbumName
+			}
+			if req.Description != "" {
+				alb.Description = req.Description
+			}
+			if req.AlbumThumbnailAssetId != "" {
+				alb.Thumbnail = req.AlbumThumbnailAssetId
+			}
+		}
+		reply(im.albumJSON(alb, true))
+	case r.Method == "PUT" && len(parts) == 3 && parts[0] == "albums" && parts[2] == "assets":
+		alb := im.albums[parts[1]]
+		var req struct{ Ids []string }
+		json.Unmarshal(body, &req)
+		var res []map[string]interface{}
+		for _, id := range req.Ids {
+			switch {
+			case im.assets[id] == nil:
+				res = append(res, map[string]interface{}{"id": id, "success": false, "error": "not_found"})
+			case contains(alb.Assets, id):
+				res = append(res, map[string]interface{}{"id": id, "success": false, "error": "duplicate"})
+			default:
+				alb.Assets = append(alb.Assets, id)
+				res = append(res, map[string]interface{}{"id": id, "success": true})
+			}
+		}
+		reply(res)
+	case r.Method == "PUT" && len(parts) == 3 && parts[0] == "albums" && parts[2] == "users":
+		var req struct {
+			AlbumUsers []struct{ UserId, Role string }
+		}
+		json.Unmarshal(body, &req)
+		alb := im.albums[parts[1]]
+		for _, u := range req.AlbumUsers {
+			alb.Users = append(alb.Users, immich.AlbumUser{User: immich.User{Id: u.UserId}, Role: u.Role})
+		}
+		reply(im.albumJSON(alb, false))
+
+	case r.Method == "POST" && path == "search/metadata":
+		var items []map[string]interface{}
+		for _, a := range im.assets {
+			items = append(items, im.assetJSON(a))
+		}
+		reply(map[string]interface{}{"assets": map[string]interface{}{"items": items, "nextPage": nil}})
+	case r.Method == "POST" && path == "assets":
+		im.upload(w, r, reply)
+	case r.Method == "GET" && len(parts) == 2 && parts[0] == "assets":
+		a, ok := im.assets[parts[1]]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusBadRequest)
+			return
+		}
+		out := im.assetJSON(a)
+		if im.checksum != "" {
+			out["checksum"] = im.checksum
+		}
+		reply(out)
+	case r.Method == "DELETE" && path == "assets":
+		var req struct{ Ids []string }
+		json.Unmarshal(body, &req)
+		for _, id := range req.Ids {
+			delete(im.assets, id)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT" && (path == "assets" || parts[0] == "assets" || path == "tags/assets"):
+		reply(map[string]interface{}{})
+	case r.Method == "POST" && path == "stacks":
+		reply(map[string]string{"id": im.id("stack")})
+	case r.Method == "PUT" && path == "tags":
+		var req struct{ Tags []string }
+		json.Unmarshal(body, &req)
+		var tags []immich.Tag
+		for _, name := range req.Tags {
+			tags = append(tags, immich.Tag{Id: "tag-" + name, Name: name, Value: name})
+		}
+		reply(tags)
+	default:
+		im.t.Errorf("fakeImmich: unexpected request %s %s", r.Method, path)
+		http.Error(w, `{"message":"unexpected"}`, http.StatusNotFound)
+	}
+}
+
+// checksumOf is the base64 SHA-1 Immich reports for data
+func checksumOf(data []byte) string {
+	sum := sha1.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// upload stores a multipart asset upload, answering duplicate for known checksums like Immich
+func (im *fakeImmich) upload(w http.ResponseWriter, r *http.Request, reply func(interface{})) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, `{"message":"bad upload"}`, http.StatusBadRequest)
+		return
+	}
+	f, hdr, err := r.FormFile("assetData")
+	if err != nil {
+		http.Error(w, `{"message":"no file"}`, http.StatusBadRequest)
+		return
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if status := im.rejects[hdr.Filename]; status != 0 {
+		http.Error(w, `{"message":"injected upload failure"}`, status)
+		return
+	}
+	checksum := checksumOf(data)
+	for _, a := range im.assets {
+		if a.Checksum == checksum {
+			reply(map[string]interface{}{"id": a.ID, "duplicate": true})
+			return
+		}
+	}
+	assetType := "IMAGE"
+	if strings.HasPrefix(http.DetectContentType(data), "video/") || strings.HasSuffix(hdr.Filename, ".mp4") {
+		assetType = "VIDEO"
+	}
+	a := &fakeAsset{
+		ID:            im.id("asset"),
+		Name:          hdr.Filename,
+		DeviceAssetID: r.FormValue("deviceAssetId"),
+		Description:   r.FormValue("description"),
+		Checksum:      checksum,
+		Type:          assetType,
+		CreatedAt:     r.FormValue("fileCreatedAt"),
+		LibraryID:     r.FormValue("libraryId"),
+	}
+	im.assets[a.ID] = a
+	reply(map[string]interface{}{"id": a.ID, "duplicate": false})
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// newTestApp builds an App talking to the fakes, with Google pauses disabled and
+// logs discarded. cfg may be nil; its album list defaults to the fakeGoogle album.
+func newTestApp(t *testing.T, cfg *config.Config, im *fakeImmich) *App {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	if im != nil {
+		cfg.ApiURL, cfg.ApiKey = im.URL, "key"
+	}
+	if cfg.FailedRetryDelay == "" {
+		cfg.FailedRetryDelay = "0"
+	}
+	cfg.LogFormat = "json" // Keeps progress bars out of the test output
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, err := state.Load(cfg.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{
+		Cfg:      cfg,
+		Client:   immich.NewClient(cfg.ApiURL, cfg.ApiKey),
+		GPClient: newTestGPClient(nil),
+		Logger:   logger,
+		State:    store,
+		health:   newHealth(cfg, 0),
+	}
+	return a
+}
+
+// newTestGPClient returns a Google client without pauses or retries
+func newTestGPClient(transport http.RoundTripper) *googlephotos.Client {
+	return googlephotos.NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), googlephotos.Options{
+		MinDelay:          -1,
+		Jitter:            -1,
+		RequestsPerSecond: -1,
+		MaxRetries:        1,
+		DownloadRetries:   -1,
+		Transport:         transport,
+	})
+}
+
+// syncAlbum runs processAlbum for ac against the current Immich album list
+func syncAlbum(t *testing.T, ctx context.Context, a *App, ac config.GooglePhotosConfig) error {
+	t.Helper()
+	albums, err := a.Client.GetAlbums()
+	if err != nil {
+		t.Fatalf("listing albums: %v", err)
+	}
+	return a.processAlbum(ctx, ac, albums)
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+// health tracks what /healthz reports: the Immich connection check at startup
+// and the last album sync that ran to completion
+type health struct {
+	mu         sync.Mutex
+	staleAfter time.Duration // Unhealthy when no sync succeeded for this long, 0 never goes stale
+	started    time.Time
+	checked    bool  // Immich connection has been checked
+	immichErr  error // Result of the connection check
+	lastSync   time.Time
+}
+
+// healthReport is the /healthz response body
+type healthReport struct {
+	Status   string     `json:"status"` // "ok" or "unhealthy"
+	Reason   string     `json:"reason,omitempty"`
+	Immich   string     `json:"immich"` // "connected", "unchecked" or the connection error
+	LastSync *time.Time `json:"lastSync,omitempty"`
+}
+
+// newHealth sets the staleness window to healthStaleAfter, or twice the longest
+// album sync interval when unset
+func newHealth(cfg *config.Config, staleAfter time.Duration) *health {
+	if staleAfter <= 0 {
+		for _, ac := range cfg.GooglePhotos {
+			staleAfter = max(staleAfter, 2*syncInterval(ac))
+		}
+	}
+	return &health{staleAfter: staleAfter, started: time.Now()}
+}
+
+// immichChecked records the result of the startup connection check
+func (h *health) immichChecked(err error) {
+	if h == nil { // App built without New
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checked = true
+	h.immichErr = err
+}
+
+// syncSucceeded records an album sync that ran to completion
+func (h *health) syncSucceeded(t time.Time) {
+	if h == nil { // App built without New
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSync = t
+}
+
+// report evaluates the health at now. Until the first sync succeeds the window
+// counts from startup, so a long first sync isn't reported as unhealthy.
+func (h *health) report(now time.Time) healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r := healthReport{Status: "ok", Immich: "connected"}
+	if !h.lastSync.IsZero() {
+		last := h.lastSync
+		r.LastSync = &last
+	}
+	switch {
+	case !h.checked:
+		r.Immich = "unchecked"
+	case h.immichErr != nil:
+		r.Immich = h.immichErr.Error()
+		r.Status, r.Reason = "unhealthy", "Immich connection check failed"
+		return r
+	}
+
+	since := h.started
+	if !h.lastSync.IsZero() {
+		since = h.lastSync
+	}
+	if h.staleAfter > 0 && now.Sub(since) > h.staleAfter {
+		r.Status, r.Reason = "unhealthy", "no successful sync within "+h.staleAfter.String()
+	}
+	return r
+}
+
+// ServeHTTP answers 200 when healthy and 503 otherwise, with the report as JSON
+func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.report(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
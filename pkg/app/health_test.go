@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+func TestHealthHandler(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		immichErr  error
+		started    time.Duration // Before now
+		lastSync   time.Duration // Before now, 0 for none
+		wantStatus int
+	}{
+		{name: "fresh sync", lastSync: time.Minute, started: 3 * time.Hour, wantStatus: http.StatusOK},
+		{name: "first sync still running", started: 30 * time.Minute, wantStatus: http.StatusOK},
+		{name: "stale sync", lastSync: 2 * time.Hour, started: 3 * time.Hour, wantStatus: http.StatusServiceUnavailable},
+		{name: "no sync since startup", started: 2 * time.Hour, wantStatus: http.StatusServiceUnavailable},
+		{name: "immich unreachable", immichErr: errors.New("connection refused"), lastSync: time.Minute, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &health{staleAfter: time.Hour, started: now.Add(-tt.started)}
+			h.immichChecked(tt.immichErr)
+			if tt.lastSync > 0 {
+				h.syncSucceeded(now.Add(-tt.lastSync))
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var report healthReport
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("invalid JSON body: %v", err)
+			}
+			if wantOK := tt.wantStatus == http.StatusOK; (report.Status == "ok") != wantOK {
+				t.Errorf("report status %q for HTTP %d", report.Status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHealthCountsOnlyCompleteSyncs(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(4)...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{QuickCheck: true}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL()}
+
+	// Cut short by the deadline: not a success
+	g.delay = 60 * time.Millisecond
+	ac.MaxSyncDuration = "50ms"
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if !a.health.lastSync.IsZero() {
+		t.Error("deadline-cut sync reported as successful")
+	}
+
+	g.mu.Lock()
+	g.delay = 0
+	g.mu.Unlock()
+	ac.MaxSyncDuration = ""
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	first := a.health.lastSync
+	if first.IsZero() {
+		t.Fatal("complete sync not reported as successful")
+	}
+
+	// Skipped by the quick check: still a successful sync
+	time.Sleep(time.Millisecond)
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if !a.health.lastSync.After(first) {
+		t.Error("unchanged album skip not reported as successful")
+	}
+
+	// An album with nothing to sync is healthy too
+	empty := newTestApp(t, nil, im)
+	g.setItems()
+	if err := syncAlbum(t, context.Background(), empty, ac); err != nil {
+		t.Fatal(err)
+	}
+	if empty.health.lastSync.IsZero() {
+		t.Error("empty album not reported as successful")
+	}
+}
+
+func TestNewLeavesPortsToSyncRuns(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	a, err := New(&config.Config{ApiURL: "http://127.0.0.1:1/api", ApiKey: "key", HealthPort: port, MetricsBackend: "prometheus", MetricsPort: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// -status, -verify and -diff build the App too: the port must still be free
+	ln, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("New bound the health/metrics port: %v", err)
+	}
+	ln.Close()
+
+	a.startServers()
+	for _, path := range []string{"/healthz", "/metrics"} {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+		if err != nil {
+			t.Fatalf("%s not served after startServers: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			t.Errorf("%s not registered", path)
+		}
+	}
+}
+
+func TestHealthWhileImmichUnreachable(t *testing.T) {
+	healthCode := func(a *App) int {
+		rec := httptest.NewRecorder()
+		a.health.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+		return rec.Code
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+
+	for _, recovers := range []bool{true, false} {
+		t.Run(fmt.Sprintf("recovers %v", recovers), func(t *testing.T) {
+			im := newFakeImmich(t)
+			im.failWith("GET", "users/me", http.StatusBadGateway)
+			a := newTestApp(t, nil, im)
+			a.connectBackoff = time.Millisecond
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				a.RunContext(ctx) // No albums: returns once connected
+			}()
+
+			waitFor("a retried connection check", func() bool { return len(im.callsTo("GET", "users/me")) >= 3 })
+			if code := healthCode(a); code != http.StatusServiceUnavailable {
+				t.Errorf("health %d while Immich is unreachable, want 503", code)
+			}
+
+			if recovers {
+				im.mu.Lock()
+				delete(im.failures, "GET users/me")
+				im.mu.Unlock()
+			} else {
+				cancel()
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("RunContext still retrying")
+			}
+			if wantOK := recovers; (healthCode(a) == http.StatusOK) != wantOK {
+				t.Errorf("health %d after the run, want OK: %v", healthCode(a), wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+// libraryID returns the Immich library an album uploads into, "" for the default
+func (a *App) libraryID(ac config.GooglePhotosConfig) string {
+	if ac.ImmichLibraryID != "" {
+		return ac.ImmichLibraryID
+	}
+	return a.Cfg.ImmichLibraryID
+}
+
+// checkLibraries verifies that every configured library ID exists in Immich,
+// so a typo fails at startup instead of on every upload
+func (a *App) checkLibraries() error {
+	wanted := make(map[string]bool)
+	for _, ac := range a.Cfg.GooglePhotos {
+		if id := a.libraryID(ac); id != "" {
+			wanted[id] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	libraries, err := a.Client.GetLibraries()
+	if err != nil {
+		return fmt.Errorf("could not list Immich libraries to check immichLibraryId (listing requires an admin API key): %w", err)
+	}
+	found := make(map[string]bool, len(libraries))
+	for _, l := range libraries {
+		found[l.Id] = true
+	}
+	for id := range wanted {
+		if !found[id] {
+			return fmt.Errorf("immichLibraryId %q does not exist in Immich", id)
+		}
+	}
+	a.Logger.Info("Uploading into configured Immich libraries", "count", len(wanted))
+	return nil
+}
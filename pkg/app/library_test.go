@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+func TestUploadsSendLibraryID(t *testing.T) {
+	tests := []struct {
+		name          string
+		global, album string
+		want          string
+	}{
+		{"unset", "", "", ""},
+		{"global", "lib-1", "", "lib-1"},
+		{"per album", "lib-1", "lib-2", "lib-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", fakeItem{ID: "still", Width: 400, Height: 300, Motion: true})
+			im := newFakeImmich(t)
+			a := newTestApp(t, &config.Config{ImmichLibraryID: tt.global}, im)
+			a.GPClient = g.contentClient()
+			ac := config.GooglePhotosConfig{URL: g.albumURL(), ImmichLibraryID: tt.album}
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			uploads := im.uploads()
+			if len(uploads) != 2 {
+				t.Fatalf("%d uploads, want the still and its motion clip", len(uploads))
+			}
+			for _, up := range uploads {
+				if up.LibraryID != tt.want {
+					t.Errorf("%s sent libraryId %q, want %q", up.Name, up.LibraryID, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckLibraries(t *testing.T) {
+	tests := []struct {
+		name       string
+		global     string
+		albums     []string // Per-album library IDs
+		listStatus int      // Answer to listing libraries, 0 for the list
+		wantErr    string   // Empty when the check passes
+		wantList   bool     // Whether libraries are listed at all
+	}{
+		{"none configured", "", []string{""}, 0, "", false},
+		{"existing", "lib-1", []string{"", "lib-2"}, 0, "", true},
+		{"missing global", "lib-9", []string{""}, 0, `"lib-9" does not exist`, true},
+		{"missing per album", "", []string{"lib-1", "nope"}, 0, `"nope" does not exist`, true},
+		{"listing forbidden", "lib-1", []string{""}, http.StatusForbidden, "admin API key", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im := newFakeImmich(t)
+			im.libraries = []immich.Library{{Id: "lib-1", Name: "Photos"}, {Id: "lib-2", Name: "Archive"}}
+			if tt.listStatus != 0 {
+				im.failWith("GET", "libraries", tt.listStatus)
+			}
+			cfg := &config.Config{ImmichLibraryID: tt.global}
+			for _, id := range tt.albums {
+				cfg.GooglePhotos = append(cfg.GooglePhotos, config.GooglePhotosConfig{URL: "https://photos.app.goo.gl/x", ImmichLibraryID: id})
+			}
+			a := newTestApp(t, cfg, im)
+
+			err := a.checkLibraries()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkLibraries() = %v, want error containing %q", err, tt.wantErr)
+			}
+			if listed := len(im.callsTo("GET", "libraries")) > 0; listed != tt.wantList {
+				t.Errorf("listed libraries: %v, want %v", listed, tt.wantList)
+			}
+		})
+	}
+}
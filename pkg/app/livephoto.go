@@ -0,0 +1,138 @@
+package app
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// linkLivePhotos pairs stills with their motion videos when Google lists them as
+// two separate items and links them in Immich so they show as one live photo.
+//
+// Matching heuristic: a still is paired with the video item its page data names
+// as its motion clip, else with the video sharing its filename stem (IMG_1234.HEIC
+// and IMG_1234.MOV). When several videos share the stem, e.g. camera counters that
+// wrapped, the one with the exact same capture millisecond wins; without a single
+// such video the still is left alone. Immich must also report one asset as an image
+// and the other as a video. Only pairs with at least one asset uploaded in this run
+// are checked, so unchanged albums cost no extra API calls.
+func (a *App) linkLivePhotos(photos []googlephotos.Photo, assetByPhoto map[string]string, uploaded map[string]bool, logger *slog.Logger) {
+	var stills []googlephotos.Photo
+	videoByURL := make(map[string]googlephotos.Photo)
+	videosByStem := make(map[string][]googlephotos.Photo)
+	for _, p := range photos {
+		if assetByPhoto[p.ID] == "" {
+			continue
+		}
+		if !p.IsVideo {
+			stills = append(stills, p)
+			continue
+		}
+		videoByURL[p.URL] = p
+		if stem := fileStem(p.FileName); stem != "" {
+			videosByStem[stem] = append(videosByStem[stem], p)
+		}
+	}
+
+	var pairs [][2]string
+	paired := make(map[string]bool)
+	for _, still := range stills {
+		video, ok := videoByURL[still.MotionVideoURL]
+		if !ok || paired[video.ID] {
+			video, ok = stemMatch(still, videosByStem[fileStem(still.FileName)], paired)
+		}
+		if !ok {
+			continue
+		}
+		paired[video.ID] = true
+		pairs = append(pairs, [2]string{assetByPhoto[still.ID], assetByPhoto[video.ID]})
+	}
+
+	linked := 0
+	for _, ids := range pairs {
+		if ids[0] == ids[1] || (!uploaded[ids[0]] && !uploaded[ids[1]]) {
+			continue
+		}
+		first, err := a.Client.GetAsset(ids[0])
+		if err != nil {
+			logger.Warn("Could not check asset for live photo pairing", "id", ids[0], "error", err)
+			continue
+		}
+		second, err := a.Client.GetAsset(ids[1])
+		if err != nil {
+			logger.Warn("Could not check asset for live photo pairing", "id", ids[1], "error", err)
+			continue
+		}
+
+		image, video := first, second
+		if image.Type == "VIDEO" {
+			image, video = second, first
+		}
+		if image.Type != "IMAGE" || video.Type != "VIDEO" || image.LivePhotoVideoId != "" {
+			continue
+		}
+		if err := a.Client.LinkLivePhoto(image.Id, video.Id); err != nil {
+			logger.Warn("Failed to link live photo", "image", image.Id, "video", video.Id, "error", err)
+			continue
+		}
+		logger.Debug("Linked live photo", "image", image.Id, "video", video.Id)
+		linked++
+	}
+	if linked > 0 {
+		logger.Info("Linked live photos", "count", linked)
+	}
+}
+
+// stemMatch picks the video among candidates (sharing the still's filename stem)
+// that isn't paired yet, using the exact capture millisecond to choose between several
+func stemMatch(still googlephotos.Photo, candidates []googlephotos.Photo, paired map[string]bool) (googlephotos.Photo, bool) {
+	var free, sameTime []googlephotos.Photo
+	for _, v := range candidates {
+		if paired[v.ID] {
+			continue
+		}
+		free = append(free, v)
+		if !still.TakenAt.IsZero() && v.TakenAt.UnixMilli() == still.TakenAt.UnixMilli() {
+			sameTime = append(sameTime, v)
+		}
+	}
+	switch {
+	case len(free) == 1:
+		return free[0], true
+	case len(sameTime) == 1:
+		return sameTime[0], true
+	}
+	return googlephotos.Photo{}, false
+}
+
+// fileStem is a filename without its extension, lowercased, "" without a filename
+func fileStem(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// uploadMotionPart uploads the video half of a Google motion photo next to its
+// still (stillId) and stacks the two in Immich with the still as primary.
+// Failures are logged only: the still is already synced. Returns bytes transferred.
+func (a *App) uploadMotionPart(p googlephotos.Photo, job *albumSync, stillId, baseName string, takenAt time.Time) (int64, int64) {
+	r, size, ext, err := googlephotos.DownloadMotionVideo(a.GPClient, p.MotionVideoURL)
+	if err != nil {
+		job.Logger.Warn("Failed to download motion photo video", "id", p.ID, "error", err)
+		return 0, 0
+	}
+	filename := baseName + "_motion" + ext
+	videoId, _, err := a.Client.UploadAssetStream(r, filename, stableID(job.Key, p.ID)+"-motion", size, takenAt, "", job.LibraryID)
+	r.Close()
+	if err != nil || videoId == "" {
+		job.Logger.Warn("Failed to upload motion photo video", "filename", filename, "error", err)
+		return size, 0
+	}
+	if _, err := a.Client.CreateStack([]string{stillId, videoId}); err != nil {
+		job.Logger.Warn("Failed to stack motion photo", "image", stillId, "video", videoId, "error", err)
+		return size, size
+	}
+	job.Logger.Debug("Stacked motion photo", "image", stillId, "video", videoId)
+	return size, size
+}
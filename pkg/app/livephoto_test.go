@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+func TestLinkLivePhotos(t *testing.T) {
+	at := func(ms int64) time.Time { return time.UnixMilli(1700000000000 + ms) }
+	photos := []googlephotos.Photo{
+		// Same filename stem, the video's time a second off
+		{ID: "still1", FileName: "IMG_0001.HEIC", TakenAt: at(0)},
+		{ID: "video1", FileName: "IMG_0001.MOV", TakenAt: at(1000), IsVideo: true, URL: "v1"},
+		// Named as the motion clip, unrelated filenames
+		{ID: "still2", FileName: "PXL_1.jpg", TakenAt: at(5000), MotionVideoURL: "v2"},
+		{ID: "video2", FileName: "clip.mp4", TakenAt: at(9000), IsVideo: true, URL: "v2"},
+		// Two videos share the stem, the capture millisecond decides
+		{ID: "still3", FileName: "IMG_0003.HEIC", TakenAt: at(20000)},
+		{ID: "video3a", FileName: "IMG_0003.MOV", TakenAt: at(30000), IsVideo: true, URL: "v3a"},
+		{ID: "video3b", FileName: "IMG_0003.MOV", TakenAt: at(20000), IsVideo: true, URL: "v3b"},
+		// Same capture time only, e.g. a burst frame and a clip: not a live photo
+		{ID: "still4", TakenAt: at(40000)},
+		{ID: "video4", TakenAt: at(40000), IsVideo: true, URL: "v4"},
+	}
+
+	im := newFakeImmich(t)
+	assetByPhoto := make(map[string]string)
+	uploaded := make(map[string]bool)
+	for _, p := range photos {
+		typ := "IMAGE"
+		if p.IsVideo {
+			typ = "VIDEO"
+		}
+		id := im.addAsset(fakeAsset{ID: "asset-" + p.ID, Name: p.ID, Type: typ})
+		assetByPhoto[p.ID] = id
+		uploaded[id] = true
+	}
+	a := newTestApp(t, &config.Config{}, im)
+	a.linkLivePhotos(photos, assetByPhoto, uploaded, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	links := make(map[string]string)
+	for _, c := range im.callsTo("PUT", "assets/") {
+		var req struct{ LivePhotoVideoId string }
+		if err := json.Unmarshal(c.Body, &req); err != nil {
+			t.Fatal(err)
+		}
+		links[strings.TrimPrefix(c.Path, "assets/")] = req.LivePhotoVideoId
+	}
+	want := map[string]string{
+		"asset-still1": "asset-video1",
+		"asset-still2": "asset-video2",
+		"asset-still3": "asset-video3b",
+	}
+	if len(links) != len(want) {
+		t.Errorf("linked %v, want %v", links, want)
+	}
+	for still, video := range want {
+		if links[still] != video {
+			t.Errorf("%s linked to %q, want %q", still, links[still], video)
+		}
+	}
+}
+
+func TestMotionPhotoUploadsBothPartsAndStacksThem(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", fakeItem{ID: "item00", Width: 400, Height: 300, TakenAt: 1700000000000, Motion: true})
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+	a.GPClient = g.contentClient()
+
+	if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+		t.Fatal(err)
+	}
+	ups := im.uploads()
+	if len(ups) != 2 {
+		t.Fatalf("uploaded %d assets, want the still and its clip", len(ups))
+	}
+	still, clip := ups[0], ups[1]
+	if still.Name != "gp_item00.jpg" || still.Type != "IMAGE" {
+		t.Errorf("still uploaded as %s (%s)", still.Name, still.Type)
+	}
+	if clip.Name != "gp_item00_motion.mp4" || clip.Type != "VIDEO" || clip.DeviceAssetID != "gp:KEY:item00-motion" {
+		t.Errorf("clip uploaded as %s (%s, %s)", clip.Name, clip.Type, clip.DeviceAssetID)
+	}
+	if clip.CreatedAt != still.CreatedAt {
+		t.Errorf("clip dated %s, still %s", clip.CreatedAt, still.CreatedAt)
+	}
+
+	stacks := im.callsTo("POST", "stacks")
+	if len(stacks) != 1 {
+		t.Fatalf("%d stack requests, want 1", len(stacks))
+	}
+	var req struct{ AssetIds []string }
+	if err := json.Unmarshal(stacks[0].Body, &req); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{still.ID, clip.ID}; !reflect.DeepEqual(req.AssetIds, want) {
+		t.Errorf("stacked %v, want %v with the still first", req.AssetIds, want)
+	}
+}
+
+func TestMotionPhotoClipSkippedWithSkipVideos(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", fakeItem{ID: "item00", Width: 400, Height: 300, Motion: true})
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{SkipVideos: true}, im)
+	a.GPClient = g.contentClient()
+
+	if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(im.uploads()); n != 1 {
+		t.Errorf("uploaded %d assets, want the still only", n)
+	}
+	if n := g.getCount("item00=dv"); n != 0 {
+		t.Errorf("clip downloaded %d times with skipVideos", n)
+	}
+	if n := len(im.callsTo("POST", "stacks")); n != 0 {
+		t.Errorf("%d stack requests, want none", n)
+	}
+}
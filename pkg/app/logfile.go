@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+// teeHandler sends every record to all wrapped handlers
+type teeHandler struct {
+	handlers []slog.Handler
+}
+
+func (t *teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t.handlers {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t.handlers {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make([]slog.Handler, len(t.handlers))
+	for i, h := range t.handlers {
+		next[i] = h.WithAttrs(attrs)
+	}
+	return &teeHandler{handlers: next}
+}
+
+func (t *teeHandler) WithGroup(name string) slog.Handler {
+	next := make([]slog.Handler, len(t.handlers))
+	for i, h := range t.handlers {
+		next[i] = h.WithGroup(name)
+	}
+	return &teeHandler{handlers: next}
+}
+
+// defaultAlbumLogDir holds per-run album logs when albumLogDir is unset
+const defaultAlbumLogDir = "logs"
+
+// albumLogger returns the logger for one album run. With AlbumLogFiles enabled it
+// also writes to <dir>/<album-slug>-<timestamp>.log; the returned close func must be called.
+func (a *App) albumLogger(ac config.GooglePhotosConfig) (*slog.Logger, func()) {
+	logger := a.Logger.With("album_url", ac.URL)
+	if !a.Cfg.AlbumLogFiles {
+		return logger, func() {}
+	}
+
+	dir := a.Cfg.AlbumLogDir
+	if dir == "" {
+		dir = defaultAlbumLogDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logger.Warn("Could not create album log directory", "dir", dir, "error", err)
+		return logger, func() {}
+	}
+	name := fmt.Sprintf("%s-%s.log", slugify(albumLogName(ac)), time.Now().Format("20060102-150405"))
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		logger.Warn("Could not create album log file", "error", err)
+		return logger, func() {}
+	}
+
+	level := slog.LevelInfo
+	if a.Cfg.Debug {
+		level = slog.LevelDebug
+	}
+	var fileHandler slog.Handler = slog.NewTextHandler(f, &slog.HandlerOptions{Level: level})
+	if a.Cfg.LogFormat == "json" {
+		fileHandler = slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level})
+	}
+	tee := &teeHandler{handlers: []slog.Handler{a.Logger.Handler(), fileHandler}}
+	logger.Debug("Writing album log file", "file", f.Name())
+	return slog.New(tee).With("album_url", ac.URL), func() { f.Close() }
+}
+
+// albumLogName picks a readable name for an album before it is scraped:
+// the configured album name, or the last segment of the share URL
+func albumLogName(ac config.GooglePhotosConfig) string {
+	if ac.AlbumName != "" {
+		return ac.AlbumName
+	}
+	if u, err := url.Parse(ac.URL); err == nil {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			return base
+		}
+	}
+	return "album"
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"log/slog"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/metrics"
+)
+
+const (
+	defaultStatsDAddress = "127.0.0.1:8125"
+	defaultStatsDPrefix  = "immich_sync" // Also the Prometheus metric prefix
+)
+
+// newMetrics builds the configured metrics backend. Returns nil when metrics are
+// disabled or the backend can't be set up, the sync itself never depends on it.
+// The Prometheus endpoint is only served once a sync run starts (startServers).
+func newMetrics(cfg *config.Config, logger *slog.Logger) metrics.Recorder {
+	switch cfg.MetricsBackend {
+	case "":
+		return nil
+	case "statsd":
+		addr := cfg.StatsDAddress
+		if addr == "" {
+			addr = defaultStatsDAddress
+		}
+		prefix := cfg.StatsDPrefix
+		if prefix == "" {
+			prefix = defaultStatsDPrefix
+		}
+		s, err := metrics.NewStatsD(addr, prefix)
+		if err != nil {
+			logger.Warn("Metrics disabled", "backend", cfg.MetricsBackend, "error", err)
+			return nil
+		}
+		logger.Debug("Pushing metrics to StatsD", "address", addr, "prefix", prefix)
+		return s
+	case "prometheus":
+		if cfg.MetricsPort <= 0 {
+			logger.Warn("Metrics disabled, prometheus backend needs metricsPort")
+			return nil
+		}
+		return metrics.NewPrometheus(defaultStatsDPrefix)
+	default:
+		logger.Warn("Unknown metrics backend, metrics disabled", "backend", cfg.MetricsBackend)
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/metrics"
+)
+
+// scrapeMetrics returns the sample lines served by the Prometheus handler
+func scrapeMetrics(t *testing.T, p *metrics.Prometheus) map[string]string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("metrics answered %d", rec.Code)
+	}
+	samples := make(map[string]string)
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if name, value, ok := strings.Cut(line, " "); ok && !strings.HasPrefix(line, "#") {
+			samples[name] = value
+		}
+	}
+	return samples
+}
+
+func TestPrometheusCountersAfterSync(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(4)...)
+	g.status["item02"] = []int{http.StatusForbidden}
+	g.status["item03"] = []int{500, 500, 500, 500}
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{Workers: 1}, im)
+	prom := metrics.NewPrometheus(defaultStatsDPrefix)
+	a.Metrics = prom
+	itemSize := len(jpegBytes) + len("item00")
+
+	// The first sync uploads two items, the third is restricted and the last fails.
+	// The second uploads those two and skips the rest; a missing album is a scrape error.
+	tests := []struct {
+		name string
+		sync func() error
+		want map[string]string
+	}{
+		{"first sync", func() error {
+			return syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()})
+		}, map[string]string{
+			`immich_sync_assets_added_total{album="Trip"}`:      "2",
+			`immich_sync_assets_skipped_total{album="Trip"}`:    "0",
+			`immich_sync_assets_failed_total{album="Trip"}`:     "1",
+			`immich_sync_assets_restricted_total{album="Trip"}`: "1",
+			`immich_sync_bytes_downloaded_total{album="Trip"}`:  fmt.Sprint(2 * itemSize),
+			`immich_sync_bytes_uploaded_total{album="Trip"}`:    fmt.Sprint(2 * itemSize),
+			`immich_sync_scrape_errors_total{album="Trip"}`:     "0",
+		}},
+		{"second sync", func() error {
+			g.mu.Lock()
+			delete(g.status, "item03")
+			g.mu.Unlock()
+			return syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()})
+		}, map[string]string{
+			`immich_sync_assets_added_total{album="Trip"}`:      "4",
+			`immich_sync_assets_skipped_total{album="Trip"}`:    "2",
+			`immich_sync_assets_failed_total{album="Trip"}`:     "1",
+			`immich_sync_assets_restricted_total{album="Trip"}`: "1",
+			`immich_sync_bytes_downloaded_total{album="Trip"}`:  fmt.Sprint(4 * itemSize),
+		}},
+		{"scrape error", func() error {
+			return syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.URL + "/gone", AlbumName: "Gone"})
+		}, map[string]string{
+			`immich_sync_scrape_errors_total{album="Gone"}`:            "1",
+			`immich_sync_assets_added_total{album="Gone"}`:             "0",
+			`immich_sync_last_success_timestamp_seconds{album="Gone"}`: "0",
+			`immich_sync_assets_added_total{album="Trip"}`:             "4",
+			`immich_sync_scrape_errors_total{album="Trip"}`:            "0",
+		}},
+	}
+	for _, tt := range tests {
+		tt.sync()
+		samples := scrapeMetrics(t, prom)
+		for name, want := range tt.want {
+			if got, ok := samples[name]; !ok {
+				t.Errorf("%s: no %s sample", tt.name, name)
+			} else if got != want {
+				t.Errorf("%s: %s = %s, want %s", tt.name, name, got, want)
+			}
+		}
+		if v := samples[`immich_sync_last_success_timestamp_seconds{album="Trip"}`]; v == "" || v == "0" {
+			t.Errorf("%s: last success of Trip is %q, want a timestamp", tt.name, v)
+		}
+	}
+}
+
+func TestPrometheusLastSuccessOnlyForCompleteSyncs(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(4)...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{Workers: 1}, im)
+	prom := metrics.NewPrometheus(defaultStatsDPrefix)
+	a.Metrics = prom
+	ac := config.GooglePhotosConfig{URL: g.albumURL()}
+	lastSuccess := `immich_sync_last_success_timestamp_seconds{album="Trip"}`
+
+	// Cut short by the deadline: not a success
+	g.delay = 60 * time.Millisecond
+	ac.MaxSyncDuration = "50ms"
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if v := scrapeMetrics(t, prom)[lastSuccess]; v != "0" {
+		t.Errorf("last success after a deadline-cut sync is %q, want 0", v)
+	}
+
+	g.mu.Lock()
+	g.delay = 0
+	g.mu.Unlock()
+	ac.MaxSyncDuration = ""
+	if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+		t.Fatal(err)
+	}
+	if v := scrapeMetrics(t, prom)[lastSuccess]; v == "" || v == "0" {
+		t.Errorf("last success after a complete sync is %q, want a timestamp", v)
+	}
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+func TestLookupAssetLegacyNames(t *testing.T) {
+	// Assets uploaded before stable IDs, found by filename only
+	assets := map[string]string{
+		"gp_a_b":    "asset-of-a_b", // Current name of ID "a_b", and the legacy name of "a/b"
+		"gp_x.y_z":  "asset-of-x.y/z",
+		"gp_plain":  "asset-of-plain",
+		"gp_long.v": "asset-of-long.v",
+	}
+	tests := []struct {
+		id     string
+		want   string
+		wantOK bool
+	}{
+		{"plain", "asset-of-plain", true},
+		{"a_b", "asset-of-a_b", true},
+		{"a/b", "", false}, // Legacy name is another photo's current name
+		{"x.y/z", "asset-of-x.y/z", true},
+		{"long.v", "asset-of-long.v", true},
+		{"missing/one", "", false},
+	}
+	for _, tt := range tests {
+		id, ok := lookupAsset(assets, "KEY", googlephotos.Photo{ID: tt.id})
+		if id != tt.want || ok != tt.wantOK {
+			t.Errorf("lookupAsset(%q) = %q, %v, want %q, %v", tt.id, id, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestUnsafeIDsSyncOnce(t *testing.T) {
+	items := []fakeItem{
+		{ID: "a/b", Width: 400, Height: 300},
+		{ID: "a_b", Width: 400, Height: 300},
+		{ID: "x.y:z", Width: 400, Height: 300},
+	}
+	g := newFakeGoogle(t, "Trip", items...)
+	im := newFakeImmich(t)
+	a := newTestApp(t, &config.Config{}, im)
+	ac := config.GooglePhotosConfig{URL: g.albumURL()}
+
+	for run := 1; run <= 2; run++ {
+		if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(im.uploads()); n != len(items) {
+			t.Errorf("run %d: %d uploads, want one per item (%d)", run, n, len(items))
+		}
+	}
+	if n := len(im.album(im.albumNamed("Trip")).Assets); n != len(items) {
+		t.Errorf("album has %d assets, want %d", n, len(items))
+	}
+}
+
+func TestSourceMarkerMatchesItemByID(t *testing.T) {
+	tests := []struct {
+		name   string
+		marker func(g *fakeGoogle) string
+		upload bool
+	}{
+		{"same ID, new media URL", func(g *fakeGoogle) string { return "gp-source: https://lh3.googleusercontent.com/pw/old id=item00" }, false},
+		{"no ID, same media URL", func(g *fakeGoogle) string { return "gp-source: " + g.URL + "/m/item00" }, false},
+		{"no ID, new media URL", func(g *fakeGoogle) string { return "gp-source: https://lh3.googleusercontent.com/pw/old" }, true},
+		{"other item's ID, same media URL", func(g *fakeGoogle) string { return "gp-source: " + g.URL + "/m/item00 id=item99" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", fakeItem{ID: "item00", Width: 400, Height: 300})
+			im := newFakeImmich(t)
+			renamed := im.addAsset(fakeAsset{Name: "renamed.jpg", Description: "Beach\n" + tt.marker(g)})
+			im.addAlbum("Trip", renamed)
+			a := newTestApp(t, &config.Config{RecordSourceURL: true}, im)
+
+			if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+				t.Fatal(err)
+			}
+			if uploaded := len(im.uploads()) > 0; uploaded != tt.upload {
+				t.Errorf("uploaded: %v, want %v", uploaded, tt.upload)
+			}
+		})
+	}
+}
+
+func TestOriginalFilenames(t *testing.T) {
+	items := []fakeItem{
+		{ID: "orig1", Width: 400, Height: 300, Extra: `["IMG_1234.JPG"]`},
+		{ID: "caption1", Width: 400, Height: 300, Extra: `"beach.jpg"`}, // A caption, not a filename
+		{ID: "plain", Width: 400, Height: 300},
+	}
+	tests := []struct {
+		name      string
+		firstRun  bool // originalFilenames on the first run, the second always has it
+		wantNames []string
+	}{
+		{"enabled from the start", true, []string{"IMG_1234.JPG", "gp_caption1.jpg", "gp_plain.jpg"}},
+		{"enabled later", false, []string{"gp_caption1.jpg", "gp_orig1.jpg", "gp_plain.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", items...)
+			im := newFakeImmich(t)
+			ac := config.GooglePhotosConfig{URL: g.albumURL()}
+			a := newTestApp(t, &config.Config{OriginalFilenames: tt.firstRun}, im)
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, up := range im.uploads() {
+				names = append(names, up.Name)
+				if up.DeviceAssetID == "" {
+					t.Errorf("%s uploaded without a device asset ID", up.Name)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("uploaded %v, want %v", names, tt.wantNames)
+			}
+
+			// A fresh state has only Immich to go by: the ID, not the name, finds the assets
+			b := newTestApp(t, &config.Config{OriginalFilenames: true}, im)
+			if err := syncAlbum(t, context.Background(), b, ac); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(im.uploads()); n != len(items) {
+				t.Errorf("%d uploads after the second sync, want still %d", n, len(items))
+			}
+			if n := len(im.album(im.albumNamed("Trip")).Assets); n != len(items) {
+				t.Errorf("album has %d assets, want %d", n, len(items))
+			}
+		})
+	}
+}
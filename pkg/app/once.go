@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// RunOnce syncs every configured album exactly once, ignoring sync intervals and
+// the state file's schedule, then returns. For external schedulers (cron, systemd
+// timers, Kubernetes CronJobs). The error lists the albums that couldn't be scraped
+// and wraps googlephotos.ErrFormatChanged when any of them hit a page format change.
+func (a *App) RunOnce(ctx context.Context) error {
+	a.Logger.Info("Starting Immich Sync (single run)")
+	a.startServers()
+
+	id, name, err := a.Client.GetUser()
+	a.health.immichChecked(err)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Immich: %w", err)
+	}
+	a.Logger.Info("Connected to Immich", "user_id", id, "name", name)
+	if err := a.checkLibraries(); err != nil {
+		return err
+	}
+
+	if len(a.Cfg.GooglePhotos) == 0 {
+		a.Logger.Warn("No albums configured")
+		return nil
+	}
+
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		a.Logger.Warn("Failed to fetch Immich album list", "error", err)
+	}
+
+	albumWorkers := a.albumWorkers()
+	a.Logger.Info("Processing albums", "count", len(a.Cfg.GooglePhotos), "album_workers", albumWorkers)
+
+	sem := make(chan struct{}, albumWorkers)
+	var mu sync.Mutex
+	var failed []string
+	formatChanged := false
+	var wg sync.WaitGroup
+	for _, ac := range a.Cfg.GooglePhotos {
+		wg.Add(1)
+		go func(ac config.GooglePhotosConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			if err := a.processAlbum(ctx, ac, albumCache); err != nil {
+				mu.Lock()
+				failed = append(failed, ac.URL)
+				formatChanged = formatChanged || errors.Is(err, googlephotos.ErrFormatChanged)
+				mu.Unlock()
+			}
+		}(ac)
+	}
+	wg.Wait()
+	a.webhooks.Wait()
+
+	a.Logger.Info("Single run finished", "albums", len(a.Cfg.GooglePhotos), "failed", len(failed))
+	if formatChanged {
+		return fmt.Errorf("%w: %d album(s) could not be scraped: %s", googlephotos.ErrFormatChanged, len(failed), strings.Join(failed, ", "))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d album(s) could not be scraped: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
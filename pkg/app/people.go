@@ -0,0 +1,41 @@
+package app
+
+import (
+	"log/slog"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// tagPerson associates freshly uploaded assets with the album's configured Immich
+// person. Does nothing but log a warning if the person doesn't exist.
+func (a *App) tagPerson(personId string, photos []googlephotos.Photo, assetByPhoto map[string]string, uploadedIds []string, logger *slog.Logger) {
+	person, err := a.Client.GetPerson(personId)
+	if err != nil {
+		logger.Warn("Immich person not found, assets not tagged", "person_id", personId, "error", err)
+		return
+	}
+
+	uploaded := make(map[string]bool, len(uploadedIds))
+	for _, id := range uploadedIds {
+		uploaded[id] = true
+	}
+
+	tagged := 0
+	for _, p := range photos {
+		assetId := assetByPhoto[p.ID]
+		if !uploaded[assetId] {
+			continue
+		}
+		// Immich only needs a box relative to the image size, so unknown dimensions can use a unit box
+		w, h := p.Width, p.Height
+		if w <= 0 || h <= 0 {
+			w, h = 1, 1
+		}
+		if err := a.Client.TagPerson(assetId, person.Id, w, h); err != nil {
+			logger.Warn("Failed to tag person on asset", "id", assetId, "person", person.Name, "error", err)
+			continue
+		}
+		tagged++
+	}
+	logger.Info("Tagged uploaded assets with person", "person", person.Name, "count", tagged)
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultProgressLogItems    = 100
+	defaultProgressLogInterval = 30 * time.Second
+	progressLogMinGap          = time.Second // Debounce: never more than one progress line per second
+)
+
+// progressLog writes periodic progress lines for an album sync, for logs where
+// the progress bar isn't shown (debug, JSON) or as configured. A line is due
+// after every items processed items or interval, whichever comes first.
+type progressLog struct {
+	logger   *slog.Logger
+	total    int
+	every    int           // Items between lines, 0 disables
+	interval time.Duration // Time between lines, 0 disables
+	start    time.Time
+	base     int // Items already settled when the log started, left out of the ETA
+	last     time.Time
+	lastDone int
+}
+
+func newProgressLog(logger *slog.Logger, total, every int, interval time.Duration, base int, now time.Time) *progressLog {
+	return &progressLog{logger: logger, total: total, every: every, interval: interval, start: now, base: base, last: now, lastDone: base}
+}
+
+// update logs a progress line when one is due
+func (p *progressLog) update(now time.Time, processed, added, skipped, failed int) {
+	if processed <= p.lastDone || processed >= p.total || now.Sub(p.last) < progressLogMinGap {
+		return
+	}
+	byItems := p.every > 0 && processed-p.lastDone >= p.every
+	byTime := p.interval > 0 && now.Sub(p.last) >= p.interval
+	if !byItems && !byTime {
+		return
+	}
+	p.last, p.lastDone = now, processed
+
+	eta := "unknown"
+	if done := processed - p.base; done > 0 {
+		perItem := now.Sub(p.start) / time.Duration(done)
+		eta = (perItem * time.Duration(p.total-processed)).Round(time.Second).String()
+	}
+	p.logger.Info("Progress", "progress", fmt.Sprintf("%d/%d", processed, p.total), "percent", processed*100/p.total,
+		"added", added, "skipped", skipped, "failed", failed, "eta", eta)
+}
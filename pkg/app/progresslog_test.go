@@ -0,0 +1,75 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressLogCadence(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		every    int
+		interval time.Duration
+		base     int
+		step     time.Duration // Time between processed items
+		want     []string      // Progress of the lines logged
+	}{
+		{"every 10 items", 50, 10, 0, 0, 2 * time.Second, []string{"10/50", "20/50", "30/50", "40/50"}},
+		{"debounced", 30, 1, 0, 0, 100 * time.Millisecond, []string{"10/30", "20/30"}},
+		{"every 5s", 20, 0, 5 * time.Second, 0, 2 * time.Second, []string{"3/20", "6/20", "9/20", "12/20", "15/20", "18/20"}},
+		{"resumed album", 40, 10, 0, 15, 2 * time.Second, []string{"25/40", "35/40"}},
+		{"disabled", 50, 0, 0, 0, 2 * time.Second, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			p := newProgressLog(slog.New(slog.NewJSONHandler(&buf, nil)), tt.total, tt.every, tt.interval, tt.base, start)
+			for done := tt.base + 1; done <= tt.total; done++ {
+				p.update(start.Add(time.Duration(done-tt.base)*tt.step), done, done, 0, 0)
+			}
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if line == "" {
+					continue
+				}
+				var rec map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &rec); err != nil {
+					t.Fatalf("bad log line %q: %v", line, err)
+				}
+				got = append(got, rec["progress"].(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("progress lines %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressLogETA(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := newProgressLog(slog.New(slog.NewJSONHandler(&buf, nil)), 50, 10, 0, 0, start)
+	p.update(start.Add(20*time.Second), 10, 7, 2, 1) // 2s per item, 40 to go
+
+	type line struct {
+		Progress               string
+		Percent                int
+		Added, Skipped, Failed int
+		ETA                    string
+	}
+	var got line
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("bad log line %q: %v", buf.String(), err)
+	}
+	if want := (line{"10/50", 20, 7, 2, 1, "1m20s"}); got != want {
+		t.Errorf("progress line %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+func TestQualityFallbackOnlyForRestrictedImages(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     fakeItem
+		status   int
+		fallback bool
+	}{
+		{"restricted image", fakeItem{ID: "img"}, http.StatusForbidden, true},
+		{"server error", fakeItem{ID: "img"}, http.StatusInternalServerError, false},
+		{"restricted video", fakeItem{ID: "vid", Type: "video/mp4"}, http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.item.Width, tt.item.Height = 400, 300
+			g := newFakeGoogle(t, "Trip", tt.item)
+			g.status[tt.item.ID] = []int{tt.status}
+			im := newFakeImmich(t)
+			a := newTestApp(t, &config.Config{}, im)
+			ac := config.GooglePhotosConfig{URL: g.albumURL(), QualityFallback: []string{"=w2048"}}
+
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			fellBack := g.getCount(tt.item.ID+"=w2048") > 0
+			if fellBack != tt.fallback {
+				t.Errorf("fell back to =w2048: %v, want %v", fellBack, tt.fallback)
+			}
+			if uploaded := len(im.uploads()) > 0; uploaded != tt.fallback {
+				t.Errorf("uploaded: %v, want %v", uploaded, tt.fallback)
+			}
+		})
+	}
+}
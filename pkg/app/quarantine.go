@@ -0,0 +1,75 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// quarantineSidecar is written next to a quarantined file to explain why it wasn't uploaded
+type quarantineSidecar struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url"`
+	Album         string    `json:"album"`
+	AlbumURL      string    `json:"albumUrl"`
+	Reason        string    `json:"reason"`
+	Description   string    `json:"description,omitempty"`
+	Uploader      string    `json:"uploader,omitempty"`
+	Width         int       `json:"width,omitempty"`
+	Height        int       `json:"height,omitempty"`
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+}
+
+// quarantineItem downloads p into <QuarantineDir>/<album-slug>/ with a JSON sidecar
+// instead of uploading it. Items already quarantined by an earlier run are left alone.
+// Returns the number of bytes downloaded.
+func (a *App) quarantineItem(p googlephotos.Photo, job *albumSync, reason string) (int64, error) {
+	dir := filepath.Join(a.Cfg.QuarantineDir, slugify(job.Title))
+	baseName := photoBaseName(p)
+
+	matches, _ := filepath.Glob(filepath.Join(dir, baseName+".*"))
+	for _, m := range matches {
+		if !strings.HasSuffix(m, ".part") && !strings.HasSuffix(m, ".json") {
+			job.Logger.Debug("Item already quarantined", "id", p.ID, "file", m)
+			return 0, nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("error creating quarantine directory: %w", err)
+	}
+
+	r, size, ext, _, err := googlephotos.DownloadMedia(a.GPClient, p.URL, p.IsVideo)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	if err := writeFile(filepath.Join(dir, baseName+ext), r); err != nil {
+		return 0, fmt.Errorf("error writing quarantined item: %w", err)
+	}
+
+	sidecar := quarantineSidecar{
+		ID:            p.ID,
+		URL:           p.URL,
+		Album:         job.Title,
+		AlbumURL:      job.URL,
+		Reason:        reason,
+		Description:   p.Description,
+		Uploader:      p.Uploader,
+		Width:         p.Width,
+		Height:        p.Height,
+		QuarantinedAt: time.Now(),
+	}
+	data, _ := json.MarshalIndent(sidecar, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, baseName+".json"), data, 0o644); err != nil {
+		return size, fmt.Errorf("error writing quarantine sidecar: %w", err)
+	}
+	job.Logger.Info("Quarantined item", "id", p.ID, "reason", reason, "dir", dir)
+	return size, nil
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// scrapeRun summarizes a single scrape of an album
+type scrapeRun struct {
+	Count     int
+	Title     string
+	ZeroDates int
+	Recent    int // dated within the last 24h, usually a fallback rather than a real capture date
+	Err       error
+}
+
+// ScrapeBench scrapes every configured album runs times without touching Immich
+// and reports how item count, title and timestamps vary between responses.
+func (a *App) ScrapeBench(runs int) {
+	if runs < 1 {
+		runs = 1
+	}
+	for _, ac := range a.Cfg.GooglePhotos {
+		logger := a.Logger.With("album_url", ac.URL)
+		logger.Info("Scrape benchmark", "runs", runs)
+
+		var results []scrapeRun
+		var durations []time.Duration
+		for i := 0; i < runs; i++ {
+			start := time.Now()
+			album, err := googlephotos.RefetchAlbum(a.GPClient, ac.URL)
+			durations = append(durations, time.Since(start))
+			if err != nil {
+				logger.Warn("Scrape failed", "run", i+1, "error", err)
+				results = append(results, scrapeRun{Err: err})
+				continue
+			}
+			run := summarizeScrape(album)
+			logger.Debug("Scrape run", "run", i+1, "count", run.Count, "title", run.Title,
+				"zero_dates", run.ZeroDates, "recent_dates", run.Recent, "duration", durations[i])
+			results = append(results, run)
+		}
+
+		printScrapeStats(ac.URL, results, durations)
+	}
+}
+
+func summarizeScrape(album *googlephotos.Album) scrapeRun {
+	run := scrapeRun{Count: len(album.Photos), Title: album.Title}
+	recent := time.Now().Add(-24 * time.Hour)
+	for _, p := range album.Photos {
+		if p.TakenAt.IsZero() {
+			run.ZeroDates++
+		} else if p.TakenAt.After(recent) {
+			run.Recent++
+		}
+	}
+	return run
+}
+
+func printScrapeStats(albumURL string, results []scrapeRun, durations []time.Duration) {
+	var counts, zeros, recents []float64
+	titles := make(map[string]int)
+	failures := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failures++
+			continue
+		}
+		counts = append(counts, float64(r.Count))
+		zeros = append(zeros, float64(r.ZeroDates))
+		recents = append(recents, float64(r.Recent))
+		titles[r.Title]++
+	}
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+
+	fmt.Printf("\n== %s ==\n", albumURL)
+	fmt.Printf("runs: %d  failed: %d  avg duration: %s\n", len(results), failures, (total / time.Duration(len(durations))).Round(time.Millisecond))
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("items:         %s\n", formatStats(counts))
+	fmt.Printf("missing dates: %s\n", formatStats(zeros))
+	fmt.Printf("dated <24h:    %s\n", formatStats(recents))
+
+	names := make([]string, 0, len(titles))
+	for t := range titles {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	if len(names) > 1 {
+		fmt.Printf("titles:        %d distinct (nondeterministic)\n", len(names))
+	} else {
+		fmt.Printf("titles:        1 distinct\n")
+	}
+	for _, t := range names {
+		fmt.Printf("  %q x%d\n", t, titles[t])
+	}
+}
+
+// formatStats renders min/max/mean/stddev and flags any variance between runs
+func formatStats(values []float64) string {
+	min, max, sum := values[0], values[0], 0.0
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(values)))
+
+	s := fmt.Sprintf("min %.0f  max %.0f  mean %.1f  stddev %.1f", min, max, mean, stddev)
+	if min != max {
+		s += "  (varies)"
+	}
+	return s
+}
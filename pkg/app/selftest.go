@@ -0,0 +1,78 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"time"
+)
+
+// SelfTest verifies Immich connectivity and upload permissions end-to-end by
+// uploading a tiny generated image, reading it back and deleting it again.
+// Each step is reported; the first failing step aborts the test.
+func (a *App) SelfTest() error {
+	a.Logger.Info("Running self-test against Immich", "api_url", a.Cfg.ApiURL)
+
+	id, name, err := a.Client.GetUser()
+	if err != nil {
+		a.Logger.Error("Self-test: connect failed", "error", err)
+		return fmt.Errorf("connect: %w", err)
+	}
+	a.Logger.Info("Self-test: connect OK", "user_id", id, "name", name)
+
+	data, err := selfTestImage()
+	if err != nil {
+		return fmt.Errorf("generate test image: %w", err)
+	}
+
+	filename := fmt.Sprintf("immich-sync-selftest-%d.png", time.Now().Unix())
+	assetId, _, err := a.Client.UploadAssetStream(bytes.NewReader(data), filename, "", int64(len(data)), time.Now(), "immich-sync self-test asset", "")
+	if err != nil {
+		a.Logger.Error("Self-test: upload failed", "error", err)
+		return fmt.Errorf("upload: %w", err)
+	}
+	a.Logger.Info("Self-test: upload OK", "id", assetId, "filename", filename)
+
+	asset, err := a.Client.GetAsset(assetId)
+	if err != nil || asset.Id != assetId {
+		a.Logger.Error("Self-test: confirm failed", "id", assetId, "error", err)
+		// Still try to clean up the uploaded asset
+		if delErr := a.Client.DeleteAssets([]string{assetId}, true); delErr != nil {
+			a.Logger.Warn("Self-test: cleanup failed, please delete the asset manually", "id", assetId, "error", delErr)
+		}
+		if err == nil {
+			err = fmt.Errorf("asset %s not found after upload", assetId)
+		}
+		return fmt.Errorf("confirm: %w", err)
+	}
+	a.Logger.Info("Self-test: confirm OK", "id", asset.Id, "original_file_name", asset.OriginalFileName)
+
+	if err := a.Client.DeleteAssets([]string{assetId}, true); err != nil {
+		a.Logger.Error("Self-test: delete failed, please delete the asset manually", "id", assetId, "error", err)
+		return fmt.Errorf("delete: %w", err)
+	}
+	a.Logger.Info("Self-test: delete OK", "id", assetId)
+
+	a.Logger.Info("Self-test passed")
+	return nil
+}
+
+// selfTestImage generates a small PNG whose pixels vary per run,
+// so Immich's checksum dedup never matches a previous self-test upload
+func selfTestImage() ([]byte, error) {
+	seed := time.Now().UnixNano()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			v := seed >> uint((x+y*8)%56)
+			img.Set(x, y, color.RGBA{R: uint8(v), G: uint8(v >> 8), B: uint8(v >> 16), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+
+	"warreth.dev/immich-sync/pkg/metrics"
+)
+
+// httpServers hands out one HTTP server per port, so endpoints configured on the
+// same port (/metrics, /healthz) share a listener
+type httpServers struct {
+	logger *slog.Logger
+	mu     sync.Mutex
+	muxes  map[int]*http.ServeMux
+}
+
+func newHTTPServers(logger *slog.Logger) *httpServers {
+	return &httpServers{logger: logger, muxes: make(map[int]*http.ServeMux)}
+}
+
+// handle registers h at path on port, starting the port's server on first use
+func (s *httpServers) handle(port int, path string, h http.Handler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mux, ok := s.muxes[port]
+	if !ok {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return err
+		}
+		mux = http.NewServeMux()
+		s.muxes[port] = mux
+		go func() {
+			if err := http.Serve(ln, mux); err != nil {
+				s.logger.Warn("HTTP server stopped", "port", port, "error", err)
+			}
+		}()
+	}
+	mux.Handle(path, h)
+	return nil
+}
+
+// startServers binds the /healthz and Prometheus /metrics endpoints. Only the sync
+// runs call it, so one-shot commands (-status, -verify, ...) neither serve stale
+// health nor take the ports of a daemon that is already running.
+func (a *App) startServers() {
+	servers := newHTTPServers(a.Logger)
+	if a.Cfg.HealthPort > 0 {
+		if err := servers.handle(a.Cfg.HealthPort, "/healthz", a.health); err != nil {
+			a.Logger.Warn("Health endpoint disabled", "error", err)
+		} else {
+			a.Logger.Info("Serving health endpoint", "port", a.Cfg.HealthPort, "path", "/healthz", "stale_after", a.health.staleAfter)
+		}
+	}
+	if p, ok := a.Metrics.(*metrics.Prometheus); ok {
+		if err := servers.handle(a.Cfg.MetricsPort, "/metrics", p.Handler()); err != nil {
+			a.Logger.Warn("Metrics disabled", "backend", a.Cfg.MetricsBackend, "error", err)
+			a.Metrics = nil
+		} else {
+			a.Logger.Info("Serving Prometheus metrics", "port", a.Cfg.MetricsPort, "path", "/metrics")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"log/slog"
+	"strings"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+// shareAlbum shares the Immich album with the album's ShareWithUsers that don't
+// have access yet. Entries containing "@" are emails, resolved through the user
+// list; anything else is taken as a user ID. Failures are logged, not fatal: when
+// the user list can't be read, the album is still shared with the plain IDs.
+func (a *App) shareAlbum(album *immich.Album, ac config.GooglePhotosConfig, logger *slog.Logger) {
+	shared := map[string]bool{album.OwnerId: true}
+	for _, u := range album.AlbumUsers {
+		shared[u.User.Id] = true
+	}
+
+	var byEmail map[string]string
+	listed := false
+	var add []string
+	for _, entry := range ac.ShareWithUsers {
+		id := entry
+		if strings.Contains(entry, "@") {
+			if !listed {
+				listed = true
+				users, err := a.Client.GetUsers()
+				if err != nil {
+					logger.Warn("Could not list Immich users to resolve shareWithUsers emails, sharing with user IDs only", "error", err)
+				} else {
+					byEmail = make(map[string]string, len(users))
+					for _, u := range users {
+						byEmail[strings.ToLower(u.Email)] = u.Id
+					}
+				}
+			}
+			if byEmail == nil {
+				continue
+			}
+			var ok bool
+			if id, ok = byEmail[strings.ToLower(entry)]; !ok {
+				logger.Warn("No Immich user with this email, not sharing with it", "email", entry)
+				continue
+			}
+		}
+		if !shared[id] {
+			shared[id] = true
+			add = append(add, id)
+		}
+	}
+	if len(add) == 0 {
+		return
+	}
+
+	role := ac.ShareRole
+	if role == "" {
+		role = "editor"
+	}
+	if a.Cfg.DryRun {
+		logger.Info("Dry run: would share album", "users", len(add), "role", role)
+		return
+	}
+	if err := a.Client.AddAlbumUsers(album.Id, add, role); err != nil {
+		logger.Warn("Could not share album", "users", len(add), "error", err)
+		return
+	}
+	logger.Info("Shared album", "users", len(add), "role", role)
+}
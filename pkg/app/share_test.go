@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+// shareCalls returns the users each album share request added, as "id:role"
+func shareCalls(t *testing.T, im *fakeImmich) [][]string {
+	t.Helper()
+	var shares [][]string
+	for _, c := range im.callsTo("PUT", "albums/") {
+		if !strings.HasSuffix(c.Path, "/users") {
+			continue
+		}
+		var req struct {
+			AlbumUsers []struct{ UserId, Role string }
+		}
+		if err := json.Unmarshal(c.Body, &req); err != nil {
+			t.Fatalf("share payload %s: %v", c.Body, err)
+		}
+		var added []string
+		for _, u := range req.AlbumUsers {
+			added = append(added, u.UserId+":"+u.Role)
+		}
+		shares = append(shares, added)
+	}
+	return shares
+}
+
+func TestShareAlbum(t *testing.T) {
+	tests := []struct {
+		name        string
+		share       []string
+		role        string
+		usersStatus int      // Answer to listing users, 0 for the list
+		want        []string // Users added by the first sync, "id:role"
+	}{
+		{"ids and emails", []string{"u-partner", "Kid@Example.com"}, "", 0, []string{"u-partner:editor", "u-kid:editor"}},
+		{"viewer role", []string{"u-partner"}, "viewer", 0, []string{"u-partner:viewer"}},
+		{"owner and repeats skipped", []string{"owner", "u-partner", "partner@example.com"}, "", 0, []string{"u-partner:editor"}},
+		{"unknown email skipped", []string{"nobody@example.com", "u-partner"}, "", 0, []string{"u-partner:editor"}},
+		{"user list unavailable", []string{"kid@example.com", "u-partner"}, "", http.StatusInternalServerError, []string{"u-partner:editor"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", fakeItem{ID: "a", Width: 400, Height: 300})
+			im := newFakeImmich(t)
+			im.users = []immich.User{
+				{Id: "owner", Email: "owner@example.com"},
+				{Id: "u-partner", Email: "partner@example.com"},
+				{Id: "u-kid", Email: "kid@example.com"},
+				{Id: "u-friend", Email: "friend@example.com"},
+			}
+			if tt.usersStatus != 0 {
+				im.failWith("GET", "users", tt.usersStatus)
+			}
+			a := newTestApp(t, nil, im)
+			a.GPClient = g.contentClient()
+			ac := config.GooglePhotosConfig{URL: g.albumURL(), ShareWithUsers: tt.share, ShareRole: tt.role}
+
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			shares := shareCalls(t, im)
+			if len(shares) != 1 || strings.Join(shares[0], ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("first sync shared %v, want one request adding %v", shares, tt.want)
+			}
+			if users := im.album(im.albumNamed("Trip")).Users; len(users) != len(tt.want) {
+				t.Errorf("album shared with %d users, want %d", len(users), len(tt.want))
+			}
+
+			// A second sync leaves existing shares alone and adds only new entries
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			if shares := shareCalls(t, im); len(shares) != 1 {
+				t.Errorf("second sync re-shared: %v", shares[1:])
+			}
+			ac.ShareWithUsers = append(ac.ShareWithUsers, "u-friend")
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			role := tt.role
+			if role == "" {
+				role = "editor"
+			}
+			if shares := shareCalls(t, im); len(shares) != 2 || strings.Join(shares[1], ",") != "u-friend:"+role {
+				t.Errorf("adding a user shared %v, want only u-friend:%s", shares[1:], role)
+			}
+		})
+	}
+}
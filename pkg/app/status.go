@@ -0,0 +1,81 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+// albumStatus is what Status reports for one configured album
+type albumStatus struct {
+	Album    *immich.Album // nil when no Immich album could be resolved
+	Source   string        // How the album was resolved: "config", "state" or "title"
+	LastSync time.Time
+	Items    int // Items in the Google album at the last sync
+	Failed   int // Items that failed at the last sync
+	Interval time.Duration
+}
+
+// Status prints, for every configured album, the Immich album it syncs into,
+// its asset count and the last sync from the state file. Only Immich's album
+// list is read: nothing is scraped, uploaded or changed.
+func (a *App) Status() error {
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		return fmt.Errorf("error fetching Immich albums: %w", err)
+	}
+	for _, ac := range a.Cfg.GooglePhotos {
+		printStatus(os.Stdout, ac, a.albumStatus(ac, albumCache))
+	}
+	return nil
+}
+
+// albumStatus resolves an album like a sync would, except that without a
+// scrape the Google title is unknown: only a configured albumName is matched
+func (a *App) albumStatus(ac config.GooglePhotosConfig, albumCache []immich.Album) albumStatus {
+	st := a.State.Album(ac.URL)
+	s := albumStatus{LastSync: st.LastSync, Items: st.LastItemCount, Failed: st.LastFailed, Interval: syncInterval(ac)}
+
+	byID := func(id string) *immich.Album {
+		for i := range albumCache {
+			if albumCache[i].Id == id {
+				return &albumCache[i]
+			}
+		}
+		return nil
+	}
+	switch {
+	case ac.ImmichAlbumID != "":
+		s.Album, s.Source = byID(ac.ImmichAlbumID), "config"
+	case st.ImmichAlbumID != "" && byID(st.ImmichAlbumID) != nil:
+		s.Album, s.Source = byID(st.ImmichAlbumID), "state"
+	case ac.AlbumName != "":
+		if id := a.albumByTitle(ac.URL, ac.AlbumName, albumCache, a.Logger); id != "" {
+			s.Album, s.Source = byID(id), "title"
+		}
+	}
+	return s
+}
+
+func printStatus(w io.Writer, ac config.GooglePhotosConfig, s albumStatus) {
+	fmt.Fprintf(w, "\n%s (%s)\n", albumLogName(ac), ac.URL)
+	switch {
+	case s.Album != nil:
+		fmt.Fprintf(w, "  Immich album:  %s (%s, from %s)\n", s.Album.AlbumName, s.Album.Id, s.Source)
+		fmt.Fprintf(w, "  Assets:        %d\n", s.Album.AssetCount)
+	case s.Source == "config":
+		fmt.Fprintf(w, "  Immich album:  %s not found\n", ac.ImmichAlbumID)
+	default:
+		fmt.Fprintln(w, "  Immich album:  not resolved yet (found or created on the next sync)")
+	}
+	if s.LastSync.IsZero() {
+		fmt.Fprintln(w, "  Last sync:     never")
+	} else {
+		fmt.Fprintf(w, "  Last sync:     %s (%d items, %d failed)\n", s.LastSync.Format("2006-01-02 15:04:05"), s.Items, s.Failed)
+	}
+	fmt.Fprintf(w, "  Sync interval: %s\n", s.Interval)
+}
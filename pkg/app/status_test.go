@@ -0,0 +1,78 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+func TestAlbumStatus(t *testing.T) {
+	const url, other = "https://photos.app.goo.gl/trip", "https://photos.app.goo.gl/other"
+	lastSync := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		ac         config.GooglePhotosConfig
+		stateID    string // Immich album recorded in the state file, "gone" for a deleted one
+		otherOwns  bool   // Another album's state records the "Trip" album
+		wantAlbum  string // Name of the resolved Immich album, "" for none
+		wantSource string
+	}{
+		{"configured ID", config.GooglePhotosConfig{ImmichAlbumID: "beach"}, "trip", false, "Beach", "config"},
+		{"configured ID missing", config.GooglePhotosConfig{ImmichAlbumID: "nope"}, "trip", false, "", "config"},
+		{"state mapping", config.GooglePhotosConfig{AlbumName: "Beach"}, "trip", false, "Trip", "state"},
+		{"stale state, albumName", config.GooglePhotosConfig{AlbumName: "Trip"}, "gone", false, "Trip", "title"},
+		{"albumName of another album", config.GooglePhotosConfig{AlbumName: "Trip"}, "", true, "", ""},
+		{"nothing to go on", config.GooglePhotosConfig{}, "", false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im := newFakeImmich(t)
+			ids := map[string]string{"trip": im.addAlbum("Trip", "a1", "a2"), "beach": im.addAlbum("Beach"), "gone": "album-deleted"}
+			ac := tt.ac
+			ac.URL, ac.SyncInterval = url, "6h"
+			if ac.ImmichAlbumID != "" && ids[ac.ImmichAlbumID] != "" {
+				ac.ImmichAlbumID = ids[ac.ImmichAlbumID]
+			}
+			a := newTestApp(t, &config.Config{GooglePhotos: []config.GooglePhotosConfig{ac}}, im)
+			a.State.UpdateAlbum(url, func(st *state.AlbumState) {
+				st.ImmichAlbumID, st.LastSync, st.LastItemCount, st.LastFailed = ids[tt.stateID], lastSync, 12, 1
+			})
+			if tt.otherOwns {
+				a.State.UpdateAlbum(other, func(st *state.AlbumState) { st.ImmichAlbumID = ids["trip"] })
+			}
+
+			albums, err := a.Client.GetAlbums()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := a.albumStatus(ac, albums)
+			var name string
+			if s.Album != nil {
+				name = s.Album.AlbumName
+			}
+			if name != tt.wantAlbum || s.Source != tt.wantSource {
+				t.Errorf("resolved %q from %q, want %q from %q", name, s.Source, tt.wantAlbum, tt.wantSource)
+			}
+			if !s.LastSync.Equal(lastSync) || s.Items != 12 || s.Failed != 1 || s.Interval != 6*time.Hour {
+				t.Errorf("status %+v, want the state file's last sync and a 6h interval", s)
+			}
+			im.mu.Lock()
+			for _, c := range im.calls {
+				if c.Method != "GET" {
+					t.Errorf("status made a %s %s request, want read-only calls", c.Method, c.Path)
+				}
+			}
+			im.mu.Unlock()
+
+			var out bytes.Buffer
+			printStatus(&out, ac, s)
+			if !strings.Contains(out.String(), "Last sync:     2024-05-01 12:00:00 (12 items, 1 failed)") {
+				t.Errorf("printed status lacks the last sync:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// applyTags attaches the album's configured Immich tags to assetIds. Missing tags
+// are created with a single upsert per album sync, not per asset.
+func (a *App) applyTags(names []string, assetIds []string, logger *slog.Logger) {
+	var wanted []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			seen[name] = true
+			wanted = append(wanted, name)
+		}
+	}
+	if len(wanted) == 0 || len(assetIds) == 0 {
+		return
+	}
+
+	tags, err := a.Client.UpsertTags(wanted)
+	if err != nil {
+		logger.Warn("Could not create Immich tags, assets not tagged", "tags", strings.Join(wanted, ", "), "error", err)
+		return
+	}
+	tagIds := make([]string, 0, len(tags))
+	for _, t := range tags {
+		tagIds = append(tagIds, t.Id)
+	}
+	if err := a.Client.TagAssets(tagIds, assetIds); err != nil {
+		logger.Warn("Failed to tag assets", "tags", strings.Join(wanted, ", "), "error", err)
+		return
+	}
+	logger.Info("Tagged assets", "tags", strings.Join(wanted, ", "), "count", len(assetIds))
+}
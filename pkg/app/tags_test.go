@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+func TestAlbumTags(t *testing.T) {
+	tests := []struct {
+		name       string
+		tags       []string
+		failUpsert bool
+		wantUpsert []string // Names sent in the single tag upsert, nil for none
+		wantTagged bool
+	}{
+		{"deduplicated", []string{"Trips", " Trips ", "Family/Kids", "", "Trips"}, false, []string{"Trips", "Family/Kids"}, true},
+		{"none configured", nil, false, nil, false},
+		{"only blanks", []string{" ", ""}, false, nil, false},
+		{"upsert fails", []string{"Trips"}, true, []string{"Trips"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t, "Trip", testItems(6)...)
+			im := newFakeImmich(t)
+			if tt.failUpsert {
+				im.failWith("PUT", "tags", http.StatusBadRequest)
+			}
+			a := newTestApp(t, &config.Config{Workers: 3}, im)
+			ac := config.GooglePhotosConfig{URL: g.albumURL(), Tags: tt.tags}
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+
+			upserts := im.callsTo("PUT", "tags")
+			var created []string
+			var tagCalls int
+			for _, c := range upserts {
+				if c.Path != "tags" {
+					continue
+				}
+				tagCalls++
+				var req struct{ Tags []string }
+				json.Unmarshal(c.Body, &req)
+				created = append(created, req.Tags...)
+			}
+			if tt.wantUpsert == nil && tagCalls != 0 || tt.wantUpsert != nil && tagCalls != 1 {
+				t.Fatalf("%d tag upserts, want at most one per sync", tagCalls)
+			}
+			if !reflect.DeepEqual(created, tt.wantUpsert) {
+				t.Errorf("upserted tags %q, want %q", created, tt.wantUpsert)
+			}
+
+			var tagIds, tagged []string
+			for _, c := range im.callsTo("PUT", "tags/assets") {
+				var req struct{ TagIds, AssetIds []string }
+				json.Unmarshal(c.Body, &req)
+				tagIds = req.TagIds
+				tagged = append(tagged, req.AssetIds...)
+			}
+			if !tt.wantTagged {
+				if len(tagged) != 0 {
+					t.Errorf("tagged %d assets, want none", len(tagged))
+				}
+				return
+			}
+			var uploaded []string
+			for _, u := range im.uploads() {
+				uploaded = append(uploaded, u.ID)
+			}
+			sort.Strings(tagged)
+			sort.Strings(uploaded)
+			if !reflect.DeepEqual(tagged, uploaded) {
+				t.Errorf("tagged %v, want every upload %v", tagged, uploaded)
+			}
+			var wantIds []string
+			for _, name := range tt.wantUpsert {
+				wantIds = append(wantIds, "tag-"+name)
+			}
+			if !reflect.DeepEqual(tagIds, wantIds) {
+				t.Errorf("assets got tag IDs %q, want %q", tagIds, wantIds)
+			}
+
+			// Nothing new on the next sync, so nothing to tag
+			before := len(im.callsTo("PUT", "tags"))
+			if err := syncAlbum(t, context.Background(), a, ac); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(im.callsTo("PUT", "tags")) - before; n != 0 {
+				t.Errorf("%d tag requests on a sync without new assets, want none", n)
+			}
+		})
+	}
+}
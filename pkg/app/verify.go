@@ -0,0 +1,53 @@
+package app
+
+import "fmt"
+
+// Verify checks every configured album against its Immich album and reports
+// scraped items without an asset (missing) and imported assets whose item is
+// gone from Google (orphans). Nothing is uploaded or deleted. Returns an error
+// when an album has missing items or could not be checked.
+func (a *App) Verify() error {
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		return fmt.Errorf("error fetching Immich albums: %w", err)
+	}
+
+	var failedAlbums, incomplete int
+	for _, ac := range a.Cfg.GooglePhotos {
+		d, err := a.diffAlbum(ac, albumCache)
+		if err != nil {
+			a.Logger.Error("Error verifying album", "album_url", ac.URL, "error", err)
+			failedAlbums++
+			continue
+		}
+
+		logger := a.Logger.With("album", d.Title)
+		if d.AlbumID == "" {
+			logger.Warn("Immich album not created yet", "missing", len(d.OnlyInGoogle))
+		} else {
+			msg := "Album verified"
+			if len(d.OnlyInGoogle) > 0 || len(d.OnlyInImmich) > 0 {
+				msg = "Album out of sync"
+			}
+			logger.Info(msg, "google", d.GoogleCount, "immich", d.ImmichCount,
+				"missing", len(d.OnlyInGoogle), "orphans", len(d.OnlyInImmich))
+		}
+		for _, p := range d.OnlyInGoogle {
+			logger.Debug("Missing in Immich", "photo_id", p.ID, "name", photoBaseName(p))
+		}
+		for _, name := range d.OnlyInImmich {
+			logger.Debug("Orphan in Immich", "name", name)
+		}
+		if len(d.OnlyInGoogle) > 0 {
+			incomplete++
+		}
+	}
+
+	if failedAlbums > 0 {
+		return fmt.Errorf("%d album(s) could not be verified", failedAlbums)
+	}
+	if incomplete > 0 {
+		return fmt.Errorf("%d album(s) have items missing in Immich", incomplete)
+	}
+	return nil
+}
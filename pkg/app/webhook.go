@@ -0,0 +1,66 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds a webhook POST so a dead endpoint never holds anything up
+const webhookTimeout = 10 * time.Second
+
+// syncSummary is POSTed to webhookURL after each album sync. Text and Content
+// carry the same one-line message so Slack and Discord incoming webhooks can
+// take the payload as-is.
+type syncSummary struct {
+	Album           string  `json:"album"`
+	URL             string  `json:"url"`
+	Added           int     `json:"added"`
+	Skipped         int     `json:"skipped"`
+	Failed          int     `json:"failed"`
+	Restricted      int     `json:"restricted"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+	Text            string  `json:"text"`
+	Content         string  `json:"content"`
+}
+
+// notifyWebhook sends the summary in the background so syncing never waits on
+// it, except at the end of a run. Failures are only logged.
+func (a *App) notifyWebhook(s syncSummary, started time.Time, logger *slog.Logger) {
+	if a.Cfg.WebhookURL == "" {
+		return
+	}
+	duration := time.Since(started).Round(time.Second)
+	s.DurationSeconds = duration.Seconds()
+	if s.Error != "" {
+		s.Text = fmt.Sprintf("Immich Sync: album %q failed after %s: %s", s.Album, duration, s.Error)
+	} else {
+		s.Text = fmt.Sprintf("Immich Sync: album %q synced in %s, %d added, %d skipped, %d failed",
+			s.Album, duration, s.Added, s.Skipped, s.Failed)
+	}
+	s.Content = s.Text
+
+	body, err := json.Marshal(s)
+	if err != nil {
+		logger.Warn("Could not encode webhook payload", "error", err)
+		return
+	}
+	a.webhooks.Add(1)
+	go func() {
+		defer a.webhooks.Done()
+		client := &http.Client{Timeout: webhookTimeout}
+		resp, err := client.Post(a.Cfg.WebhookURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			logger.Warn("Webhook notification failed", "error", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			logger.Warn("Webhook notification rejected", "status", resp.StatusCode)
+		}
+	}()
+}
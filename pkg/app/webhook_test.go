@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+func TestWebhookPayload(t *testing.T) {
+	g := newFakeGoogle(t, "Trip", testItems(3)...)
+	g.status["item02"] = []int{500, 500, 500, 500}
+
+	tests := []struct {
+		name     string
+		ac       config.GooglePhotosConfig
+		wantErr  bool
+		want     map[string]interface{} // Fields with known values
+		wantKeys []string
+		wantText string // Start of the text line
+	}{
+		{"success", config.GooglePhotosConfig{URL: g.albumURL()}, false,
+			map[string]interface{}{"album": "Trip", "url": g.albumURL(), "added": 2.0, "skipped": 0.0, "failed": 1.0, "restricted": 0.0},
+			[]string{"added", "album", "content", "durationSeconds", "failed", "restricted", "skipped", "text", "url"},
+			`Immich Sync: album "Trip" synced in `},
+		{"scrape failure", config.GooglePhotosConfig{URL: g.URL + "/gone", AlbumName: "Gone"}, true,
+			map[string]interface{}{"album": "Gone", "url": g.URL + "/gone", "added": 0.0, "failed": 0.0},
+			[]string{"added", "album", "content", "durationSeconds", "error", "failed", "restricted", "skipped", "text", "url"},
+			`Immich Sync: album "Gone" failed after `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var posts []map[string]interface{}
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("webhook got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+				}
+				raw, _ := io.ReadAll(r.Body)
+				var payload map[string]interface{}
+				if err := json.Unmarshal(raw, &payload); err != nil {
+					t.Errorf("webhook body %s: %v", raw, err)
+				}
+				mu.Lock()
+				posts = append(posts, payload)
+				mu.Unlock()
+			}))
+			defer hook.Close()
+
+			im := newFakeImmich(t)
+			a := newTestApp(t, &config.Config{Workers: 1, WebhookURL: hook.URL}, im)
+			if err := syncAlbum(t, context.Background(), a, tt.ac); (err != nil) != tt.wantErr {
+				t.Fatalf("sync error %v, want error: %v", err, tt.wantErr)
+			}
+			a.webhooks.Wait()
+
+			if len(posts) != 1 {
+				t.Fatalf("webhook called %d times, want once", len(posts))
+			}
+			got := posts[0]
+			var keys []string
+			for k := range got {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("payload fields %v, want %v", keys, tt.wantKeys)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %v", k, got[k], v)
+				}
+			}
+			text, _ := got["text"].(string)
+			if !strings.HasPrefix(text, tt.wantText) || got["content"] != text {
+				t.Errorf("text %q, content %q, want both to start with %q", text, got["content"], tt.wantText)
+			}
+			if tt.wantErr && (got["error"] == "" || !strings.Contains(text, got["error"].(string))) {
+				t.Errorf("error %q not reported in text %q", got["error"], text)
+			}
+		})
+	}
+}
+
+func TestDeadWebhookDoesNotFailSync(t *testing.T) {
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer rejecting.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close() // Connections are refused from here on
+
+	for _, url := range []string{rejecting.URL, closed.URL} {
+		g := newFakeGoogle(t, "Trip", testItems(2)...)
+		im := newFakeImmich(t)
+		a := newTestApp(t, &config.Config{WebhookURL: url}, im)
+		if err := syncAlbum(t, context.Background(), a, config.GooglePhotosConfig{URL: g.albumURL()}); err != nil {
+			t.Fatalf("sync failed with a dead webhook: %v", err)
+		}
+		a.webhooks.Wait()
+		if n := len(im.uploads()); n != 2 {
+			t.Errorf("uploaded %d items, want 2", n)
+		}
+	}
+}
@@ -2,27 +2,188 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 )
 
+// downloadQualityRe matches Google Photos size parameters: width, height or longest side in pixels
+var downloadQualityRe = regexp.MustCompile(`^=?[whs][1-9][0-9]{0,4}$`)
+
 type GooglePhotosConfig struct {
 	URL           string `json:"url"`
-	ImmichAlbumID string `json:"immichAlbumId"`     // Optional, if existing
-	AlbumName     string `json:"albumName"`         // Optional, to create new
-	SyncInterval  string `json:"syncInterval"`      // e.g., "12h", "60m"
+	ImmichAlbumID string `json:"immichAlbumId"` // Optional, if existing
+	AlbumName     string `json:"albumName"`     // Optional, to create new
+	SyncInterval  string `json:"syncInterval"`  // e.g., "12h", "60m"
+
+	MinAspectRatio       float64 `json:"minAspectRatio"`       // Optional, skip items with width/height below this
+	MaxAspectRatio       float64 `json:"maxAspectRatio"`       // Optional, skip items with width/height above this
+	ExcludeUnknownAspect bool    `json:"excludeUnknownAspect"` // Optional, skip items with missing dimensions when an aspect filter is set
+
+	MinWidth           int  `json:"minWidth"`           // Optional, skip items narrower than this many pixels
+	MinHeight          int  `json:"minHeight"`          // Optional, skip items shorter than this many pixels
+	ExcludeUnknownSize bool `json:"excludeUnknownSize"` // Optional, with minWidth/minHeight set, also skip items with missing dimensions
+
+	ImportCover bool `json:"importCover"` // Optional, import the Google Photos cover first and use it as the Immich album cover
+
+	OnlyUploaders          []string `json:"onlyUploaders"`          // Optional, only import items added by these contributors (display names)
+	ExcludeUnknownUploader bool     `json:"excludeUnknownUploader"` // Optional, with onlyUploaders set, also skip items whose uploader is unknown
+
+	PreferVariant string `json:"preferVariant"` // Optional, "original" or "edited": import only one copy of photos listed twice after an edit
+
+	LockedFolder bool `json:"lockedFolder"` // Optional, move uploaded assets into Immich's locked folder (Immich v1.133+)
+
+	NoCreate bool `json:"noCreate"` // Optional, only sync into an existing Immich album, skip the album if it isn't found
+
+	AlsoAddTo []string `json:"alsoAddTo"` // Optional, more Immich albums (IDs or names) that get the same assets without re-downloading
+
+	DateOffset string `json:"dateOffset"` // Optional, shift every item's date before upload (e.g. "8760h", "-2h")
+
+	PersonID string `json:"personId"` // Optional, Immich person ID to tag on every uploaded asset (Immich v1.125+)
+
+	QualityFallback []string `json:"qualityFallback"` // Optional, lower-quality renditions tried when the original fails (e.g. ["=w2048", "=w512"])
+
+	Tags []string `json:"tags"` // Optional, Immich tags (created if missing, "Parent/Child" for nesting) attached to synced assets (Immich v1.118+)
+
+	StartDate          string `json:"startDate"`          // Optional, skip items taken before this date (YYYY-MM-DD or RFC3339)
+	EndDate            string `json:"endDate"`            // Optional, skip items taken after this date (YYYY-MM-DD is inclusive, or RFC3339)
+	ExcludeUnknownDate bool   `json:"excludeUnknownDate"` // Optional, with startDate/endDate set, also skip items without a date
+
+	AlbumDescription string `json:"albumDescription"` // Optional, description for a newly created Immich album (default "Synced from <url>")
+
+	ImmichLibraryID string `json:"immichLibraryId"` // Optional, overrides the global immichLibraryId for this album
+
+	AutoArchive  bool `json:"autoArchive"`  // Optional, archive newly uploaded assets (hidden from the timeline)
+	AutoFavorite bool `json:"autoFavorite"` // Optional, mark newly uploaded assets as favorites
+
+	MaxSyncDuration string `json:"maxSyncDuration"` // Optional, stop starting new items once an album sync has run this long (e.g. "30m")
+
+	MaxItems int `json:"maxItems"` // Optional, only sync the first N items left after filtering (0 = all)
+
+	RawTitle bool `json:"rawTitle"` // Optional, keep the Google album title as-is (no date suffix or emoji cleanup)
+
+	ShareWithUsers []string `json:"shareWithUsers"` // Optional, Immich user IDs or emails to share the album with, checked on every sync
+	ShareRole      string   `json:"shareRole"`      // Optional, "editor" (default) or "viewer" for shareWithUsers
 }
 
 type Config struct {
-	ApiKey         string               `json:"apiKey"`
-	ApiURL         string               `json:"apiURL"`
-	Debug          bool                 `json:"debug"`          // Optional, enable verbose logging
-	Workers        int                  `json:"workers"`        // Optional, default 1
-	AlbumWorkers   int                  `json:"albumWorkers"`   // Optional, concurrent album processing (default 1)
-	StrictMetadata bool                 `json:"strictMetadata"` // Optional, skip items with missing dates
-	SkipVideos     bool                 `json:"skipVideos"`     // Optional, skip video items entirely
-	GooglePhotos   []GooglePhotosConfig `json:"googlePhotos"`
+	ApiKey          string               `json:"apiKey"`
+	ApiURL          string               `json:"apiURL"`
+	Debug           bool                 `json:"debug"`           // Optional, enable verbose logging
+	Workers         int                  `json:"workers"`         // Optional, default 1
+	AlbumWorkers    int                  `json:"albumWorkers"`    // Optional, concurrent album processing (default 1)
+	StrictMetadata  bool                 `json:"strictMetadata"`  // Optional, skip items with missing dates
+	SkipVideos      bool                 `json:"skipVideos"`      // Optional, skip video items entirely
+	RecordSourceURL bool                 `json:"recordSourceURL"` // Optional, append a machine-readable source URL and item ID marker to descriptions
+	GooglePhotos    []GooglePhotosConfig `json:"googlePhotos"`
+
+	StateFile             string `json:"stateFile"`             // Optional, path of the JSON file persisting sync state between runs
+	EmptyScrapeRetries    int    `json:"emptyScrapeRetries"`    // Optional, re-scrapes when a previously non-empty album returns 0 items (default 2, negative disables)
+	EmptyScrapeRetryDelay string `json:"emptyScrapeRetryDelay"` // Optional, wait before each of those re-scrapes (default "30s", "0" re-scrapes at once)
+	InvalidSyncInterval   string `json:"invalidSyncInterval"`   // Optional, "error" (default) fails on load, "warn" logs and falls back to 24h
+
+	DownloadAccept     string `json:"downloadAccept"`     // Optional, Accept header for image downloads (e.g. "image/jpeg")
+	ReplaceOnHigherRes bool   `json:"replaceOnHigherRes"` // Optional, re-upload and replace assets when Google serves a larger original
+
+	MaxDescriptionLength int    `json:"maxDescriptionLength"` // Optional, max characters of the composed description (default 2000, negative disables)
+	WorkerRampUp         string `json:"workerRampUp"`         // Optional, spread worker start-up over this duration (e.g. "10s")
+	QuickCheck           bool   `json:"quickCheck"`           // Optional, skip single-page albums whose item count hasn't changed
+
+	MaxVideoBytes int64 `json:"maxVideoBytes"` // Optional, skip videos larger than this many bytes
+
+	ApiBasePath string `json:"apiBasePath"` // Optional, path prefix appended to apiURL for reverse-proxy subpaths (e.g. "/immich/api")
+
+	RefreshExpiredURLs bool `json:"refreshExpiredURLs"` // Optional, re-scrape the album once per run to retry downloads rejected with 403
+
+	AlbumLogFiles bool   `json:"albumLogFiles"` // Optional, also write each album run to its own log file
+	AlbumLogDir   string `json:"albumLogDir"`   // Optional, directory for per-run album log files (default "logs")
+
+	FailedRetryDelay string `json:"failedRetryDelay"` // Optional, wait before retrying failed items once at the end of a run (default "10s", "0" disables)
+
+	MetricsBackend string `json:"metricsBackend"` // Optional, "statsd" to push per-album counters after each sync, "prometheus" to serve /metrics
+	StatsDAddress  string `json:"statsdAddress"`  // Optional, StatsD host:port (default "127.0.0.1:8125")
+	StatsDPrefix   string `json:"statsdPrefix"`   // Optional, metric name prefix (default "immich_sync")
+	MetricsPort    int    `json:"metricsPort"`    // Optional, port of the Prometheus /metrics endpoint (required for "prometheus")
+
+	HealthPort       int    `json:"healthPort"`       // Optional, serve /healthz on this port (may be the same as metricsPort)
+	HealthStaleAfter string `json:"healthStaleAfter"` // Optional, /healthz turns unhealthy when no album sync succeeded for this long (default twice the longest syncInterval)
+
+	DescriptionCaptionSeparator string `json:"descriptionCaptionSeparator"` // Optional, text between the caption and the source lines (default "\n\n")
+	DescriptionLineSeparator    string `json:"descriptionLineSeparator"`    // Optional, text between source lines (default "\n")
+	SourceAlbumLabel            string `json:"sourceAlbumLabel"`            // Optional, label before the album title (default "Source Album: ")
+	SharedByLabel               string `json:"sharedByLabel"`               // Optional, label before the contributor name (default "Shared by: ")
+	AddSourceFooter             *bool  `json:"addSourceFooter"`             // Optional, append the "Source Album: <title> (<url>)" line to descriptions (default true)
+	AddUploaderLine             *bool  `json:"addUploaderLine"`             // Optional, append the "Shared by: <name>" line to descriptions (default true)
+
+	ProgressLogItems    int    `json:"progressLogItems"`    // Optional, log a progress line every N items (default 100 with debug or JSON logs, else off; -1 disables)
+	ProgressLogInterval string `json:"progressLogInterval"` // Optional, log a progress line at least this often (default "30s" with debug or JSON logs, else off; "0" disables)
+
+	CycleCooldown   string `json:"cycleCooldown"`   // Optional, pause after every album has been synced once (e.g. "1h")
+	PollInterval    string `json:"pollInterval"`    // Optional, longest the scheduler sleeps before checking for due albums again (default "1m")
+	MinPollInterval string `json:"minPollInterval"` // Optional, shortest the scheduler sleeps between checks (default "1s")
+
+	QuarantineDir string `json:"quarantineDir"` // Optional, with strictMetadata, save items with missing dates here instead of skipping them
+
+	ScrapeCacheDir string `json:"scrapeCacheDir"` // Optional, directory caching album pages between runs (for debugging)
+	ScrapeCacheTTL string `json:"scrapeCacheTTL"` // Optional, how long a cached album page is reused (e.g. "30m"), cache is off when unset
+
+	MaxConnections int `json:"maxConnections"` // Optional, cap on concurrent HTTP requests across Google and Immich (minimum 2)
+
+	VerifyMediaType bool `json:"verifyMediaType"` // Optional, check downloaded bytes are really a video/image before uploading
+
+	GoogleCookies    string `json:"googleCookies"`    // Optional, Google session cookies ("SID=...; HSID=...") for albums shared to specific accounts
+	GoogleCookieFile string `json:"googleCookieFile"` // Optional, Netscape cookies.txt with Google session cookies
+
+	PairLivePhotos bool `json:"pairLivePhotos"` // Optional, link still/video items of one live photo (same motion clip or filename stem) in Immich
+
+	MaxRedirects         int  `json:"maxRedirects"`         // Optional, redirects followed per Google request (default 10)
+	StopOnSignInRedirect bool `json:"stopOnSignInRedirect"` // Optional, fail fast when Google redirects to its sign-in page
+
+	MaxConcurrentProbes int `json:"maxConcurrentProbes"` // Optional, HEAD probes in flight at once across all workers (default unlimited)
+
+	DryRun bool `json:"dryRun"` // Optional, log what would be uploaded/skipped without changing Immich
+
+	WriteExifDates bool `json:"writeExifDates"` // Optional, embed the taken date into JPEGs without an EXIF capture date
+
+	RunOnce bool `json:"runOnce"` // Optional, sync every album once and exit (for cron-style schedulers)
+
+	RespectDeletions bool `json:"respectDeletions"` // Optional, don't re-upload items deleted from the Immich album after they were synced
+
+	DownloadRetries    int    `json:"downloadRetries"`    // Optional, extra attempts for downloads failing on network errors (default 2, -1 disables)
+	DownloadRetryDelay string `json:"downloadRetryDelay"` // Optional, wait before the first download retry, doubled per attempt (default "2s")
+
+	SpoolThresholdBytes int64  `json:"spoolThresholdBytes"` // Optional, downloads larger than this go to a temp file instead of memory (default 0, all in memory)
+	SpoolDir            string `json:"spoolDir"`            // Optional, directory for spooled downloads (default system temp dir)
+	DownloadResumes     int    `json:"downloadResumes"`     // Optional, Range requests continuing a spooled download cut off midway (default 3, -1 disables)
+
+	WebhookURL string `json:"webhookURL"` // Optional, POST a JSON summary here after each album sync (Slack/Discord compatible)
+
+	GoogleMaxRetries        int     `json:"googleMaxRetries"`        // Optional, attempts per Google request answered with 429/5xx (default 5)
+	GoogleRetryBackoff      string  `json:"googleRetryBackoff"`      // Optional, wait after the first 429/5xx, grows linearly per attempt (default "5s"); Retry-After wins
+	GoogleRequestDelay      string  `json:"googleRequestDelay"`      // Optional, minimum pause before every Google request with googleRequestsPerSecond -1 (default "100ms", "0" disables)
+	GoogleRequestJitter     string  `json:"googleRequestJitter"`     // Optional, random extra pause on top of googleRequestDelay (default "250ms", "0" disables)
+	GoogleRequestsPerSecond float64 `json:"googleRequestsPerSecond"` // Optional, ceiling for all Google requests together, replaces delay and jitter; halved on 429 and recovered gradually (default 4, -1 disables)
+
+	ChecksumDedup         bool `json:"checksumDedup"`         // Optional, skip uploading files Immich already has (same SHA-1) and add the existing asset instead
+	VerifyUploads         bool `json:"verifyUploads"`         // Optional, compare each upload's SHA-1 with Immich's checksum and delete mismatching assets
+	KeepMismatchedUploads bool `json:"keepMismatchedUploads"` // Optional, with verifyUploads only report mismatching assets instead of deleting them
+
+	OriginalFilenames bool `json:"originalFilenames"` // Optional, name assets after the original Google filename when known instead of gp_<id>
+
+	ProxyURL string `json:"proxyURL"` // Optional, proxy for Google Photos requests (http://, https:// or socks5://, credentials allowed)
+
+	DownloadQuality string `json:"downloadQuality"` // Optional, "original" (default) or a size like "w2048" for image downloads, videos stay original
+
+	ImmichLibraryID string `json:"immichLibraryId"` // Optional, Immich library to upload into (default the user's upload library)
+
+	LogFormat string `json:"logFormat"` // Optional, "text" (default) or "json" for one JSON object per log line
+
+	AlbumAddBatchSize int `json:"albumAddBatchSize"` // Optional, asset IDs per Immich "add to album" request (default 250)
 }
 
 func ReadConfig(path string) (*Config, error) {
@@ -58,6 +219,142 @@ func ReadConfig(path string) (*Config, error) {
 	// Override/Fallback with ENV
 	if config.ApiKey == "" { config.ApiKey = os.Getenv("IMMICH_API_KEY") }
 	if config.ApiURL == "" { config.ApiURL = os.Getenv("IMMICH_API_URL") }
-	
+
 	return &config, nil
 }
+
+// CheckSyncIntervals returns one error per album whose syncInterval cannot be parsed.
+// An empty interval is valid and means the 24h default.
+func (c *Config) CheckSyncIntervals() []error {
+	var errs []error
+	for _, ac := range c.GooglePhotos {
+		if ac.SyncInterval == "" {
+			continue
+		}
+		d, err := time.ParseDuration(ac.SyncInterval)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid syncInterval %q for album %s: %w", ac.SyncInterval, ac.URL, err))
+		} else if d <= 0 {
+			errs = append(errs, fmt.Errorf("invalid syncInterval %q for album %s: must be positive", ac.SyncInterval, ac.URL))
+		}
+	}
+	return errs
+}
+
+// Validate checks the settings that would otherwise only fail deep inside a sync
+// and returns every problem at once. Sync intervals are only reported when
+// invalidSyncInterval isn't "warn".
+func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// ValidateOffline is Validate for modes that never talk to Immich (-export,
+// -scrapebench), so apiURL and apiKey aren't required
+func (c *Config) ValidateOffline() error {
+	return c.validate(false)
+}
+
+func (c *Config) validate(immich bool) error {
+	var errs []error
+	if immich {
+		if u, err := url.Parse(c.ApiURL); c.ApiURL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("apiURL %q must be an http(s) URL such as http://immich:2283/api", c.ApiURL))
+		}
+		if c.ApiKey == "" {
+			errs = append(errs, errors.New("apiKey is missing (set it in config.json or IMMICH_API_KEY)"))
+		}
+	}
+	if c.Workers < 0 {
+		errs = append(errs, fmt.Errorf("workers must be 0 or more, got %d", c.Workers))
+	}
+	if c.AlbumWorkers < 0 {
+		errs = append(errs, fmt.Errorf("albumWorkers must be 0 or more, got %d", c.AlbumWorkers))
+	}
+	if c.GoogleRequestsPerSecond < 0 && c.GoogleRequestsPerSecond != -1 {
+		errs = append(errs, fmt.Errorf("googleRequestsPerSecond must be more than 0, or -1 to disable it, got %g", c.GoogleRequestsPerSecond))
+	}
+	if c.FailedRetryDelay != "" {
+		if d, err := time.ParseDuration(c.FailedRetryDelay); err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("failedRetryDelay must be a duration like \"10s\", or \"0\" to disable retries, got %q", c.FailedRetryDelay))
+		}
+	}
+	if c.EmptyScrapeRetryDelay != "" {
+		if d, err := time.ParseDuration(c.EmptyScrapeRetryDelay); err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("emptyScrapeRetryDelay must be a duration like \"30s\", got %q", c.EmptyScrapeRetryDelay))
+		}
+	}
+	if c.LogFormat != "" && c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("logFormat must be \"text\" or \"json\", got %q", c.LogFormat))
+	}
+	if c.DownloadQuality != "" && c.DownloadQuality != "original" {
+		if !downloadQualityRe.MatchString(c.DownloadQuality) {
+			errs = append(errs, fmt.Errorf("downloadQuality must be \"original\" or a size like \"w2048\", \"h1080\" or \"s4096\", got %q", c.DownloadQuality))
+		} else if c.ReplaceOnHigherRes {
+			errs = append(errs, errors.New("replaceOnHigherRes can't be combined with a reduced downloadQuality, every asset would be replaced on each sync"))
+		}
+	}
+	if c.ProxyURL != "" {
+		u, err := url.Parse(c.ProxyURL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" && u.Scheme != "socks5h") {
+			errs = append(errs, errors.New("proxyURL must be an http://, https:// or socks5:// URL with a host"))
+		}
+	}
+	for i, ac := range c.GooglePhotos {
+		if !strings.Contains(ac.URL, "photos.app.goo.gl") && !strings.Contains(ac.URL, "photos.google.com") {
+			errs = append(errs, fmt.Errorf("googlePhotos[%d].url %q is not a Google Photos share link (photos.app.goo.gl or photos.google.com)", i, ac.URL))
+		}
+		if _, _, err := ac.DateRange(); err != nil {
+			errs = append(errs, fmt.Errorf("googlePhotos[%d]: %w", i, err))
+		}
+		if ac.ShareRole != "" && ac.ShareRole != "editor" && ac.ShareRole != "viewer" {
+			errs = append(errs, fmt.Errorf("googlePhotos[%d].shareRole %q must be \"editor\" or \"viewer\"", i, ac.ShareRole))
+		}
+	}
+	if c.InvalidSyncInterval != "warn" {
+		errs = append(errs, c.CheckSyncIntervals()...)
+	}
+	return errors.Join(errs...)
+}
+
+// ImageSuffix returns the URL suffix requesting images at DownloadQuality, "=d" for originals
+func (c *Config) ImageSuffix() string {
+	if c.DownloadQuality == "" || c.DownloadQuality == "original" {
+		return "=d"
+	}
+	return "=" + strings.TrimPrefix(c.DownloadQuality, "=")
+}
+
+// DateRange returns the album's StartDate/EndDate as a half-open range
+// [start, end). Zero times mean no bound. A date-only EndDate includes that whole day.
+func (ac GooglePhotosConfig) DateRange() (time.Time, time.Time, error) {
+	var start, end time.Time
+	if ac.StartDate != "" {
+		t, _, err := parseDate(ac.StartDate)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid startDate %q: use YYYY-MM-DD or RFC3339", ac.StartDate)
+		}
+		start = t
+	}
+	if ac.EndDate != "" {
+		t, dateOnly, err := parseDate(ac.EndDate)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid endDate %q: use YYYY-MM-DD or RFC3339", ac.EndDate)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		} else {
+			t = t.Add(time.Nanosecond) // RFC3339 end is inclusive too
+		}
+		end = t
+	}
+	return start, end, nil
+}
+
+// parseDate accepts RFC3339 or YYYY-MM-DD (local midnight)
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, false, err
+}
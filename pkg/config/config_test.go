@@ -0,0 +1,114 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig is the smallest config that passes Validate
+func validConfig() Config {
+	return Config{
+		ApiURL:       "http://immich:2283/api",
+		ApiKey:       "secret",
+		GooglePhotos: []GooglePhotosConfig{{URL: "https://photos.app.goo.gl/abc123"}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr string // Empty when valid
+		offline bool   // Also invalid for ValidateOffline
+	}{
+		{name: "minimal", change: func(c *Config) {}},
+		{name: "full album url", change: func(c *Config) { c.GooglePhotos[0].URL = "https://photos.google.com/share/AF1Qip?key=x" }},
+		{name: "reduced quality", change: func(c *Config) { c.DownloadQuality = "w2048" }},
+		{name: "socks proxy", change: func(c *Config) { c.ProxyURL = "socks5://127.0.0.1:1080" }},
+		{name: "date range", change: func(c *Config) {
+			c.GooglePhotos[0].StartDate, c.GooglePhotos[0].EndDate = "2024-01-01", "2024-06-30T12:00:00Z"
+		}},
+		{name: "request rate ceiling", change: func(c *Config) { c.GoogleRequestsPerSecond = 2.5 }},
+		{name: "request rate ceiling disabled", change: func(c *Config) { c.GoogleRequestsPerSecond = -1 }},
+		{name: "retries disabled", change: func(c *Config) { c.FailedRetryDelay = "0" }},
+		{name: "invalid interval tolerated", change: func(c *Config) {
+			c.GooglePhotos[0].SyncInterval, c.InvalidSyncInterval = "daily", "warn"
+		}},
+
+		{name: "missing apiURL", change: func(c *Config) { c.ApiURL = "" }, wantErr: "apiURL"},
+		{name: "apiURL without scheme", change: func(c *Config) { c.ApiURL = "immich:2283/api" }, wantErr: "apiURL"},
+		{name: "missing apiKey", change: func(c *Config) { c.ApiKey = "" }, wantErr: "apiKey"},
+		{name: "negative workers", change: func(c *Config) { c.Workers = -1 }, wantErr: "workers", offline: true},
+		{name: "negative albumWorkers", change: func(c *Config) { c.AlbumWorkers = -2 }, wantErr: "albumWorkers", offline: true},
+		{name: "negative googleRequestsPerSecond", change: func(c *Config) { c.GoogleRequestsPerSecond = -0.5 }, wantErr: "googleRequestsPerSecond", offline: true},
+		{name: "bad failedRetryDelay", change: func(c *Config) { c.FailedRetryDelay = "soon" }, wantErr: "failedRetryDelay", offline: true},
+		{name: "negative failedRetryDelay", change: func(c *Config) { c.FailedRetryDelay = "-5s" }, wantErr: "failedRetryDelay", offline: true},
+		{name: "bad emptyScrapeRetryDelay", change: func(c *Config) { c.EmptyScrapeRetryDelay = "-30s" }, wantErr: "emptyScrapeRetryDelay", offline: true},
+		{name: "unknown logFormat", change: func(c *Config) { c.LogFormat = "xml" }, wantErr: "logFormat", offline: true},
+		{name: "unknown quality", change: func(c *Config) { c.DownloadQuality = "huge" }, wantErr: "downloadQuality", offline: true},
+		{name: "reduced quality with replace", change: func(c *Config) {
+			c.DownloadQuality, c.ReplaceOnHigherRes = "w2048", true
+		}, wantErr: "replaceOnHigherRes", offline: true},
+		{name: "ftp proxy", change: func(c *Config) { c.ProxyURL = "ftp://proxy:21" }, wantErr: "proxyURL", offline: true},
+		{name: "not a google url", change: func(c *Config) { c.GooglePhotos[0].URL = "https://example.com/album" }, wantErr: "googlePhotos[0].url", offline: true},
+		{name: "bad startDate", change: func(c *Config) { c.GooglePhotos[0].StartDate = "2024-13-01" }, wantErr: "startDate", offline: true},
+		{name: "bad shareRole", change: func(c *Config) { c.GooglePhotos[0].ShareRole = "owner" }, wantErr: "shareRole", offline: true},
+		{name: "bad syncInterval", change: func(c *Config) { c.GooglePhotos[0].SyncInterval = "daily" }, wantErr: "syncInterval", offline: true},
+		{name: "negative syncInterval", change: func(c *Config) { c.GooglePhotos[0].SyncInterval = "-1h" }, wantErr: "must be positive", offline: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.change(&c)
+
+			err := c.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want valid", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+
+			err = c.ValidateOffline()
+			if tt.offline && err == nil {
+				t.Errorf("ValidateOffline() accepted the config, want an error mentioning %q", tt.wantErr)
+			}
+			if !tt.offline && err != nil {
+				t.Errorf("ValidateOffline() = %v, want valid", err)
+			}
+		})
+	}
+}
+
+func TestImageSuffix(t *testing.T) {
+	tests := []struct {
+		quality string
+		want    string
+	}{
+		{"", "=d"},
+		{"original", "=d"},
+		{"w2048", "=w2048"},
+		{"h1080", "=h1080"},
+		{"s4096", "=s4096"},
+		{"=w2048", "=w2048"},
+	}
+	for _, tt := range tests {
+		c := Config{DownloadQuality: tt.quality}
+		if got := c.ImageSuffix(); got != tt.want {
+			t.Errorf("ImageSuffix() for downloadQuality %q = %q, want %q", tt.quality, got, tt.want)
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := Config{Workers: -1, LogFormat: "xml"}
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate() accepted an empty config")
+	}
+	for _, want := range []string{"apiURL", "apiKey", "workers", "logFormat"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, missing the %s problem", err, want)
+		}
+	}
+}
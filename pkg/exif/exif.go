@@ -0,0 +1,256 @@
+// Package exif adds capture dates to JPEG files that don't carry one.
+// It only understands the few TIFF structures it needs and never moves existing
+// data: changed IFDs are copied to the end of the EXIF block and re-pointed.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNotJPEG   = errors.New("not a JPEG file")
+	ErrMalformed = errors.New("malformed JPEG/EXIF data")
+)
+
+const (
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagCreateDate         = 0x9004 // DateTimeDigitized
+	tagOffsetTimeOriginal = 0x9011
+
+	typeASCII = 2
+	typeLong  = 4
+
+	exifHeader = "Exif\x00\x00"
+	maxSegment = 0xFFFF - 2 // APP1 payload limit (length field counts itself)
+)
+
+// byteOrder is the TIFF block's endianness ("II" little, "MM" big)
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// entry is a raw 12-byte IFD entry: tag, type, count, value or offset
+type entry [12]byte
+
+func (e entry) tag(bo byteOrder) uint16 { return bo.Uint16(e[0:2]) }
+
+// SetDateTaken writes t as DateTimeOriginal, CreateDate and OffsetTimeOriginal
+// into a JPEG that has no DateTimeOriginal yet. Files that already have one are
+// returned unchanged with false.
+func SetDateTaken(jpeg []byte, t time.Time) ([]byte, bool, error) {
+	if len(jpeg) < 4 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return jpeg, false, ErrNotJPEG
+	}
+
+	insertAt := 2 // New APP1 goes right after SOI, or after a leading JFIF APP0
+	pos := 2
+	for pos+4 <= len(jpeg) {
+		if jpeg[pos] != 0xFF {
+			return jpeg, false, ErrMalformed
+		}
+		marker := jpeg[pos+1]
+		if marker == 0xFF { // Fill byte
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // Start of scan / end of image: no more metadata
+			break
+		}
+		segLen := int(binary.BigEndian.Uint16(jpeg[pos+2:]))
+		end := pos + 2 + segLen
+		if segLen < 2 || end > len(jpeg) {
+			return jpeg, false, ErrMalformed
+		}
+		body := jpeg[pos+4 : end]
+
+		if marker == 0xE1 && bytes.HasPrefix(body, []byte(exifHeader)) {
+			tiff, changed, err := addDates(body[len(exifHeader):], t)
+			if err != nil || !changed {
+				return jpeg, false, err
+			}
+			return replaceSegment(jpeg, pos, end, tiff)
+		}
+		if marker == 0xE0 && pos == 2 {
+			insertAt = end
+		}
+		pos = end
+	}
+
+	tiff, err := newTIFF(t)
+	if err != nil {
+		return jpeg, false, err
+	}
+	return replaceSegment(jpeg, insertAt, insertAt, tiff)
+}
+
+// replaceSegment swaps jpeg[start:end] for an EXIF APP1 segment holding tiff
+func replaceSegment(jpeg []byte, start, end int, tiff []byte) ([]byte, bool, error) {
+	payload := len(exifHeader) + len(tiff)
+	if payload > maxSegment {
+		return jpeg, false, fmt.Errorf("%w: EXIF block would exceed %d bytes", ErrMalformed, maxSegment)
+	}
+	out := make([]byte, 0, len(jpeg)-(end-start)+payload+4)
+	out = append(out, jpeg[:start]...)
+	out = append(out, 0xFF, 0xE1, byte((payload+2)>>8), byte(payload+2))
+	out = append(out, exifHeader...)
+	out = append(out, tiff...)
+	out = append(out, jpeg[end:]...)
+	return out, true, nil
+}
+
+// newTIFF builds a little-endian TIFF block with only the date tags
+func newTIFF(t time.Time) ([]byte, error) {
+	tiff := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	return addExifIFD(tiff, binary.LittleEndian, nil, 0, t)
+}
+
+// addDates adds the date tags to an existing TIFF block. Returns false when it
+// already has a DateTimeOriginal.
+func addDates(tiff []byte, t time.Time) ([]byte, bool, error) {
+	if len(tiff) < 8 {
+		return nil, false, ErrMalformed
+	}
+	var bo byteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return nil, false, ErrMalformed
+	}
+	if bo.Uint16(tiff[2:]) != 42 {
+		return nil, false, ErrMalformed
+	}
+
+	ifd0 := bo.Uint32(tiff[4:])
+	entries0, next0, err := readIFD(tiff, bo, ifd0)
+	if err != nil {
+		return nil, false, err
+	}
+	for i, e := range entries0 {
+		if e.tag(bo) != tagExifIFD {
+			continue
+		}
+		exifOff := bo.Uint32(e[8:])
+		entries, next, err := readIFD(tiff, bo, exifOff)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, e := range entries {
+			if e.tag(bo) == tagDateTimeOriginal {
+				return tiff, false, nil
+			}
+		}
+		// Copy the Exif IFD with the dates added and re-point IFD0's entry at the copy
+		out := append([]byte(nil), tiff...)
+		out, entries = appendDateEntries(out, bo, entries, t)
+		out, newOff, err := appendIFD(out, bo, entries, next)
+		if err != nil {
+			return nil, false, err
+		}
+		bo.PutUint32(out[int(ifd0)+2+12*i+8:], newOff)
+		return out, true, nil
+	}
+
+	out, err := addExifIFD(append([]byte(nil), tiff...), bo, entries0, next0, t)
+	return out, err == nil, err
+}
+
+// addExifIFD appends a new Exif IFD with the dates plus a copy of IFD0 (entries0)
+// pointing at it, and makes the copy the TIFF's first IFD
+func addExifIFD(tiff []byte, bo byteOrder, entries0 []entry, next0 uint32, t time.Time) ([]byte, error) {
+	tiff, dates := appendDateEntries(tiff, bo, nil, t)
+	tiff, exifOff, err := appendIFD(tiff, bo, dates, 0)
+	if err != nil {
+		return nil, err
+	}
+	var ptr entry
+	bo.PutUint16(ptr[0:], tagExifIFD)
+	bo.PutUint16(ptr[2:], typeLong)
+	bo.PutUint32(ptr[4:], 1)
+	bo.PutUint32(ptr[8:], exifOff)
+	tiff, ifd0, err := appendIFD(tiff, bo, append(append([]entry(nil), entries0...), ptr), next0)
+	if err != nil {
+		return nil, err
+	}
+	bo.PutUint32(tiff[4:], ifd0)
+	return tiff, nil
+}
+
+// appendDateEntries appends the date strings to tiff and returns entries
+// extended with the tags referencing them
+func appendDateEntries(tiff []byte, bo byteOrder, entries []entry, t time.Time) ([]byte, []entry) {
+	date := t.Format("2006:01:02 15:04:05")
+	tiff, e := asciiEntry(tiff, bo, tagDateTimeOriginal, date)
+	entries = append(entries, e)
+	tiff, e = asciiEntry(tiff, bo, tagCreateDate, date)
+	entries = append(entries, e)
+	tiff, e = asciiEntry(tiff, bo, tagOffsetTimeOriginal, t.Format("-07:00"))
+	entries = append(entries, e)
+	return tiff, entries
+}
+
+// asciiEntry stores s (NUL-terminated) at the end of tiff and returns an entry for it
+func asciiEntry(tiff []byte, bo byteOrder, tag uint16, s string) ([]byte, entry) {
+	var e entry
+	value := append([]byte(s), 0)
+	bo.PutUint16(e[0:], tag)
+	bo.PutUint16(e[2:], typeASCII)
+	bo.PutUint32(e[4:], uint32(len(value)))
+	if len(value) <= 4 {
+		copy(e[8:], value)
+		return tiff, e
+	}
+	tiff = pad(tiff)
+	bo.PutUint32(e[8:], uint32(len(tiff)))
+	return append(tiff, value...), e
+}
+
+// appendIFD writes an IFD (entries sorted by tag, as TIFF requires) at the end of tiff
+func appendIFD(tiff []byte, bo byteOrder, entries []entry, next uint32) ([]byte, uint32, error) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].tag(bo) < entries[j].tag(bo) })
+	tiff = pad(tiff)
+	off := uint32(len(tiff))
+	tiff = bo.AppendUint16(tiff, uint16(len(entries)))
+	for _, e := range entries {
+		tiff = append(tiff, e[:]...)
+	}
+	tiff = bo.AppendUint32(tiff, next)
+	if len(exifHeader)+len(tiff) > maxSegment {
+		return nil, 0, fmt.Errorf("%w: EXIF block would exceed %d bytes", ErrMalformed, maxSegment)
+	}
+	return tiff, off, nil
+}
+
+// readIFD returns the entries and next-IFD offset of the IFD at off
+func readIFD(tiff []byte, bo byteOrder, off uint32) ([]entry, uint32, error) {
+	if int(off)+2 > len(tiff) {
+		return nil, 0, ErrMalformed
+	}
+	n := int(bo.Uint16(tiff[off:]))
+	start := int(off) + 2
+	if start+12*n+4 > len(tiff) {
+		return nil, 0, ErrMalformed
+	}
+	entries := make([]entry, n)
+	for i := range entries {
+		copy(entries[i][:], tiff[start+12*i:])
+	}
+	return entries, bo.Uint32(tiff[start+12*n:]), nil
+}
+
+// pad aligns the next write to an even offset, IFDs and values start on word boundaries
+func pad(tiff []byte) []byte {
+	if len(tiff)%2 == 1 {
+		return append(tiff, 0)
+	}
+	return tiff
+}
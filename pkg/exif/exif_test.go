@@ -0,0 +1,193 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	tagOrientation  = 0x0112
+	tagExposureTime = 0x829A
+)
+
+var (
+	jfifSegment = []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0}
+	imageData   = []byte{0xFF, 0xDA, 0, 2, 0x12, 0x34, 0x56, 0xFF, 0xD9} // Start of scan to end of image
+)
+
+// jpegWith builds a JPEG from SOI, the given segments and a stub image
+func jpegWith(segments ...[]byte) []byte {
+	out := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, imageData...)
+}
+
+// app1 wraps a TIFF block in an EXIF APP1 segment
+func app1(tiff []byte) []byte {
+	n := len(exifHeader) + len(tiff) + 2
+	return append(append([]byte{0xFF, 0xE1, byte(n >> 8), byte(n)}, exifHeader...), tiff...)
+}
+
+// shortEntry is an IFD entry with a SHORT value stored inline
+func shortEntry(bo byteOrder, tag, value uint16) entry {
+	var e entry
+	bo.PutUint16(e[0:], tag)
+	bo.PutUint16(e[2:], 3)
+	bo.PutUint32(e[4:], 1)
+	bo.PutUint16(e[8:], value)
+	return e
+}
+
+// tiffWith builds a TIFF block with IFD0 holding ifd0 and, when exifEntries
+// isn't nil, an Exif IFD holding those
+func tiffWith(t *testing.T, bo byteOrder, ifd0 []entry, exifEntries []entry) []byte {
+	t.Helper()
+	tiff := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	if bo == binary.BigEndian {
+		tiff = []byte{'M', 'M', 0, 42, 0, 0, 0, 0}
+	}
+	var err error
+	if exifEntries != nil {
+		var off uint32
+		if tiff, off, err = appendIFD(tiff, bo, exifEntries, 0); err != nil {
+			t.Fatal(err)
+		}
+		var ptr entry
+		bo.PutUint16(ptr[0:], tagExifIFD)
+		bo.PutUint16(ptr[2:], typeLong)
+		bo.PutUint32(ptr[4:], 1)
+		bo.PutUint32(ptr[8:], off)
+		ifd0 = append(ifd0, ptr)
+	}
+	tiff, off, err := appendIFD(tiff, bo, ifd0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bo.PutUint32(tiff[4:], off)
+	return tiff
+}
+
+// readTags parses a JPEG's EXIF block the way a reader would and returns the
+// IFD0 and Exif IFD entries by tag: ASCII values as strings, SHORTs as uint16
+func readTags(t *testing.T, jpeg []byte) map[uint16]interface{} {
+	t.Helper()
+	pos := 2
+	for pos+4 <= len(jpeg) && jpeg[pos+1] != 0xDA {
+		end := pos + 2 + int(binary.BigEndian.Uint16(jpeg[pos+2:]))
+		body := jpeg[pos+4 : end]
+		if jpeg[pos+1] != 0xE1 || !bytes.HasPrefix(body, []byte(exifHeader)) {
+			pos = end
+			continue
+		}
+		tiff := body[len(exifHeader):]
+		var bo byteOrder = binary.LittleEndian
+		if string(tiff[:2]) == "MM" {
+			bo = binary.BigEndian
+		}
+		tags := make(map[uint16]interface{})
+		var read func(off uint32)
+		read = func(off uint32) {
+			entries, _, err := readIFD(tiff, bo, off)
+			if err != nil {
+				t.Fatalf("reading IFD at %d: %v", off, err)
+			}
+			for _, e := range entries {
+				switch bo.Uint16(e[2:]) {
+				case typeASCII:
+					n := bo.Uint32(e[4:])
+					value := e[8 : 8+min(n, 4)]
+					if n > 4 {
+						value = tiff[bo.Uint32(e[8:]) : bo.Uint32(e[8:])+n]
+					}
+					tags[e.tag(bo)] = string(bytes.TrimRight(value, "\x00"))
+				case 3:
+					tags[e.tag(bo)] = bo.Uint16(e[8:])
+				}
+				if e.tag(bo) == tagExifIFD {
+					read(bo.Uint32(e[8:]))
+				}
+			}
+		}
+		read(bo.Uint32(tiff[4:]))
+		return tags
+	}
+	t.Fatal("no EXIF block")
+	return nil
+}
+
+func TestSetDateTakenRoundTrip(t *testing.T) {
+	taken := time.Date(2024, 1, 1, 8, 30, 15, 0, time.FixedZone("", 9*3600))
+	le, be := binary.LittleEndian, binary.BigEndian
+	tests := []struct {
+		name string
+		jpeg func(t *testing.T) []byte
+		keep map[uint16]interface{} // Existing tags that must survive
+	}{
+		{"bare", func(t *testing.T) []byte { return jpegWith() }, nil},
+		{"JFIF only", func(t *testing.T) []byte { return jpegWith(jfifSegment) }, nil},
+		{"IFD0 without Exif IFD, big endian", func(t *testing.T) []byte {
+			return jpegWith(app1(tiffWith(t, be, []entry{shortEntry(be, tagOrientation, 6)}, nil)))
+		}, map[uint16]interface{}{tagOrientation: uint16(6)}},
+		{"Exif IFD without dates", func(t *testing.T) []byte {
+			return jpegWith(jfifSegment, app1(tiffWith(t, le, []entry{shortEntry(le, tagOrientation, 3)}, []entry{shortEntry(le, tagExposureTime, 7)})))
+		}, map[uint16]interface{}{tagOrientation: uint16(3), tagExposureTime: uint16(7)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.jpeg(t)
+			out, changed, err := SetDateTaken(in, taken)
+			if err != nil || !changed {
+				t.Fatalf("SetDateTaken = %v, %v, want changed", changed, err)
+			}
+			if !bytes.HasSuffix(out, imageData) || !bytes.HasPrefix(out, []byte{0xFF, 0xD8}) {
+				t.Error("image data not kept intact")
+			}
+
+			tags := readTags(t, out)
+			want := map[uint16]interface{}{
+				tagDateTimeOriginal:   "2024:01:01 08:30:15",
+				tagCreateDate:         "2024:01:01 08:30:15",
+				tagOffsetTimeOriginal: "+09:00",
+			}
+			for tag, v := range tt.keep {
+				want[tag] = v
+			}
+			for tag, v := range want {
+				if tags[tag] != v {
+					t.Errorf("tag %#04x = %v, want %v", tag, tags[tag], v)
+				}
+			}
+
+			// A second pass finds the date and leaves the file alone
+			again, changed, err := SetDateTaken(out, taken.Add(time.Hour))
+			if err != nil || changed || !bytes.Equal(again, out) {
+				t.Errorf("second SetDateTaken = %v, %v, want the file unchanged", changed, err)
+			}
+		})
+	}
+}
+
+func TestSetDateTakenErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n"), ErrNotJPEG},
+		{"empty", nil, ErrNotJPEG},
+		{"segment past the end", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E'}, ErrMalformed},
+		{"garbage between segments", []byte{0xFF, 0xD8, 0x00, 0x00, 0x00, 0x00}, ErrMalformed},
+		{"broken TIFF", jpegWith(app1([]byte("XX\x00\x2a\x08\x00\x00\x00"))), ErrMalformed},
+	}
+	for _, tt := range tests {
+		out, changed, err := SetDateTaken(tt.data, time.Now())
+		if !errors.Is(err, tt.want) || changed || !bytes.Equal(out, tt.data) {
+			t.Errorf("%s: SetDateTaken = %v, %v, want %v with the input returned", tt.name, changed, err, tt.want)
+		}
+	}
+}
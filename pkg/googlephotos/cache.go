@@ -0,0 +1,104 @@
+package googlephotos
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cachePath returns the cache file for an album URL
+func (c *Client) cachePath(albumURL string) string {
+	sum := sha256.Sum256([]byte(albumURL))
+	return filepath.Join(c.opts.CacheDir, hex.EncodeToString(sum[:8])+".html")
+}
+
+// fetchAlbumPage returns the album page HTML and the URL it was served from after
+// redirects. With a scrape cache configured, a page fetched less than CacheTTL ago
+// is read from disk instead of Google. Cache files hold the final URL on the first
+// line followed by the page.
+func (c *Client) fetchAlbumPage(albumURL string, useCache bool) ([]byte, string, error) {
+	useCache = useCache && c.opts.CacheDir != "" && c.opts.CacheTTL > 0
+	if useCache {
+		if page, finalURL, age, ok := c.readCache(albumURL); ok {
+			c.logger.Info("Using cached album page", "url", albumURL, "age", age.Round(time.Second), "ttl", c.opts.CacheTTL)
+			return page, finalURL, nil
+		}
+	}
+
+	resp, err := c.Get(albumURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("failed to fetch album: %d", resp.StatusCode)
+	}
+
+	// Capture final URL after redirects (short URLs like photos.app.goo.gl redirect to photos.google.com)
+	finalURL := albumURL
+	if resp.Request != nil && resp.Request.URL != nil {
+		finalURL = resp.Request.URL.String()
+	}
+
+	if resp.Request != nil && resp.Request.URL != nil && isSignInURL(resp.Request.URL) {
+		if c.opts.Cookies != "" || c.opts.CookieFile != "" {
+			return nil, "", fmt.Errorf("%w: Google rejected the configured cookies", ErrSignInRequired)
+		}
+		return nil, "", fmt.Errorf("%w: Google redirected to its sign-in page (configure cookies for private albums)", ErrSignInRequired)
+	}
+
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if useCache {
+		if err := c.writeCache(albumURL, finalURL, page); err != nil {
+			c.logger.Warn("Could not write scrape cache", "error", err)
+		}
+	}
+	return page, finalURL, nil
+}
+
+func (c *Client) readCache(albumURL string) ([]byte, string, time.Duration, bool) {
+	path := c.cachePath(albumURL)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", 0, false
+	}
+	age := time.Since(info.ModTime())
+	if age > c.opts.CacheTTL {
+		return nil, "", 0, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", 0, false
+	}
+	nl := bytes.IndexByte(data, '\n')
+	if nl == -1 {
+		return nil, "", 0, false
+	}
+	return data[nl+1:], string(data[:nl]), age, true
+}
+
+func (c *Client) writeCache(albumURL, finalURL string, page []byte) error {
+	if err := os.MkdirAll(c.opts.CacheDir, 0o755); err != nil {
+		return err
+	}
+	path := c.cachePath(albumURL)
+	tmp := path + ".tmp"
+	data := make([]byte, 0, len(finalURL)+1+len(page))
+	data = append(data, finalURL...)
+	data = append(data, '\n')
+	data = append(data, page...)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -1,40 +1,144 @@
 package googlephotos
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"math/rand"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
 	"strings"
 	"time"
 )
 
 const userAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
 
+const defaultMaxRedirects = 10
+
+// ErrSignInRequired is returned when Google redirects to its sign-in page, i.e. the
+// album isn't publicly shared (anymore) or the configured cookies were not accepted
+var ErrSignInRequired = errors.New("album requires authentication")
+
+// Defaults for the Options that tune rate-limit handling
 const (
-	maxRetries  = 5
-	baseBackoff = 5 * time.Second
-	minJitter   = 100
-	jitterRange = 250
+	defaultMaxRetries  = 5
+	defaultBaseBackoff = 5 * time.Second
+	defaultMinDelay    = 100 * time.Millisecond
+	defaultJitter      = 250 * time.Millisecond
+
+	defaultRequestsPerSecond = 4
+
+	defaultDownloadRetries = 2
+	defaultDownloadBackoff = 2 * time.Second
+	defaultDownloadResumes = 3
 )
 
+// Options tunes the Google Photos client. The zero value keeps the defaults.
+type Options struct {
+	DownloadAccept      string            // Accept header sent on image downloads, e.g. "image/jpeg" (Google may ignore it)
+	MaxVideoBytes       int64             // Videos larger than this are not downloaded, 0 means no limit
+	MaxRedirects        int               // Redirects followed per request, 0 means the default of 10
+	StopOnSignIn        bool              // Fail with ErrSignInRequired instead of following redirects to the sign-in page
+	MaxConcurrentProbes int               // HEAD probes allowed in flight at once across workers, 0 means no limit
+	CacheDir            string            // Directory for cached album pages, empty disables the scrape cache
+	CacheTTL            time.Duration     // How long a cached album page is used instead of fetching it again
+	Transport           http.RoundTripper // HTTP transport, nil uses http.DefaultTransport
+	VerifyMediaType     bool              // Sniff downloaded bytes and re-request once when image/video doesn't match
+	Cookies             string            // Cookie header value for albums shared to specific accounts ("SID=...; HSID=...")
+	CookieFile          string            // Netscape cookies.txt file, alternative to Cookies
+	DownloadRetries     int               // Extra attempts for media downloads failing on network errors, 0 means 2, negative disables
+	DownloadBackoff     time.Duration     // Wait before the first download retry, doubled per attempt, 0 means 2s
+	SpoolThreshold      int64             // Bodies larger than this are downloaded to a temp file instead of memory, 0 keeps everything in memory
+	SpoolDir            string            // Directory for spooled downloads, empty uses the system temp directory
+	MaxRetries          int               // Attempts per request on 429/5xx answers, 0 means 5
+	BaseBackoff         time.Duration     // Wait after the first 429/5xx, growing linearly per attempt, 0 means 5s; Retry-After wins
+	MinDelay            time.Duration     // Minimum pause before every request without a RequestsPerSecond ceiling, 0 means 100ms, negative disables
+	Jitter              time.Duration     // Random extra pause on top of MinDelay, 0 means 250ms, negative disables
+	RequestsPerSecond   float64           // Ceiling for all requests of the client together, replaces MinDelay/Jitter; 0 means 4, negative disables
+	DownloadResumes     int               // Range requests continuing a spooled download cut off midway, 0 means 3, negative disables
+	ImageSuffix         string            // URL suffix for image downloads, e.g. "=w2048"; empty means "=d" (original)
+}
+
 type Client struct {
-	client *http.Client
-	logger *slog.Logger
+	client   *http.Client
+	logger   *slog.Logger
+	opts     Options
+	probeSem chan struct{} // Bounds concurrent HEAD probes, nil when unlimited
+	limiter  *rateLimiter  // Shared request rate ceiling, nil when RequestsPerSecond is disabled
 }
 
-func NewClient(logger *slog.Logger) *Client {
+// ProxyTransport returns a transport sending requests through proxy (http, https
+// or socks5 scheme). Pass it as Options.Transport, optionally wrapped.
+func ProxyTransport(proxy *url.URL) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(proxy)
+	return t
+}
+
+func NewClient(logger *slog.Logger, opts Options) *Client {
 	jar, _ := cookiejar.New(nil)
-	return &Client{
-		client: &http.Client{
-			Jar: jar,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return nil
-			},
-			Timeout: 120 * time.Second,
-		},
+	c := &Client{
 		logger: logger,
+		opts:   opts,
+	}
+	c.client = &http.Client{
+		Transport:     opts.Transport,
+		Jar:           jar,
+		CheckRedirect: c.checkRedirect,
+		Timeout:       120 * time.Second,
 	}
+	if opts.Cookies != "" || opts.CookieFile != "" {
+		n, err := loadCookies(jar, opts.Cookies, opts.CookieFile)
+		if err != nil {
+			logger.Warn("Could not load Google cookies", "error", err)
+		}
+		logger.Debug("Loaded Google cookies", "count", n)
+	}
+	if opts.MaxConcurrentProbes > 0 {
+		c.probeSem = make(chan struct{}, opts.MaxConcurrentProbes)
+	}
+	if c.opts.RequestsPerSecond == 0 {
+		c.opts.RequestsPerSecond = defaultRequestsPerSecond
+	}
+	if c.opts.RequestsPerSecond > 0 {
+		c.limiter = newRateLimiter(c.opts.RequestsPerSecond)
+	}
+	if c.opts.MaxRetries <= 0 {
+		c.opts.MaxRetries = defaultMaxRetries
+	}
+	if c.opts.BaseBackoff <= 0 {
+		c.opts.BaseBackoff = defaultBaseBackoff
+	}
+	if c.opts.MinDelay == 0 {
+		c.opts.MinDelay = defaultMinDelay
+	}
+	if c.opts.Jitter == 0 {
+		c.opts.Jitter = defaultJitter
+	}
+	return c
+}
+
+// checkRedirect bounds redirect chains and optionally stops at Google's sign-in page
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	limit := c.opts.MaxRedirects
+	if limit <= 0 {
+		limit = defaultMaxRedirects
+	}
+	if len(via) >= limit {
+		return fmt.Errorf("stopped after %d redirects (last: %s)", len(via), req.URL.Host)
+	}
+	if c.opts.StopOnSignIn && isSignInURL(req.URL) {
+		return fmt.Errorf("%w: redirected to %s", ErrSignInRequired, req.URL.Host)
+	}
+	return nil
+}
+
+// isSignInURL reports whether u points at Google's account sign-in flow
+func isSignInURL(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == "accounts.google.com" || strings.Contains(u.Path, "ServiceLogin")
 }
 
 func (c *Client) Get(targetURL string) (*http.Response, error) {
@@ -48,14 +152,84 @@ func (c *Client) Get(targetURL string) (*http.Response, error) {
 	})
 }
 
-// Head performs a lightweight HEAD request without jitter (used for content-type probing)
+// getMedia is Get sending accept as the Accept header, none when empty
+func (c *Client) getMedia(targetURL, accept string) (*http.Response, error) {
+	return c.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", targetURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, nil
+	})
+}
+
+// getMediaRange is getMedia asking for the body from offset on
+func (c *Client) getMediaRange(targetURL, accept string, offset int64) (*http.Response, error) {
+	return c.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", targetURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		return req, nil
+	})
+}
+
+// resumer returns a resumeFunc continuing a download of mediaURL with a Range
+// request, sending the same Accept header as the first request. limit caps the
+// whole body like MaxVideoBytes, 0 means no cap.
+func (c *Client) resumer(mediaURL, accept string, limit int64) resumeFunc {
+	return func(offset int64) (io.ReadCloser, bool, error) {
+		resp, err := c.getMediaRange(mediaURL, accept, offset)
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			resp.Body.Close()
+			return nil, false, nil
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return nil, false, fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		if cr := resp.Header.Get("Content-Range"); !strings.HasPrefix(cr, fmt.Sprintf("bytes %d-", offset)) {
+			resp.Body.Close()
+			return nil, false, fmt.Errorf("unexpected Content-Range %q", cr)
+		}
+		if limit <= 0 {
+			return resp.Body, true, nil
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, limit+1-offset), resp.Body}, true, nil
+	}
+}
+
+// Head performs a HEAD request (used for content-type/size probing). Probes go
+// through the same jitter and 429 backoff as other requests so they count toward
+// the Google request budget, and at most MaxConcurrentProbes run at once.
 func (c *Client) Head(targetURL string) (*http.Response, error) {
-	req, err := http.NewRequest("HEAD", targetURL, nil)
-	if err != nil {
-		return nil, err
+	if c.probeSem != nil {
+		c.probeSem <- struct{}{}
+		defer func() { <-c.probeSem }()
 	}
-	req.Header.Set("User-Agent", userAgent)
-	return c.client.Do(req)
+	return c.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("HEAD", targetURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		return req, nil
+	})
 }
 
 // Post performs a POST request with retry logic and cookie/session support
@@ -71,31 +245,56 @@ func (c *Client) Post(targetURL string, contentType string, body string) (*http.
 	})
 }
 
-// doWithRetry executes a request with jitter, rate-limit retry and exponential backoff
+// pause returns the wait before a request: MinDelay plus a random share of Jitter,
+// either of which may be disabled (negative)
+func (c *Client) pause() time.Duration {
+	d := max(c.opts.MinDelay, 0)
+	if c.opts.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(c.opts.Jitter)))
+	}
+	return d
+}
+
+// doWithRetry executes a request with jitter, rate-limit retry and exponential backoff.
+// Unless RequestsPerSecond is disabled, every attempt waits for the shared rate limiter instead of the jitter.
 func (c *Client) doWithRetry(makeReq func() (*http.Request, error)) (*http.Response, error) {
-	jitter := time.Duration(minJitter+rand.Intn(jitterRange)) * time.Millisecond
-	time.Sleep(jitter)
+	if c.limiter == nil {
+		if pause := c.pause(); pause > 0 {
+			time.Sleep(pause)
+		}
+	}
 
 	var resp *http.Response
-	for i := 0; i < maxRetries; i++ {
+	for i := 0; i < c.opts.MaxRetries; i++ {
 		req, err := makeReq()
 		if err != nil {
 			return nil, err
 		}
 
+		if c.limiter != nil {
+			c.limiter.wait()
+		}
 		resp, err = c.client.Do(req)
 		if err != nil {
 			return nil, err
 		}
+		if c.limiter != nil {
+			if resp.StatusCode == 429 {
+				c.logger.Warn("Rate limited by Google, lowering request rate", "requests_per_second", c.limiter.slowDown())
+			} else {
+				c.limiter.speedUp()
+			}
+		}
 
-		// Success or client error (4xx except 429) — return immediately
-		if resp.StatusCode < 429 || (resp.StatusCode > 429 && resp.StatusCode < 500) {
+		// Success or client error (4xx except 429) — return immediately.
+		// The last attempt's answer is returned as-is for the caller to report.
+		if resp.StatusCode < 429 || (resp.StatusCode > 429 && resp.StatusCode < 500) || i == c.opts.MaxRetries-1 {
 			return resp, nil
 		}
 
 		// Retryable: 429 (rate limit) or 5xx (server error)
 		resp.Body.Close()
-		sleepTime := baseBackoff * time.Duration(i+1)
+		sleepTime := c.opts.BaseBackoff * time.Duration(i+1)
 		if resp.StatusCode == 429 {
 			if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
 				if seconds, parseErr := time.ParseDuration(retryAfter + "s"); parseErr == nil {
@@ -109,3 +308,41 @@ func (c *Client) doWithRetry(makeReq func() (*http.Request, error)) (*http.Respo
 
 	return resp, nil
 }
+
+// retryDownload runs one media download attempt at a time and retries it with
+// exponential backoff on network errors, including connections dropped mid-body.
+// Every attempt starts from scratch, partial bodies are discarded. HTTP statuses
+// aren't retried here: doWithRetry already covers 429 and 5xx, and 403/404 are final.
+func (c *Client) retryDownload(attempt func() error) error {
+	retries := c.opts.DownloadRetries
+	if retries == 0 {
+		retries = defaultDownloadRetries
+	}
+	backoff := c.opts.DownloadBackoff
+	if backoff <= 0 {
+		backoff = defaultDownloadBackoff
+	}
+
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil || i >= retries || !retryableDownloadError(err) {
+			return err
+		}
+		c.logger.Warn("Download failed, retrying", "error", err, "attempt", i+1, "sleep", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryableDownloadError reports whether a failed download is worth another attempt
+func retryableDownloadError(err error) bool {
+	var se *statusError
+	switch {
+	case errors.As(err, &se),
+		errors.Is(err, ErrRestricted),
+		errors.Is(err, ErrVideoTooLarge),
+		errors.Is(err, ErrSignInRequired):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,285 @@
+package googlephotos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeadConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int // Most probes in flight at once, 0 for more than any limit here
+	}{
+		{0, 0},
+		{1, 1},
+		{3, 3},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		inFlight, most := 0, 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			most = max(most, inFlight)
+			mu.Unlock()
+			time.Sleep(30 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}))
+
+		client := newTestClient(Options{MaxConcurrentProbes: tt.limit})
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Head(srv.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+		srv.Close()
+
+		if tt.want == 0 && most <= 3 {
+			t.Errorf("no limit: only %d probes in flight at once", most)
+		} else if tt.want != 0 && most != tt.want {
+			t.Errorf("limit %d: %d probes in flight at once, want %d", tt.limit, most, tt.want)
+		}
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		notFound bool // Failing GETs answer 404 instead of dropping the connection halfway
+		failures int  // Full GETs that fail before one succeeds
+		retries  int
+		wantErr  bool
+		wantGets int
+	}{
+		{"third attempt wins", false, 2, 2, false, 3},
+		{"out of retries", false, 3, 2, true, 3},
+		{"retries disabled", false, 1, -1, true, 1},
+		{"not found is final", true, 1, 2, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			gets := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/jpeg")
+				w.Header().Set("Content-Length", fmt.Sprint(len(testJPEG)))
+				if r.Method == http.MethodHead {
+					return
+				}
+				fail := false
+				if r.Header.Get("Range") == "" { // Range is ignored, so resuming fails and the download starts over
+					mu.Lock()
+					gets++
+					fail = gets <= tt.failures
+					mu.Unlock()
+				}
+				switch {
+				case !fail:
+					w.Write(testJPEG)
+				case tt.notFound:
+					w.WriteHeader(http.StatusNotFound)
+				default:
+					w.Write(testJPEG[:len(testJPEG)/2])
+					w.(http.Flusher).Flush()
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err != nil {
+						t.Errorf("hijacking connection: %v", err)
+						return
+					}
+					conn.Close()
+				}
+			}))
+			defer srv.Close()
+
+			client := newTestClient(Options{DownloadRetries: tt.retries, DownloadBackoff: time.Millisecond, SpoolThreshold: 16, SpoolDir: t.TempDir()})
+			r, size, _, _, err := DownloadMedia(client, srv.URL+"/img", false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error: %v", err, tt.wantErr)
+			}
+			mu.Lock()
+			if gets != tt.wantGets {
+				t.Errorf("%d GETs, want %d", gets, tt.wantGets)
+			}
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, testJPEG) || size != int64(len(testJPEG)) {
+				t.Errorf("downloaded %d bytes (size %d), want exactly the %d served", len(got), size, len(testJPEG))
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []int  // Answers in order, the last one repeats
+		retryAfter  string // Retry-After sent with 429s
+		maxRetries  int
+		backoff     time.Duration
+		wantStatus  int
+		wantCalls   int
+		minDuration time.Duration // Backoff slept in total, growing linearly
+		maxDuration time.Duration
+	}{
+		{"429 then 200", []int{429, 200}, "", 3, 10 * time.Millisecond, 200, 2, 10 * time.Millisecond, time.Second},
+		{"5xx until out of retries", []int{503}, "", 4, 10 * time.Millisecond, 503, 4, 60 * time.Millisecond, time.Second},
+		{"single attempt", []int{429}, "", 1, 10 * time.Millisecond, 429, 1, 0, time.Second},
+		{"client error is final", []int{404, 200}, "", 3, 10 * time.Millisecond, 404, 1, 0, time.Second},
+		{"Retry-After wins", []int{429, 429, 200}, "0", 3, time.Minute, 200, 3, 0, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := tt.statuses[min(calls, len(tt.statuses)-1)]
+				calls++
+				mu.Unlock()
+				if status == 429 && tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			client := newTestClient(Options{MaxRetries: tt.maxRetries, BaseBackoff: tt.backoff})
+			start := time.Now()
+			resp, err := client.Get(srv.URL)
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if resp.StatusCode != tt.wantStatus || calls != tt.wantCalls {
+				t.Errorf("got %d after %d requests, want %d after %d", resp.StatusCode, calls, tt.wantStatus, tt.wantCalls)
+			}
+			if elapsed < tt.minDuration || elapsed > tt.maxDuration {
+				t.Errorf("took %v, want between %v and %v", elapsed, tt.minDuration, tt.maxDuration)
+			}
+		})
+	}
+}
+
+func TestProxyTransport(t *testing.T) {
+	// The proxy stub answers for any host, as a forward proxy sees absolute request URLs
+	var mu sync.Mutex
+	var seen []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Method+" "+r.URL.String()+" "+r.Header.Get("Proxy-Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", fmt.Sprint(len(testJPEG)))
+		if r.Method != http.MethodHead {
+			w.Write(testJPEG)
+		}
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "secret")
+
+	client := newTestClient(Options{Transport: ProxyTransport(proxyURL)})
+	const target = "http://photos.invalid/pw/item"
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	r, _, _, _, err := DownloadMedia(client, target, false) // HEAD probe, then the download
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(got, testJPEG) {
+		t.Errorf("downloaded %d bytes through the proxy, want %d", len(got), len(testJPEG))
+	}
+
+	auth := "Basic dXNlcjpzZWNyZXQ=" // user:secret
+	want := []string{
+		"GET " + target + " " + auth,
+		"HEAD " + target + "=d " + auth,
+		"GET " + target + "=d " + auth,
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(seen, "\n") != strings.Join(want, "\n") {
+		t.Errorf("proxy saw\n%s\nwant\n%s", strings.Join(seen, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRequestPause(t *testing.T) {
+	const n = 10
+	tests := []struct {
+		name        string
+		minDelay    time.Duration
+		jitter      time.Duration
+		minDuration time.Duration // For n sequential requests
+		maxDuration time.Duration
+	}{
+		{"disabled", -1, -1, 0, n * defaultMinDelay / 4},
+		{"fixed delay only", 10 * time.Millisecond, -1, n * 10 * time.Millisecond, time.Second},
+		{"jitter only", -1, 5 * time.Millisecond, 0, n * 5 * time.Millisecond * 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer srv.Close()
+
+			client := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), Options{MinDelay: tt.minDelay, Jitter: tt.jitter, RequestsPerSecond: -1})
+			start := time.Now()
+			for i := 0; i < n; i++ {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+			if elapsed := time.Since(start); elapsed < tt.minDuration || elapsed > tt.maxDuration {
+				t.Errorf("%d requests took %v, want between %v and %v", n, elapsed, tt.minDuration, tt.maxDuration)
+			}
+		})
+	}
+
+	// Unset values fall back to the production defaults, where the rate limiter replaces the pause
+	client := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), Options{})
+	if client.limiter == nil || client.opts.RequestsPerSecond != defaultRequestsPerSecond {
+		t.Errorf("default client has no %d/s rate limiter", defaultRequestsPerSecond)
+	}
+	for i := 0; i < 100; i++ {
+		if d := client.pause(); d < defaultMinDelay || d >= defaultMinDelay+defaultJitter {
+			t.Fatalf("default pause %v, want between %v and %v", d, defaultMinDelay, defaultMinDelay+defaultJitter)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package googlephotos
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// googleURL is where cookies from a raw cookie string are scoped to (all of .google.com)
+var googleURL = &url.URL{Scheme: "https", Host: "photos.google.com", Path: "/"}
+
+// loadCookies adds the configured session cookies to the jar. raw is a Cookie
+// header value ("SID=...; HSID=..."), file a Netscape/curl cookies.txt export.
+// Returns how many cookies were added.
+func loadCookies(jar *cookiejar.Jar, raw, file string) (int, error) {
+	count := 0
+	if raw != "" {
+		var cookies []*http.Cookie
+		for _, part := range strings.Split(raw, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok || name == "" {
+				continue
+			}
+			cookies = append(cookies, &http.Cookie{Name: name, Value: value, Domain: ".google.com", Path: "/"})
+		}
+		jar.SetCookies(googleURL, cookies)
+		count += len(cookies)
+	}
+
+	if file != "" {
+		n, err := loadCookieFile(jar, file)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// loadCookieFile parses a Netscape cookie file:
+// domain, include-subdomains, path, secure, expiry, name, value (tab separated)
+func loadCookieFile(jar *cookiejar.Jar, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening cookie file: %w", err)
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// curl marks HttpOnly cookies with a prefix on an otherwise commented line
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			continue
+		}
+		domain := fields[0]
+		cookie := &http.Cookie{
+			Name:   fields[5],
+			Value:  fields[6],
+			Path:   fields[2],
+			Secure: strings.EqualFold(fields[3], "TRUE"),
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		if exp, err := strconv.ParseInt(fields[4], 10, 64); err == nil && exp > 0 {
+			cookie.Expires = time.Unix(exp, 0)
+		}
+		u := &url.URL{Scheme: "https", Host: strings.TrimPrefix(domain, "."), Path: "/"}
+		jar.SetCookies(u, []*http.Cookie{cookie})
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("error reading cookie file: %w", err)
+	}
+	return count, nil
+}
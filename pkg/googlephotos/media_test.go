@@ -0,0 +1,293 @@
+package googlephotos
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testJPEG = append([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F', 0}, bytes.Repeat([]byte{0x42}, 200)...)
+	testMP4  = append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}, bytes.Repeat([]byte{0x17}, 200)...)
+)
+
+// mediaServer serves an image at /img and a video at /vid with Google's rendition
+// suffixes, and records the Accept header of every request. With dropOnce the
+// first full GET of each file ends halfway, so the download has to resume.
+type mediaServer struct {
+	*httptest.Server
+	t        *testing.T
+	dropOnce bool
+
+	mu          sync.Mutex
+	accept      map[string]string // "METHOD /path=suffix", with " range" for Range requests -> Accept
+	gets        []string          // GETs in order, keyed like accept
+	dropped     map[string]bool
+	ignoreRange bool // Answer Range requests with the whole file, like servers without Range support
+}
+
+func newMediaServer(t *testing.T, dropOnce bool) *mediaServer {
+	t.Helper()
+	s := &mediaServer{t: t, dropOnce: dropOnce, accept: make(map[string]string), dropped: make(map[string]bool)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *mediaServer) serve(w http.ResponseWriter, r *http.Request) {
+	name, _, _ := strings.Cut(r.URL.Path, "=")
+	body, ct := testJPEG, "image/jpeg"
+	if name == "/vid" {
+		body, ct = testMP4, "video/mp4"
+	}
+
+	key := r.Method + " " + r.URL.Path
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader != "" {
+		key += " range"
+	}
+	s.mu.Lock()
+	s.accept[key] = r.Header.Get("Accept")
+	if r.Method == http.MethodGet {
+		s.gets = append(s.gets, key)
+	}
+	if s.ignoreRange {
+		rangeHeader = ""
+	}
+	drop := s.dropOnce && r.Method == http.MethodGet && rangeHeader == "" && !s.dropped[r.URL.Path]
+	if drop {
+		s.dropped[r.URL.Path] = true
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", ct)
+	if rangeHeader != "" {
+		var from int
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &from); err != nil || from >= len(body) {
+			http.Error(w, "bad range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", from, len(body)-1, len(body)))
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)-from))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(body[from:])
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if !drop {
+		w.Write(body)
+		return
+	}
+	// Send half the promised body, then cut the connection
+	w.Write(body[:len(body)/2])
+	w.(http.Flusher).Flush()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		s.t.Errorf("hijacking connection: %v", err)
+		return
+	}
+	conn.Close()
+}
+
+func TestDownloadAcceptOnlyOnImageGets(t *testing.T) {
+	srv := newMediaServer(t, true)
+	client := newTestClient(Options{DownloadAccept: "image/jpeg", SpoolThreshold: 16, SpoolDir: t.TempDir()})
+
+	for _, path := range []string{"/img", "/vid"} {
+		r, size, _, _, err := DownloadMedia(client, srv.URL+path, false)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := testJPEG
+		if path == "/vid" {
+			want = testMP4
+		}
+		if !bytes.Equal(got, want) || size != int64(len(want)) {
+			t.Errorf("%s: downloaded %d bytes (size %d), want the full %d", path, len(got), size, len(want))
+		}
+	}
+
+	want := map[string]string{
+		"HEAD /img=d":       "",
+		"GET /img=d":        "image/jpeg",
+		"GET /img=d range":  "image/jpeg",
+		"HEAD /vid=d":       "",
+		"GET /vid=dv":       "",
+		"GET /vid=dv range": "",
+	}
+	for key, accept := range want {
+		got, ok := srv.accept[key]
+		if !ok {
+			t.Errorf("no %s request", key)
+		} else if got != accept {
+			t.Errorf("%s sent Accept %q, want %q", key, got, accept)
+		}
+	}
+}
+
+func TestVerifyMediaType(t *testing.T) {
+	type rendition struct {
+		body []byte
+		ct   string
+	}
+	jpeg := rendition{testJPEG, "image/jpeg"}
+	mp4 := rendition{testMP4, "video/mp4"}
+	mislabeledMP4 := rendition{testMP4, "image/jpeg"}
+	tests := []struct {
+		name      string
+		d, dv     rendition
+		videoHint bool
+		verify    bool
+		wantErr   error
+		wantVideo bool
+		wantExt   string
+		wantGets  []string // Suffixes downloaded, in order
+	}{
+		{"matching image", jpeg, mp4, false, true, nil, false, ".jpg", []string{"=d"}},
+		{"matching video", mp4, mp4, true, true, nil, true, ".mp4", []string{"=dv"}},
+		{"thumbnail for a video", jpeg, jpeg, true, true, nil, false, ".jpg", []string{"=dv", "=d"}},
+		{"video bytes for an image", mislabeledMP4, mp4, false, true, nil, true, ".mp4", []string{"=d", "=dv"}},
+		{"wrong both ways", mp4, jpeg, true, true, ErrMediaTypeMismatch, false, "", []string{"=dv", "=d"}},
+		{"unchecked", jpeg, jpeg, true, false, nil, true, ".jpg", []string{"=dv"}}, // Uploaded mislabeled as a video
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var gets []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, suffix, _ := strings.Cut(r.URL.Path, "=")
+				rend := tt.d
+				if suffix == "dv" {
+					rend = tt.dv
+				}
+				if r.Method == http.MethodGet {
+					mu.Lock()
+					gets = append(gets, "="+suffix)
+					mu.Unlock()
+				}
+				w.Header().Set("Content-Type", rend.ct)
+				w.Write(rend.body)
+			}))
+			defer srv.Close()
+
+			client := newTestClient(Options{VerifyMediaType: tt.verify})
+			r, _, ext, isVideo, err := DownloadMedia(client, srv.URL+"/item", tt.videoHint)
+			if r != nil {
+				r.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (isVideo != tt.wantVideo || ext != tt.wantExt) {
+				t.Errorf("got video %v with %s, want video %v with %s", isVideo, ext, tt.wantVideo, tt.wantExt)
+			}
+			if strings.Join(gets, ",") != strings.Join(tt.wantGets, ",") {
+				t.Errorf("downloaded %v, want %v", gets, tt.wantGets)
+			}
+		})
+	}
+}
+
+func TestDownloadMediaRejectsHTML(t *testing.T) {
+	page := []byte("<!DOCTYPE html><html><body>Before you continue to Google</body></html>")
+	tests := []struct {
+		name    string
+		ct      string
+		body    []byte
+		video   bool
+		wantErr bool
+	}{
+		{"html content type", "text/html; charset=utf-8", page, false, true},
+		{"xhtml content type", "application/xhtml+xml", page, false, true},
+		{"html bytes labeled jpeg", "image/jpeg", page, false, true},
+		{"html tag after BOM and blank lines", "image/jpeg", append([]byte("\xEF\xBB\xBF\r\n  "), "<HTML><body>error</body></HTML>"...), false, true},
+		{"html bytes labeled video", "video/mp4", page, true, true},
+		{"large html page", "application/octet-stream", append(bytes.Clone(page), bytes.Repeat([]byte(" "), 4096)...), false, true},
+		{"real jpeg", "image/jpeg", testJPEG, false, false},
+		{"real video", "video/mp4", testMP4, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			gets := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.ct)
+				if r.Method == http.MethodHead {
+					return
+				}
+				mu.Lock()
+				gets++
+				mu.Unlock()
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			client := newTestClient(Options{DownloadRetries: 1, DownloadBackoff: time.Millisecond, SpoolThreshold: 1024, SpoolDir: dir})
+			r, _, _, _, err := DownloadMedia(client, srv.URL+"/item", tt.video)
+			if r != nil {
+				r.Close()
+			}
+			if tt.wantErr != errors.Is(err, ErrHTMLResponse) || !tt.wantErr && err != nil {
+				t.Fatalf("error %v, want ErrHTMLResponse: %v", err, tt.wantErr)
+			}
+			wantGets := 1
+			if tt.wantErr {
+				wantGets = 2 // An HTML answer is worth another attempt
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if gets != wantGets {
+				t.Errorf("%d downloads, want %d", gets, wantGets)
+			}
+			if files, _ := os.ReadDir(dir); len(files) != 0 {
+				t.Errorf("%d spool files left behind", len(files))
+			}
+		})
+	}
+}
+
+func TestDownloadQualitySuffix(t *testing.T) {
+	tests := []struct {
+		suffix  string
+		wantImg string
+		wantVid string
+	}{
+		{"", "GET /img=d", "GET /vid=dv"},
+		{"=w2048", "GET /img=w2048", "GET /vid=dv"},
+		{"=h1080", "GET /img=h1080", "GET /vid=dv"},
+		{"=s4096", "GET /img=s4096", "GET /vid=dv"},
+	}
+	for _, tt := range tests {
+		srv := newMediaServer(t, false)
+		client := newTestClient(Options{ImageSuffix: tt.suffix})
+		for _, path := range []string{"/img", "/vid"} {
+			r, _, _, _, err := DownloadMedia(client, srv.URL+path, path == "/vid")
+			if err != nil {
+				t.Fatalf("%q %s: %v", tt.suffix, path, err)
+			}
+			r.Close()
+		}
+		srv.mu.Lock()
+		if want := []string{tt.wantImg, tt.wantVid}; strings.Join(srv.gets, ", ") != strings.Join(want, ", ") {
+			t.Errorf("suffix %q: requested %v, want %v", tt.suffix, srv.gets, want)
+		}
+		srv.mu.Unlock()
+	}
+}
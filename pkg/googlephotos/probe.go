@@ -0,0 +1,80 @@
+package googlephotos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const probeChunkSize = 256 * 1024
+
+// scriptEnd closes the inline script holding the ds:1 data block
+var scriptEnd = []byte("</script>")
+
+// AlbumProbe is a cheap summary read from the first data segment of an album page
+type AlbumProbe struct {
+	ItemCount int  // Items in the first data segment (deduplicated)
+	Complete  bool // True when the album has no further pages, so ItemCount is the album total
+}
+
+// ProbeAlbum reads the album page only until the embedded ds:1 data block is
+// complete and stops there, without fetching further pages or the rest of the HTML.
+// Used to detect unchanged albums before running a full ScrapeAlbum.
+func ProbeAlbum(client *Client, albumURL string) (*AlbumProbe, error) {
+	resp, err := client.Get(albumURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to fetch album: %d", resp.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	chunk := make([]byte, probeChunkSize)
+	marker := -1  // Offset of the ds:1 marker in buf
+	searched := 0 // buf before this was already searched for the marker, then for the end of its script
+	var data []interface{}
+	for {
+		n, readErr := resp.Body.Read(chunk)
+		buf.Write(chunk[:n])
+		page := buf.Bytes()
+
+		// Parse once the script holding the data block is complete, rather than
+		// rescanning the growing block after every read
+		if marker == -1 {
+			if i := bytes.Index(page[searched:], ds1Marker); i != -1 {
+				marker = searched + i
+				searched = marker
+			} else {
+				searched = max(len(page)-len(ds1Marker)+1, 0)
+			}
+		}
+		if marker != -1 {
+			if bytes.Contains(page[searched:], scriptEnd) || readErr == io.EOF {
+				var err error
+				if data, err = extractAlbumData(page); err != nil {
+					return nil, err
+				}
+				break
+			}
+			searched = max(len(page)-len(scriptEnd)+1, marker)
+		}
+
+		if readErr == io.EOF {
+			return nil, fmt.Errorf("%w: could not find album data (ds:1) in page", ErrFormatChanged)
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+
+	photos, _, _ := DeduplicatePhotos(parsePhotoItems(albumItemList(data)))
+	client.logger.Debug("Probed album", "bytes_read", buf.Len(), "first_page_items", len(photos))
+
+	return &AlbumProbe{
+		ItemCount: len(photos),
+		Complete:  continuationToken(data) == "",
+	}, nil
+}
@@ -0,0 +1,67 @@
+package googlephotos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeAlbumStopsAtEndOfDataBlock(t *testing.T) {
+	var items []string
+	for i := 0; i < 50; i++ {
+		items = append(items, fmt.Sprintf(`["item%02d",["https://lh3.googleusercontent.com/pw/%02d",400,300],1700000000000,null,null]`, i, i))
+	}
+	page := albumPage(strings.Join(items, ","))
+	head := page[:strings.Index(page, "</body>")]
+
+	for _, piece := range []int{1 << 20, 997, 7, 1} {
+		t.Run(fmt.Sprint(piece), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Markers split across writes must still be found
+				for i := 0; i < len(head); i += piece {
+					w.Write([]byte(head[i:min(i+piece, len(head))]))
+					w.(http.Flusher).Flush()
+				}
+				// The rest of the page never comes, the probe must not wait for it
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			}))
+			defer srv.Close()
+
+			start := time.Now()
+			probe, err := ProbeAlbum(newTestClient(Options{}), srv.URL+"/share/x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if probe.ItemCount != 50 || !probe.Complete {
+				t.Errorf("probe = %+v, want 50 items, complete", *probe)
+			}
+			if time.Since(start) > 2*time.Second {
+				t.Error("probe waited for the rest of the page")
+			}
+		})
+	}
+}
+
+func TestProbeAlbumErrors(t *testing.T) {
+	pages := map[string]string{
+		"/no-data":   `<html><body><script>AF_initDataCallback({key: 'ds:0', data:[]});</script></body></html>`,
+		"/cut-off":   `<html><script>AF_initDataCallback({key: 'ds:1', hash: '2', data:[null,[["a",`,
+		"/malformed": `<html><script>AF_initDataCallback({key: 'ds:1', hash: '2', data:[null,[["a",</script></html>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	for path := range pages {
+		if _, err := ProbeAlbum(newTestClient(Options{}), srv.URL+path); err == nil {
+			t.Errorf("%s: probe succeeded, want an error", path)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package googlephotos
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests across every goroutine sharing a Client so they
+// never exceed a requests-per-second ceiling. A 429 halves the current rate
+// (down to a sixteenth of the ceiling); each answered request wins back a
+// twentieth of the ceiling until the configured rate is reached again.
+type rateLimiter struct {
+	mu   sync.Mutex
+	max  float64   // Configured requests per second
+	rate float64   // Current requests per second, lowered after 429s
+	next time.Time // Earliest time the next request may be sent
+}
+
+func newRateLimiter(rps float64) *rateLimiter {
+	return &rateLimiter{max: rps, rate: rps}
+}
+
+// wait blocks until the caller's turn to send a request
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = at.Add(time.Duration(float64(time.Second) / l.rate))
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// slowDown halves the rate after a 429 and returns the new rate
+func (l *rateLimiter) slowDown() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = max(l.rate/2, l.max/16)
+	return l.rate
+}
+
+// speedUp raises the rate a step towards the configured ceiling
+func (l *rateLimiter) speedUp() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate < l.max {
+		l.rate = min(l.rate+l.max/20, l.max)
+	}
+}
@@ -0,0 +1,84 @@
+package googlephotos
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterCeiling(t *testing.T) {
+	const (
+		rps        = 100
+		goroutines = 8
+		perWorker  = 5
+	)
+	var mu sync.Mutex
+	var sent []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, time.Now())
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	client := newTestClient(Options{RequestsPerSecond: rps})
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	mu.Lock()
+	defer mu.Unlock()
+	n := len(sent)
+	if n != goroutines*perWorker {
+		t.Fatalf("%d requests arrived, want %d", n, goroutines*perWorker)
+	}
+	// The first request goes out at once, every further one waits its slot
+	if least := time.Duration(n-1) * time.Second / rps; elapsed < least {
+		t.Errorf("%d requests took %v, a rate of %d/s allows no less than %v", n, elapsed, rps, least)
+	}
+	// No 100ms window holds more than its share, plus one for scheduling slack
+	const window = 100 * time.Millisecond
+	for i := range sent {
+		count := 0
+		for _, at := range sent {
+			if !at.Before(sent[i]) && at.Sub(sent[i]) < window {
+				count++
+			}
+		}
+		if limit := rps*int(window/time.Millisecond)/1000 + 1; count > limit {
+			t.Fatalf("%d requests within %v, want at most %d", count, window, limit)
+		}
+	}
+}
+
+func TestRateLimiterBackoff(t *testing.T) {
+	l := newRateLimiter(16)
+	for _, want := range []float64{8, 4, 2, 1, 1} {
+		if got := l.slowDown(); got != want {
+			t.Fatalf("slowDown = %v, want %v", got, want)
+		}
+	}
+	for i := 0; i < 19; i++ {
+		l.speedUp()
+	}
+	if l.rate != 16 {
+		t.Errorf("rate %v after recovering, want the ceiling of 16", l.rate)
+	}
+}
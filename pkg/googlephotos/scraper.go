@@ -3,9 +3,12 @@ package googlephotos
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io"
+	"math"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -13,10 +16,39 @@ import (
 	"time"
 )
 
+var (
+	metaTagRe    = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrRe       = regexp.MustCompile(`([\w:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titleTagRe   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	dateSuffixRe = regexp.MustCompile(`(?i)^(?:` + monthPattern + `|\d{1,4}|de|del|[\s.,/~～〜–—-]|[年月日년월일]|至)+$`)
+	dateMonthRe  = regexp.MustCompile(`(?i)(?:^|[^\p{L}])` + monthPattern + `|[月월]|\d{1,4}[./-]\d{1,2}`)
+	dateDayRe    = regexp.MustCompile(`(?:^|\D)\d{1,2}(?:\D|$)`)
+	ds1DataRe    = regexp.MustCompile(`key:\s*'ds:1'.*?data:`)
+	ds1Marker    = []byte("'ds:1'")
+	atTokenRe    = regexp.MustCompile(`"SNlM0e":"([^"]+)"`)
+	sidTokenRe   = regexp.MustCompile(`"FdrFJe":"([^"]+)"`)
+	blTokenRe    = regexp.MustCompile(`"cfb2h":"([^"]+)"`)
+	pathTokenRe  = regexp.MustCompile(`"eptZe":"([^"]+)"`)
+	videoNameRe  = regexp.MustCompile(`(?i)\.(mp4|m4v|mov|3gp|mkv|webm|avi)$`)
+	fileNameRe   = regexp.MustCompile(`(?i)^[^/\\:\n]{1,200}\.(jpe?g|png|gif|heic|heif|webp|avif|dng|tiff?|mp4|m4v|mov|3gp|mkv|webm|avi)$`)
+)
+
+// monthPattern matches a month name or abbreviation in the languages Google
+// localizes album dates into: English, German, French, Spanish, Italian,
+// Portuguese, Dutch, Polish and Russian ("Feb", "März", "févr.", "ene", "mrt", "февр.")
+const monthPattern = `(?:jan|feb|fév|fev|mar|mär|mrt|apr|avr|abr|may|mai|mag|mei|maj|jun|juin|giu|jul|juil|lug|aug|août|ago|sep|set|oct|okt|ott|out|nov|dec|déc|dez|dic|ene|gen|sty|lut|kwi|cze|lip|sie|wrz|paź|lis|gru|янв|фев|мар|апр|мая|май|июн|июл|авг|сен|окт|ноя|дек)\p{L}{0,7}\.?`
+
 type Album struct {
-	ID     string
-	Title  string
-	Photos []Photo
+	ID       string
+	MediaKey string // Album media key from the share URL or page data, empty if unknown
+	Title    string
+	RawTitle string // Title exactly as shared (og:title, else <title>), before CleanTitle
+	CoverID  string // ID of the album's cover item, empty if it couldn't be identified
+	Photos   []Photo
+
+	// Incomplete is set when not every page of the album could be fetched.
+	// Photos then holds only the items found before pagination stopped.
+	Incomplete bool
 }
 
 type Photo struct {
@@ -24,124 +56,332 @@ type Photo struct {
 	URL         string
 	Width       int
 	Height      int
-	TakenAt     time.Time
+	TakenAt     time.Time // In the zone the item was taken in when the page data has its offset
 	Description string
+	Uploader    string  // Display name of the contributor who added the item, empty if unknown
+	Latitude    float64 // 0,0 when the item has no location
+	Longitude   float64
+
+	IsMotionPhoto  bool   // Still image with an embedded motion clip
+	MotionVideoURL string // Base URL of the motion clip, set when IsMotionPhoto
+
+	FileName string // Original filename as uploaded to Google (e.g. "IMG_1234.JPG"), empty if not in the page data
+
+	// The page data marks the item as a video. A hint for DownloadMedia, which
+	// otherwise only has the HEAD probe's Content-Type to go on.
+	IsVideo bool
+}
+
+// CleanTitle removes what Google adds to shared album titles: the date range
+// suffix (" · Feb 6–7", " · 6.–7. März", " · 2月6日～7日") and the trailing camera
+// emoji. A middle dot followed by anything that isn't a date ("Summer · 2023")
+// is part of the title and kept.
+func CleanTitle(title string) string {
+	title = strings.TrimSuffix(strings.TrimSpace(title), " 📸")
+	if i := strings.LastIndex(title, "·"); i != -1 && isDateSuffix(title[i+len("·"):]) {
+		title = title[:i]
+	}
+	title = strings.TrimSpace(title)
+	return strings.TrimSuffix(title, " 📸")
+}
+
+// isDateSuffix reports whether s looks like the date (range) Google appends to
+// album titles: only month names, numbers and separators, with a day number
+// and a month ("Feb 6–7", "6–7 févr.", "06/02/2023 – 07/02/2023"). A bare
+// year or word is not a date.
+func isDateSuffix(s string) bool {
+	s = strings.TrimSpace(s)
+	return dateSuffixRe.MatchString(s) && dateMonthRe.MatchString(s) && dateDayRe.MatchString(s)
 }
 
 // ScrapeAlbum parses a Google Photos shared album URL and returns the Album structure.
 // Handles pagination automatically for albums with more than ~300 items.
+// The album page may come from the scrape cache when one is configured.
 func ScrapeAlbum(client *Client, albumURL string) (*Album, error) {
-	resp, err := client.Get(albumURL)
+	return scrapeAlbum(client, albumURL, true)
+}
+
+// RefetchAlbum is ScrapeAlbum bypassing the scrape cache, for when fresh media URLs are needed
+func RefetchAlbum(client *Client, albumURL string) (*Album, error) {
+	return scrapeAlbum(client, albumURL, false)
+}
+
+func scrapeAlbum(client *Client, albumURL string, useCache bool) (*Album, error) {
+	page, finalURL, err := client.fetchAlbumPage(albumURL, useCache)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to fetch album: %d", resp.StatusCode)
+	rawTitle := extractTitle(page)
+	if rawTitle == "" {
+		rawTitle = "Google Photos Album"
 	}
+	title := CleanTitle(rawTitle)
 
-	// Capture final URL after redirects (short URLs like photos.app.goo.gl redirect to photos.google.com)
-	finalURL := albumURL
-	if resp.Request != nil && resp.Request.URL != nil {
-		finalURL = resp.Request.URL.String()
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
+	data, err := extractAlbumData(page)
 	if err != nil {
+		if albumUnavailable(finalURL) {
+			// A consent or error page has no album data either, but isn't a format change
+			return nil, fmt.Errorf("%w: Google answered with %s", ErrAlbumUnavailable, finalURL)
+		}
+		if errors.Is(err, ErrFormatChanged) {
+			client.logger.Debug("Album page doesn't match the expected format", "url", finalURL,
+				"page_bytes", len(page), "excerpt", pageExcerpt(page))
+		}
 		return nil, err
 	}
-	htmlContent := string(bodyBytes)
+	client.logger.Debug("Parsed album data", "shape", arrayShape(data))
 
-	// Extract Title from OG:TITLE
-	title := "Google Photos Album"
-	titleRe := regexp.MustCompile(`<meta property="og:title" content="([^"]+)">`)
-	titleMatch := titleRe.FindStringSubmatch(htmlContent)
-	if len(titleMatch) > 1 {
-		title = titleMatch[1]
-	}
+	list := albumItemList(data)
 
-	// Clean Title
-	title = html.UnescapeString(title)
-	// Remove Date Range Suffix (e.g. " · Feb 6–7") and emojis
-	dateSuffixRe := regexp.MustCompile(`\s*·.*$`)
-	title = dateSuffixRe.ReplaceAllString(title, "")
-	title = strings.TrimSpace(title)
-	title = strings.TrimSuffix(title, " 📸")
+	// Parse initial batch of items from embedded page data
+	photos := parsePhotoItems(list)
 
-	// Find the start of the data
-	// Look for key: 'ds:1' followed by data:
-	startRe := regexp.MustCompile(`key:\s*'ds:1'.*?data:`)
-	loc := startRe.FindStringIndex(htmlContent)
-	if loc == nil {
-		return nil, fmt.Errorf("could not find album data (ds:1) in page")
-	}
+	// Extract pagination tokens for fetching remaining album items
+	wiz := extractWizTokens(page)
+	continueToken := continuationToken(data)
 
-	startPos := loc[1]
-	// Scan forward for first '['
-	jsonStart := -1
-	for i := startPos; i < len(htmlContent); i++ {
-		if htmlContent[i] == '[' {
-			jsonStart = i
-			break
+	sourcePath, mediaKey := extractAlbumPath(finalURL)
+
+	// Fallback: extract mediaKey from embedded album metadata at data[3][0]
+	if mediaKey == "" && len(data) > 3 {
+		if meta, ok := data[3].([]interface{}); ok && len(meta) > 0 {
+			if key, ok := meta[0].(string); ok && key != "" {
+				mediaKey = key
+			}
 		}
 	}
-	if jsonStart == -1 {
-		return nil, fmt.Errorf("could not find start of JSON array")
-	}
 
-	// Balance brackets to find the end of the JSON array
-	balance := 0
-	inString := false
-	escape := false
-	jsonEnd := -1
+	// Paginate through remaining pages via batchexecute API
+	// Note: wiz.AT (SNlM0e CSRF token) is NOT present on public shared album pages
+	// batchexecute works without it for public albums
+	incomplete := false
+	if continueToken != "" {
+		authKey := extractAuthKeyFromURL(finalURL)
 
-	for i := jsonStart; i < len(htmlContent); i++ {
-		char := htmlContent[i]
+		// Fallback: extract authKey from embedded album metadata at data[3][19]
+		if authKey == "" && len(data) > 3 {
+			if meta, ok := data[3].([]interface{}); ok && len(meta) > 19 {
+				if key, ok := meta[19].(string); ok {
+					authKey = key
+				}
+			}
+		}
 
-		if escape {
-			escape = false
-			continue
+		if mediaKey != "" {
+			client.logger.Info("Album has continuation token, fetching remaining items", "count", len(photos))
+			const maxPages = 500
+			pages := 1
+			for page := 0; page < maxPages && continueToken != ""; page++ {
+				client.logger.Debug("Fetching album page", "page", page+2, "total_items", len(photos))
+				nextPhotos, nextToken, fetchErr := fetchNextPage(client, mediaKey, authKey, continueToken, sourcePath, wiz)
+				if fetchErr != nil {
+					client.logger.Warn("Pagination stopped, album incomplete", "page", page+2, "items", len(photos), "error", fetchErr)
+					incomplete = true
+					break
+				}
+				if len(nextPhotos) == 0 {
+					break
+				}
+				photos = append(photos, nextPhotos...)
+				continueToken = nextToken
+				pages++
+			}
+			if continueToken != "" && pages > maxPages {
+				client.logger.Warn("Stopped paginating at page limit, album may be incomplete", "pages", pages, "items", len(photos))
+				incomplete = true
+			}
+			client.logger.Debug("Fetched album pages", "pages", pages, "items", len(photos))
+		} else {
+			client.logger.Warn("Could not determine album mediaKey, pagination skipped")
+			incomplete = true
 		}
+	}
 
-		if char == '\\' {
-			escape = true
-			continue
+	// The album metadata at data[3] references the cover item by ID or media URL
+	var coverID string
+	if len(data) > 3 {
+		if meta, ok := data[3].([]interface{}); ok {
+			coverID = findCoverID(meta, photos)
 		}
+	}
+
+	return &Album{
+		ID:         finalURL,
+		MediaKey:   mediaKey,
+		Title:      title,
+		RawTitle:   rawTitle,
+		CoverID:    coverID,
+		Photos:     photos,
+		Incomplete: incomplete,
+	}, nil
+}
 
-		if char == '"' {
-			inString = !inString
+// extractTitle returns the album title from the page's og:title meta tag, in any
+// attribute order and quote style, falling back to the <title> element without
+// Google's " - Google Photos" suffix. Entities are decoded. "" when neither is found.
+func extractTitle(page []byte) string {
+	for _, tag := range metaTagRe.FindAll(page, -1) {
+		attrs := make(map[string]string)
+		for _, m := range attrRe.FindAllSubmatch(tag, -1) {
+			attrs[strings.ToLower(string(m[1]))] = string(m[2]) + string(m[3]) + string(m[4])
+		}
+		if attrs["property"] != "og:title" && attrs["name"] != "og:title" {
 			continue
 		}
+		if title := strings.TrimSpace(html.UnescapeString(attrs["content"])); title != "" {
+			return title
+		}
+	}
 
-		if !inString {
-			if char == '[' {
-				balance++
-			} else if char == ']' {
-				balance--
-				if balance == 0 {
-					jsonEnd = i + 1
-					break
-				}
-			}
+	if m := titleTagRe.FindSubmatch(page); m != nil {
+		title := strings.TrimSpace(html.UnescapeString(string(m[1])))
+		return strings.TrimSpace(strings.TrimSuffix(title, " - Google Photos"))
+	}
+	return ""
+}
+
+// ErrFormatChanged is returned by ScrapeAlbum when the album page loaded but its
+// embedded data isn't where the scraper expects it, usually because Google changed the page
+var ErrFormatChanged = errors.New("Google Photos page format may have changed")
+
+// ErrAlbumUnavailable is returned by ScrapeAlbum when Google served something other
+// than the album, e.g. its cookie consent page or an error page for a deleted or
+// unshared album
+var ErrAlbumUnavailable = errors.New("Google Photos album unavailable")
+
+// albumUnavailable reports whether the URL an album page was served from (after
+// redirects) can't be the album: the consent interstitial or a path that isn't a
+// shared album's
+func albumUnavailable(finalURL string) bool {
+	u, err := url.Parse(finalURL)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "consent.google.com" {
+		return true
+	}
+	return !strings.Contains(u.Path, "/share/") && !strings.Contains(u.Path, "/album/")
+}
+
+// excerptBytes is how much of the page pageExcerpt returns
+const excerptBytes = 512
+
+// pageExcerpt returns the start of the ds:1 block, or of the page when the
+// marker is missing, for debugging format changes
+func pageExcerpt(page []byte) string {
+	from := 0
+	if marker := bytes.Index(page, ds1Marker); marker != -1 {
+		from = max(marker-64, 0)
+	}
+	return string(page[from:min(from+excerptBytes, len(page))])
+}
+
+// arrayShape describes the top-level elements of the ds:1 data, e.g.
+// "[array(4) array(300) string(120) null number]", without dumping the items
+func arrayShape(data []interface{}) string {
+	parts := make([]string, len(data))
+	for i, v := range data {
+		switch v := v.(type) {
+		case []interface{}:
+			parts[i] = fmt.Sprintf("array(%d)", len(v))
+		case string:
+			parts[i] = fmt.Sprintf("string(%d)", len(v))
+		case float64:
+			parts[i] = "number"
+		case nil:
+			parts[i] = "null"
+		default:
+			parts[i] = fmt.Sprintf("%T", v)
 		}
 	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
 
-	if jsonEnd == -1 {
-		return nil, fmt.Errorf("could not find end of JSON array")
+// ds1SearchWindow is how far past the ds:1 marker its "data:" key is looked for
+const ds1SearchWindow = 4096
+
+// extractAlbumData locates the embedded ds:1 data block in the album page and parses it.
+// Works on the raw page bytes so multi-MB pages aren't copied into strings.
+func extractAlbumData(page []byte) ([]interface{}, error) {
+	// Find the start of the data
+	// Look for key: 'ds:1' followed by data:
+	// Jump to the marker first so the regexp only runs on a small region around it
+	marker := bytes.Index(page, ds1Marker)
+	if marker == -1 {
+		return nil, fmt.Errorf("%w: could not find album data (ds:1) in page", ErrFormatChanged)
+	}
+	from := max(marker-64, 0)
+	loc := ds1DataRe.FindIndex(page[from:min(marker+ds1SearchWindow, len(page))])
+	if loc == nil {
+		return nil, fmt.Errorf("%w: could not find album data (ds:1) in page", ErrFormatChanged)
+	}
+
+	startPos := from + loc[1]
+	// Scan forward for first '['
+	offset := bytes.IndexByte(page[startPos:], '[')
+	if offset == -1 {
+		return nil, fmt.Errorf("%w: could not find start of JSON array", ErrFormatChanged)
+	}
+	jsonStart := startPos + offset
+
+	end := jsonArrayEnd(page[jsonStart:])
+	if end == -1 {
+		return nil, fmt.Errorf("%w: could not find end of JSON array", ErrFormatChanged)
 	}
 
-	jsonStr := htmlContent[jsonStart:jsonEnd]
-	
-	// Pre-cleanup of JSON string if needed (sometimes unescaping)
-	// Usually it's valid JSON directly in the script tag
-	
 	var data []interface{}
-	err = json.Unmarshal([]byte(jsonStr), &data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse album JSON: %v", err)
+	if err := json.Unmarshal(page[jsonStart:jsonStart+end], &data); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse album JSON: %v", ErrFormatChanged, err)
+	}
+
+	return data, nil
+}
+
+// jsonArrayEnd balances brackets from b[0] == '[' and returns the index just past
+// the matching ']', or -1 if the array is cut off. Brackets inside JSON strings are
+// ignored. Jumps between structural bytes instead of looking at every byte.
+func jsonArrayEnd(b []byte) int {
+	balance := 0
+	for i := 0; i < len(b); {
+		next := bytes.IndexAny(b[i:], `[]"`)
+		if next == -1 {
+			return -1
+		}
+		i += next
+		switch b[i] {
+		case '[':
+			balance++
+		case ']':
+			balance--
+			if balance == 0 {
+				return i + 1
+			}
+		case '"':
+			// Skip to the closing quote, stepping over escaped characters
+			i++
+			for {
+				q := bytes.IndexAny(b[i:], `"\`)
+				if q == -1 {
+					return -1
+				}
+				i += q
+				if b[i] == '"' {
+					break
+				}
+				i += 2
+				if i >= len(b) {
+					return -1
+				}
+			}
+		}
+		i++
 	}
+	return -1
+}
 
+// albumItemList returns the raw item list from the ds:1 data
+func albumItemList(data []interface{}) []interface{} {
 	// Structure: [metadata, [item1, item2, ...], token, ...]
 	// Index 1 is usually the item list.
 	var list []interface{}
@@ -156,12 +396,11 @@ func ScrapeAlbum(client *Client, albumURL string) (*Album, error) {
 			list = l
 		}
 	}
+	return list
+}
 
-	// Parse initial batch of items from embedded page data
-	photos := parsePhotoItems(list)
-
-	// Extract pagination tokens for fetching remaining album items
-	wiz := extractWizTokens(htmlContent)
+// continuationToken returns the pagination token from the ds:1 data, or "" for single-page albums
+func continuationToken(data []interface{}) string {
 	var continueToken string
 	// Primary: continuation token is at data[2]
 	if len(data) > 2 {
@@ -178,61 +417,44 @@ func ScrapeAlbum(client *Client, albumURL string) (*Album, error) {
 			}
 		}
 	}
+	return continueToken
+}
 
-	// Paginate through remaining pages via batchexecute API
-	// Note: wiz.AT (SNlM0e CSRF token) is NOT present on public shared album pages
-	// batchexecute works without it for public albums
-	if continueToken != "" {
-		sourcePath, mediaKey := extractAlbumPath(finalURL)
-		authKey := extractAuthKeyFromURL(finalURL)
+// findCoverID searches the album metadata for a string that matches an item's
+// ID or base URL and returns that item's ID. The cover is not at a fixed
+// position in the metadata, so nested arrays are walked up to a small depth.
+func findCoverID(meta []interface{}, photos []Photo) string {
+	byKey := make(map[string]string, len(photos)*2)
+	for _, p := range photos {
+		byKey[p.ID] = p.ID
+		byKey[p.URL] = p.ID
+	}
 
-		// Fallback: extract mediaKey from embedded album metadata at data[3][0]
-		if mediaKey == "" && len(data) > 3 {
-			if meta, ok := data[3].([]interface{}); ok && len(meta) > 0 {
-				if key, ok := meta[0].(string); ok && key != "" {
-					mediaKey = key
-				}
+	var walk func(v interface{}, depth int) string
+	walk = func(v interface{}, depth int) string {
+		switch val := v.(type) {
+		case string:
+			return byKey[val]
+		case []interface{}:
+			if depth > 4 {
+				return ""
 			}
-		}
-
-		// Fallback: extract authKey from embedded album metadata at data[3][19]
-		if authKey == "" && len(data) > 3 {
-			if meta, ok := data[3].([]interface{}); ok && len(meta) > 19 {
-				if key, ok := meta[19].(string); ok {
-					authKey = key
+			for _, child := range val {
+				if id := walk(child, depth+1); id != "" {
+					return id
 				}
 			}
 		}
+		return ""
+	}
 
-		if mediaKey != "" {
-			client.logger.Info("Album has continuation token, fetching remaining items", "count", len(photos))
-			const maxPages = 500
-			for page := 0; page < maxPages && continueToken != ""; page++ {
-				client.logger.Debug("Fetching album page", "page", page+2, "total_items", len(photos))
-				nextPhotos, nextToken, fetchErr := fetchNextPage(client, mediaKey, authKey, continueToken, sourcePath, wiz)
-				if fetchErr != nil {
-					client.logger.Warn("Pagination stopped", "page", page+2, "error", fetchErr)
-					break
-				}
-				if len(nextPhotos) == 0 {
-					break
-				}
-				photos = append(photos, nextPhotos...)
-				continueToken = nextToken
-			}
-		} else {
-			client.logger.Warn("Could not determine album mediaKey, pagination skipped")
+	// Skip meta[0], which is the album's own media key
+	for i := 1; i < len(meta); i++ {
+		if id := walk(meta[i], 0); id != "" {
+			return id
 		}
 	}
-
-	// Remove duplicate photos from overlapping pages
-	photos = deduplicatePhotos(photos)
-
-	return &Album{
-		ID:     finalURL,
-		Title:  title,
-		Photos: photos,
-	}, nil
+	return ""
 }
 
 // extractInt converts interface{} values to int64 (handles JSON string and float64)
@@ -288,9 +510,18 @@ func parsePhotoItems(list []interface{}) []Photo {
 		}
 
 		timestamp := extractTimestamp(itemArr)
+		if loc, ok := extractTZOffset(itemArr); ok && !timestamp.IsZero() {
+			timestamp = timestamp.In(loc)
+		}
+		lat, lon := extractLocation(itemArr)
+		motionURL := extractMotionVideoURL(itemArr, photoURL)
+		uploader := extractUploader(itemArr)
+		fileName := extractFileName(itemArr)
+		isVideo := motionURL == "" && extractIsVideo(itemArr, fileName) // Motion photos are downloaded as images
 
+		// The caption is the first string after the dedup key and the time zone offset
 		var description string
-		for i := 3; i < len(itemArr); i++ {
+		for i := tzOffsetIndex + 1; i < len(itemArr); i++ {
 			if d, ok := itemArr[i].(string); ok && d != "" {
 				description = d
 				break
@@ -305,6 +536,15 @@ func parsePhotoItems(list []interface{}) []Photo {
 				Height:      h,
 				TakenAt:     timestamp,
 				Description: description,
+				Uploader:    uploader,
+				Latitude:    lat,
+				Longitude:   lon,
+
+				IsMotionPhoto:  motionURL != "",
+				MotionVideoURL: motionURL,
+
+				FileName: fileName,
+				IsVideo:  isVideo,
 			})
 		}
 	}
@@ -321,19 +561,19 @@ type wizTokens struct {
 }
 
 // extractWizTokens parses WIZ_global_data tokens from page HTML for batchexecute requests
-func extractWizTokens(htmlContent string) wizTokens {
+func extractWizTokens(page []byte) wizTokens {
 	var tokens wizTokens
-	if m := regexp.MustCompile(`"SNlM0e":"([^"]+)"`).FindStringSubmatch(htmlContent); len(m) > 1 {
-		tokens.AT = m[1]
+	if m := atTokenRe.FindSubmatch(page); len(m) > 1 {
+		tokens.AT = string(m[1])
 	}
-	if m := regexp.MustCompile(`"FdrFJe":"([^"]+)"`).FindStringSubmatch(htmlContent); len(m) > 1 {
-		tokens.SID = m[1]
+	if m := sidTokenRe.FindSubmatch(page); len(m) > 1 {
+		tokens.SID = string(m[1])
 	}
-	if m := regexp.MustCompile(`"cfb2h":"([^"]+)"`).FindStringSubmatch(htmlContent); len(m) > 1 {
-		tokens.BL = m[1]
+	if m := blTokenRe.FindSubmatch(page); len(m) > 1 {
+		tokens.BL = string(m[1])
 	}
-	if m := regexp.MustCompile(`"eptZe":"([^"]+)"`).FindStringSubmatch(htmlContent); len(m) > 1 {
-		tokens.Path = m[1]
+	if m := pathTokenRe.FindSubmatch(page); len(m) > 1 {
+		tokens.Path = string(m[1])
 	}
 	if tokens.Path == "" {
 		tokens.Path = "/_/PhotosUi/"
@@ -492,40 +732,291 @@ func parseBatchResponse(body string) ([]Photo, string, error) {
 	return nil, "", fmt.Errorf("no valid response envelope found in batchexecute response")
 }
 
-// deduplicatePhotos removes duplicate photos based on their ID
-func deduplicatePhotos(photos []Photo) []Photo {
+// DeduplicatePhotos removes photos listed more than once (overlapping pages or
+// repeated entries), preserving order and keeping the first occurrence, and drops
+// items without an ID. Returns the kept photos and how many of each were removed.
+func DeduplicatePhotos(photos []Photo) ([]Photo, int, int) {
 	seen := make(map[string]bool, len(photos))
 	result := make([]Photo, 0, len(photos))
+	var duplicates, idless int
 	for _, p := range photos {
-		if p.ID != "" && !seen[p.ID] {
+		switch {
+		case p.ID == "":
+			idless++
+		case seen[p.ID]:
+			duplicates++
+		default:
 			seen[p.ID] = true
 			result = append(result, p)
 		}
 	}
-	return result
+	return result, duplicates, idless
+}
+
+// extractLocation looks for a [latitude, longitude] pair in the item's metadata
+// sub-arrays (index 2 onwards, searched one level deep). Coordinates are stored
+// as decimal degrees; a pair qualifies when both values are in range and at least
+// one has a fractional part, which rules out the integer sizes and timestamps
+// nearby. The 0,0 "null island" sentinel means no location and is ignored.
+func extractLocation(itemArr []interface{}) (float64, float64) {
+	var search func(arr []interface{}, depth int) (float64, float64, bool)
+	search = func(arr []interface{}, depth int) (float64, float64, bool) {
+		for i := 0; i+1 < len(arr); i++ {
+			lat, ok1 := arr[i].(float64)
+			lon, ok2 := arr[i+1].(float64)
+			if ok1 && ok2 && validLocation(lat, lon) {
+				return lat, lon, true
+			}
+		}
+		if depth == 0 {
+			return 0, 0, false
+		}
+		for _, v := range arr {
+			if sub, ok := v.([]interface{}); ok {
+				if lat, lon, ok := search(sub, depth-1); ok {
+					return lat, lon, true
+				}
+			}
+		}
+		return 0, 0, false
+	}
+
+	for i := 2; i < len(itemArr); i++ {
+		if sub, ok := itemArr[i].([]interface{}); ok {
+			if lat, lon, ok := search(sub, 1); ok {
+				return lat, lon
+			}
+		}
+	}
+	return 0, 0
+}
+
+// extractMotionVideoURL returns the motion clip URL of a motion photo. Google lists
+// motion photos as image items whose metadata (index 2 onwards) carries a second
+// googleusercontent base URL for the video part; regular items only have the
+// primary URL at index 1.
+func extractMotionVideoURL(itemArr []interface{}, photoURL string) string {
+	var search func(arr []interface{}, depth int) string
+	search = func(arr []interface{}, depth int) string {
+		for _, v := range arr {
+			switch v := v.(type) {
+			case string:
+				if v != photoURL && strings.HasPrefix(v, "https://") && strings.Contains(v, ".googleusercontent.com/") && !isProfilePhotoURL(v) {
+					return v
+				}
+			case []interface{}:
+				if depth > 0 {
+					if u := search(v, depth-1); u != "" {
+						return u
+					}
+				}
+			}
+		}
+		return ""
+	}
+
+	for i := 2; i < len(itemArr); i++ {
+		if sub, ok := itemArr[i].([]interface{}); ok {
+			if u := search(sub, 2); u != "" {
+				return u
+			}
+		}
+	}
+	return ""
+}
+
+// extractUploader returns the display name of the contributor who added an item.
+// Items of albums with several contributors carry the contributor as a small
+// array holding the name next to the profile photo URL (googleusercontent.com/a/...);
+// that array is searched for in the item's metadata (index 2 onwards). Returns
+// "" when there is none, e.g. for most single-owner albums.
+func extractUploader(itemArr []interface{}) string {
+	var search func(arr []interface{}, depth int) string
+	search = func(arr []interface{}, depth int) string {
+		hasProfile := false
+		for _, v := range arr {
+			if u, ok := v.(string); ok && isProfilePhotoURL(u) {
+				hasProfile = true
+				break
+			}
+		}
+		if hasProfile {
+			for _, v := range arr {
+				if name, ok := v.(string); ok && looksLikeName(name) {
+					return name
+				}
+			}
+		}
+		if depth == 0 {
+			return ""
+		}
+		for _, v := range arr {
+			if sub, ok := v.([]interface{}); ok {
+				if name := search(sub, depth-1); name != "" {
+					return name
+				}
+			}
+		}
+		return ""
+	}
+
+	for i := 2; i < len(itemArr); i++ {
+		if sub, ok := itemArr[i].([]interface{}); ok {
+			if name := search(sub, 3); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// extractFileName returns the item's original filename. Some items carry it as a
+// plain string in a metadata sub-array; the first string in those sub-arrays
+// (index 2 onwards, a few levels deep) that looks like a media filename is taken.
+// Strings directly in the item are captions, even one like "beach.jpg".
+func extractFileName(itemArr []interface{}) string {
+	var search func(v interface{}, depth int) string
+	search = func(v interface{}, depth int) string {
+		switch v := v.(type) {
+		case string:
+			if fileNameRe.MatchString(v) {
+				return v
+			}
+		case []interface{}:
+			if depth == 0 {
+				return ""
+			}
+			for _, sub := range v {
+				if name := search(sub, depth-1); name != "" {
+					return name
+				}
+			}
+		}
+		return ""
+	}
+
+	for i := 2; i < len(itemArr); i++ {
+		if sub, ok := itemArr[i].([]interface{}); ok {
+			if name := search(sub, 3); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// videoInfoKey is the key of the object Google attaches to video items (playback
+// status and duration), e.g. {"76647426": [[...], 12345]}. Photos don't carry it.
+const videoInfoKey = "76647426"
+
+// extractIsVideo reports whether the page data describes the item as a video:
+// its metadata (index 2 onwards) holds the video info object, or its original
+// filename has a video extension
+func extractIsVideo(itemArr []interface{}, fileName string) bool {
+	if videoNameRe.MatchString(fileName) {
+		return true
+	}
+	var search func(v interface{}, depth int) bool
+	search = func(v interface{}, depth int) bool {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			_, ok := v[videoInfoKey]
+			return ok
+		case []interface{}:
+			if depth == 0 {
+				return false
+			}
+			for _, sub := range v {
+				if search(sub, depth-1) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for i := 2; i < len(itemArr); i++ {
+		if search(itemArr[i], 2) {
+			return true
+		}
+	}
+	return false
+}
+
+// isProfilePhotoURL reports whether u is a Google account avatar rather than media
+func isProfilePhotoURL(u string) bool {
+	rest, ok := strings.CutPrefix(u, "https://")
+	if !ok {
+		return false
+	}
+	host, path, _ := strings.Cut(rest, "/")
+	return strings.HasSuffix(host, ".googleusercontent.com") && (strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "a-/"))
+}
+
+// looksLikeName tells a display name from the URLs and IDs stored next to it
+func looksLikeName(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > 100 || strings.Contains(s, "://") || strings.HasPrefix(s, "AF1Qip") {
+		return false
+	}
+	return strings.TrimLeft(s, "0123456789") != "" // Actor IDs are numeric
 }
 
-// extractTimestamp extracts the best available timestamp from a scraped item
+func validLocation(lat, lon float64) bool {
+	if lat == 0 && lon == 0 {
+		return false
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return false
+	}
+	return lat != math.Trunc(lat) || lon != math.Trunc(lon)
+}
+
+// takenAtIndex is the item field holding the capture time. Shared album items look like
+// [id, [url, w, h, ...], takenAt, dedupKey, tzOffset, uploadedAt, ...]: the upload
+// time and other epoch-like numbers follow, so the first one is the one to trust.
+const takenAtIndex = 2
+
+// tzOffsetIndex is the item field holding the capture time's UTC offset in milliseconds
+const tzOffsetIndex = 4
+
+// extractTZOffset returns the zone the item was taken in, as a fixed offset.
+// Only whole quarter hours within ±14h are accepted, anything else means the
+// field is missing or holds something else.
+func extractTZOffset(itemArr []interface{}) (*time.Location, bool) {
+	if tzOffsetIndex >= len(itemArr) {
+		return nil, false
+	}
+	ms, ok := extractInt(itemArr[tzOffsetIndex])
+	if !ok || ms%(15*60*1000) != 0 || ms > 14*3600*1000 || ms < -14*3600*1000 {
+		return nil, false
+	}
+	return time.FixedZone("", int(ms/1000)), true
+}
+
+// extractTimestamp returns the capture time of an item. It reads the takenAtIndex
+// field and only falls back to the oldest plausible epoch anywhere in the item
+// when that field is missing or not a timestamp.
 func extractTimestamp(itemArr []interface{}) time.Time {
 	now := time.Now()
+	if takenAtIndex < len(itemArr) {
+		if t, ok := plausibleTimestamp(itemArr[takenAtIndex], now); ok {
+			return time.UnixMilli(t)
+		}
+	}
+
 	var candidates []int64
 
 	// Collect all plausible timestamps from the item
 	for i := 2; i < len(itemArr); i++ {
 		if metaArr, ok := itemArr[i].([]interface{}); ok && len(metaArr) > 0 {
-			if t, ok := extractInt(metaArr[0]); ok {
-				t = normalizeTimestamp(t)
-				if t > 946684800000 && time.UnixMilli(t).Before(now.Add(24*time.Hour)) {
-					candidates = append(candidates, t)
-				}
-			}
-		}
-		if t, ok := extractInt(itemArr[i]); ok {
-			t = normalizeTimestamp(t)
-			if t > 946684800000 && time.UnixMilli(t).Before(now.Add(24*time.Hour)) {
+			if t, ok := plausibleTimestamp(metaArr[0], now); ok {
 				candidates = append(candidates, t)
 			}
 		}
+		if t, ok := plausibleTimestamp(itemArr[i], now); ok {
+			candidates = append(candidates, t)
+		}
 	}
 
 	if len(candidates) == 0 {
@@ -543,6 +1034,20 @@ func extractTimestamp(itemArr []interface{}) time.Time {
 	return time.UnixMilli(best)
 }
 
+// plausibleTimestamp returns v in epoch milliseconds if it is a number between
+// 2000-01-01 and tomorrow
+func plausibleTimestamp(v interface{}, now time.Time) (int64, bool) {
+	t, ok := extractInt(v)
+	if !ok {
+		return 0, false
+	}
+	t = normalizeTimestamp(t)
+	if t > 946684800000 && time.UnixMilli(t).Before(now.Add(24*time.Hour)) {
+		return t, true
+	}
+	return 0, false
+}
+
 // extensionFromContentType maps Content-Type to file extension
 func extensionFromContentType(contentType string) string {
 	ct := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
@@ -575,13 +1080,48 @@ func extensionFromContentType(contentType string) string {
 	}
 }
 
+// ErrVideoTooLarge is returned by DownloadMedia for videos above Options.MaxVideoBytes
+var ErrVideoTooLarge = errors.New("video exceeds size limit")
+
+// ErrRestricted is returned by DownloadMedia when Google answers 403: the owner
+// disabled downloads for the item or its media URL has expired
+var ErrRestricted = errors.New("download not permitted (restricted or expired URL)")
+
+// statusError is a non-200 media response. 5xx answers were already retried by doWithRetry.
+type statusError struct {
+	kind   string
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("failed to download %s: %d", e.kind, e.status)
+}
+
+// downloadStatusError builds the error for a non-200 media response
+func downloadStatusError(kind string, status int) error {
+	if status == 403 {
+		return fmt.Errorf("failed to download %s: %w", kind, ErrRestricted)
+	}
+	return &statusError{kind: kind, status: status}
+}
+
 // DownloadMedia downloads original media from Google Photos.
 // Uses =d for original quality images (preserves motion photo data for Immich), =dv for videos.
-// Response is buffered to guarantee accurate Content-Length for the upload.
+// Options.ImageSuffix replaces =d with a reduced size, which drops the motion part.
+// The body is downloaded completely (in memory, or a temp file above SpoolThreshold)
+// to guarantee an accurate size for the upload; always close the returned body.
+// With VerifyMediaType the bytes are sniffed and a video/image mismatch is re-requested once.
+// videoHint (Photo.IsVideo) makes it fetch =dv even when the HEAD probe has no or a
+// non-video Content-Type, which Google answers for some videos (=d being the thumbnail).
 // Returns: body, size, extension (e.g. ".jpg"), isVideo, error
-func DownloadMedia(client *Client, baseUrl string) (io.ReadCloser, int64, string, bool, error) {
+func DownloadMedia(client *Client, baseUrl string, videoHint bool) (io.ReadCloser, int64, string, bool, error) {
 	// HEAD probe to detect content type without downloading body
-	probeResp, err := client.Head(baseUrl + "=d")
+	var probeResp *http.Response
+	err := client.retryDownload(func() error {
+		var err error
+		probeResp, err = client.Head(baseUrl + "=d")
+		return err
+	})
 	if err != nil {
 		return nil, 0, "", false, err
 	}
@@ -589,46 +1129,166 @@ func DownloadMedia(client *Client, baseUrl string) (io.ReadCloser, int64, string
 
 	probeCt := probeResp.Header.Get("Content-Type")
 	isVideo := strings.HasPrefix(strings.ToLower(probeCt), "video/")
+	if videoHint && !isVideo {
+		client.logger.Debug("Page data marks item as video, ignoring probe Content-Type", "content_type", probeCt)
+		isVideo = true
+	}
 
-	// Pure video: download with =dv
-	if isVideo {
-		resp, err := client.Get(baseUrl + "=dv")
-		if err != nil {
-			return nil, 0, "", false, err
-		}
-		if resp.StatusCode != 200 {
-			resp.Body.Close()
-			return nil, 0, "", false, fmt.Errorf("failed to download video: %d", resp.StatusCode)
+	maxVideo := client.opts.MaxVideoBytes
+	if isVideo && maxVideo > 0 && probeResp.ContentLength > maxVideo {
+		return nil, 0, "", true, fmt.Errorf("%w: %d bytes (limit %d)", ErrVideoTooLarge, probeResp.ContentLength, maxVideo)
+	}
+
+	body, ct, err := fetchMedia(client, baseUrl, isVideo)
+	if err != nil {
+		return nil, 0, "", isVideo, err
+	}
+
+	if client.opts.VerifyMediaType {
+		if kind := sniffMediaKind(body.head); kind != mediaUnknown && (kind == mediaVideo) != isVideo {
+			// e.g. a thumbnail JPEG served for a video: ask for the other rendition once
+			client.logger.Warn("Downloaded bytes don't match the expected media type, re-requesting",
+				"expected_video", isVideo, "sniffed", kind)
+			body.Discard()
+			isVideo = !isVideo
+			body, ct, err = fetchMedia(client, baseUrl, isVideo)
+			if err != nil {
+				return nil, 0, "", isVideo, err
+			}
+			if kind := sniffMediaKind(body.head); kind != mediaUnknown && (kind == mediaVideo) != isVideo {
+				body.Discard()
+				return nil, 0, "", isVideo, fmt.Errorf("%w: got %s bytes for both =d and =dv", ErrMediaTypeMismatch, kind)
+			}
 		}
-		// Buffer video for accurate size
-		data, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, 0, "", false, fmt.Errorf("failed to read video data: %w", err)
+	}
+
+	if client.opts.VerifyMediaType && strings.HasPrefix(strings.ToLower(ct), "video/") != isVideo {
+		// The header disagrees with the verified bytes, name the file after the bytes
+		ct = http.DetectContentType(body.head)
+	}
+	ext := extensionFromContentType(ct)
+	if isVideo {
+		ext = videoExtension(ct, body.head)
+	}
+	return body.Reader(), body.size, ext, isVideo, nil
+}
+
+// fetchMedia downloads one rendition: =dv for videos (bounded by MaxVideoBytes),
+// =d or Options.ImageSuffix for images (motion photos are preserved as-is for Immich at =d).
+// Returns the body and the response Content-Type.
+func fetchMedia(client *Client, baseUrl string, isVideo bool) (*mediaBody, string, error) {
+	if !isVideo {
+		suffix := client.opts.ImageSuffix
+		if suffix == "" {
+			suffix = "=d"
 		}
-		ct := resp.Header.Get("Content-Type")
-		ext := extensionFromContentType(ct)
-		return io.NopCloser(bytes.NewReader(data)), int64(len(data)), ext, true, nil
+		return fetchImage(client, baseUrl+suffix)
 	}
 
-	// Image: download original with =d (motion photos are preserved as-is for Immich)
-	resp, err := client.Get(baseUrl + "=d")
+	var body *mediaBody
+	var ct string
+	err := client.retryDownload(func() error {
+		var err error
+		body, ct, err = fetchVideo(client, baseUrl+"=dv")
+		return err
+	})
+	return body, ct, err
+}
+
+// fetchVideo makes one attempt at downloading a video URL
+func fetchVideo(client *Client, videoURL string) (*mediaBody, string, error) {
+	maxVideo := client.opts.MaxVideoBytes
+	resp, err := client.getMedia(videoURL, "")
 	if err != nil {
-		return nil, 0, "", false, err
+		return nil, "", err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		resp.Body.Close()
-		return nil, 0, "", false, fmt.Errorf("failed to download image: %d", resp.StatusCode)
+		return nil, "", downloadStatusError("video", resp.StatusCode)
 	}
-
-	// Buffer to guarantee accurate size (HTTP Content-Length can be -1 for chunked responses)
-	data, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
+	if maxVideo > 0 && resp.ContentLength > maxVideo {
+		return nil, "", fmt.Errorf("%w: %d bytes (limit %d)", ErrVideoTooLarge, resp.ContentLength, maxVideo)
+	}
+	ct := resp.Header.Get("Content-Type")
+	if looksLikeHTML(ct, nil) {
+		return nil, "", fmt.Errorf("failed to download video: %w (Content-Type %s)", ErrHTMLResponse, ct)
+	}
+	// Read the whole video for an accurate size. Without a known length, stop reading just past the limit.
+	var r io.Reader = resp.Body
+	if maxVideo > 0 {
+		r = io.LimitReader(resp.Body, maxVideo+1)
+	}
+	body, err := client.readBody(r, client.resumer(videoURL, "", maxVideo))
 	if err != nil {
-		return nil, 0, "", false, fmt.Errorf("failed to read image data: %w", err)
+		return nil, "", fmt.Errorf("failed to read video data: %w", err)
+	}
+	if maxVideo > 0 && body.size > maxVideo {
+		body.Discard()
+		return nil, "", fmt.Errorf("%w: more than %d bytes", ErrVideoTooLarge, maxVideo)
+	}
+	if looksLikeHTML(ct, body.head) {
+		body.Discard()
+		return nil, "", fmt.Errorf("failed to download video: %w", ErrHTMLResponse)
 	}
+	return body, ct, nil
+}
 
+// fetchImage downloads an image URL, retrying transient failures
+func fetchImage(client *Client, imageURL string) (*mediaBody, string, error) {
+	var body *mediaBody
+	var ct string
+	err := client.retryDownload(func() error {
+		var err error
+		body, ct, err = fetchImageOnce(client, imageURL)
+		return err
+	})
+	return body, ct, err
+}
+
+// fetchImageOnce makes one attempt at downloading an image URL
+func fetchImageOnce(client *Client, imageURL string) (*mediaBody, string, error) {
+	resp, err := client.getMedia(imageURL, client.opts.DownloadAccept)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, "", downloadStatusError("image", resp.StatusCode)
+	}
 	ct := resp.Header.Get("Content-Type")
-	ext := extensionFromContentType(ct)
-	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), ext, false, nil
+	if looksLikeHTML(ct, nil) {
+		return nil, "", fmt.Errorf("failed to download image: %w (Content-Type %s)", ErrHTMLResponse, ct)
+	}
+
+	// Read completely to guarantee accurate size (HTTP Content-Length can be -1 for chunked responses)
+	body, err := client.readBody(resp.Body, client.resumer(imageURL, client.opts.DownloadAccept, 0))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image data: %w", err)
+	}
+	if looksLikeHTML(ct, body.head) {
+		body.Discard()
+		return nil, "", fmt.Errorf("failed to download image: %w", ErrHTMLResponse)
+	}
+	return body, ct, nil
+}
+
+// DownloadMotionVideo downloads the video part of a motion photo (=dv rendition,
+// bounded by MaxVideoBytes). Returns: body, size, extension, error
+func DownloadMotionVideo(client *Client, videoURL string) (io.ReadCloser, int64, string, error) {
+	body, ct, err := fetchMedia(client, videoURL, true)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return body.Reader(), body.size, videoExtension(ct, body.head), nil
+}
+
+// DownloadVariant downloads a specific rendition of an image, e.g. "=w2048" for a
+// resized copy or "=d" for the original. Used as a fallback when the original
+// can't be downloaded. Returns: body, size, extension, error
+func DownloadVariant(client *Client, baseUrl, suffix string) (io.ReadCloser, int64, string, error) {
+	body, ct, err := fetchImage(client, baseUrl+suffix)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return body.Reader(), body.size, extensionFromContentType(ct), nil
 }
@@ -0,0 +1,492 @@
+package googlephotos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client without pauses between requests, with logs discarded
+func newTestClient(opts Options) *Client {
+	opts.MinDelay, opts.Jitter = -1, -1
+	if opts.RequestsPerSecond == 0 {
+		opts.RequestsPerSecond = -1
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 1
+	}
+	return NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), opts)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// albumPage is a minimal album page holding items in its ds:1 block
+func albumPage(items string) string {
+	return `<html><head><meta property="og:title" content="Trip"></head><body>` +
+		`<script>AF_initDataCallback({key: 'ds:1', hash: '2', data:[null,[` + items + `],null]});</script></body></html>`
+}
+
+func TestScrapeAlbumErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/share/ok", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, albumPage(`["a",["https://lh3.googleusercontent.com/pw/a",400,300],1700000000000,null,null]`))
+	})
+	mux.HandleFunc("/share/changed", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><body><script>AF_initDataCallback({key: 'ds:0', data:[]});</script></body></html>`)
+	})
+	mux.HandleFunc("/share/broken", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><script>AF_initDataCallback({key: 'ds:1', hash: '2', data:[null,[["a",</script></html>`)
+	})
+	mux.HandleFunc("/share/nodata", func(w http.ResponseWriter, r *http.Request) {
+		// The data key is only found far past the marker, in another block
+		io.WriteString(w, `<html><script>AF_initDataCallback({key: 'ds:1', hash: '2'});</script>`+
+			strings.Repeat(" ", 8192)+`<script>AF_initDataCallback({key: 'ds:2', data:[null,[]]});</script></html>`)
+	})
+	mux.HandleFunc("/share/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/error?reason=unavailable", http.StatusFound)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><body>This album is no longer available</body></html>`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		want error // nil for a successful scrape
+	}{
+		{"/share/ok", nil},
+		{"/share/changed", ErrFormatChanged},
+		{"/share/broken", ErrFormatChanged},
+		{"/share/nodata", ErrFormatChanged},
+		{"/share/gone", ErrAlbumUnavailable},
+	}
+	client := newTestClient(Options{})
+	for _, tt := range tests {
+		album, err := ScrapeAlbum(client, srv.URL+tt.path)
+		switch {
+		case tt.want == nil && err != nil:
+			t.Errorf("%s: %v", tt.path, err)
+		case tt.want == nil && len(album.Photos) != 1:
+			t.Errorf("%s: scraped %d items, want 1", tt.path, len(album.Photos))
+		case tt.want != nil && !errors.Is(err, tt.want):
+			t.Errorf("%s: error %v, want %v", tt.path, err, tt.want)
+		}
+		if errors.Is(tt.want, ErrAlbumUnavailable) && errors.Is(err, ErrFormatChanged) {
+			t.Errorf("%s: unavailable album reported as a format change", tt.path)
+		}
+	}
+}
+
+func TestAlbumUnavailable(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://photos.google.com/share/AF1QipN?key=abc", false},
+		{"https://photos.google.com/u/0/album/AF1QipN", false},
+		{"https://consent.google.com/ml?continue=https://photos.google.com/share/AF1QipN", true},
+		{"https://photos.google.com/", true},
+		{"https://photos.google.com/error", true},
+	}
+	for _, tt := range tests {
+		if got := albumUnavailable(tt.url); got != tt.want {
+			t.Errorf("albumUnavailable(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestDeduplicatePhotos(t *testing.T) {
+	photos := []Photo{{ID: "a", URL: "first"}, {ID: "b"}, {ID: ""}, {ID: "a", URL: "second"}, {ID: "c"}, {ID: "b"}}
+	got, duplicates, idless := DeduplicatePhotos(photos)
+
+	var ids []string
+	for _, p := range got {
+		ids = append(ids, p.ID)
+	}
+	if strings.Join(ids, ",") != "a,b,c" {
+		t.Errorf("kept %v, want [a b c]", ids)
+	}
+	if got[0].URL != "first" {
+		t.Errorf("kept the %s occurrence of a, want the first", got[0].URL)
+	}
+	if duplicates != 2 || idless != 1 {
+		t.Errorf("duplicates, idless = %d, %d, want 2, 1", duplicates, idless)
+	}
+}
+
+// largeAlbumPage generates an album page shaped like Google's: the WIZ tokens
+// up front, then a few MB of other scripts before the ds:1 block with n items
+func largeAlbumPage(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<html><head><meta property="og:title" content="Trip · Feb 6–7"></head><body><script>`)
+	b.WriteString(`window.WIZ_global_data = {"SNlM0e":"AT:token","FdrFJe":"-123456","cfb2h":"boq_photosuiserver_20240101","eptZe":"/_/PhotosUi/"};</script>`)
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&b, `<script>AF_initDataCallback({key: 'ds:%d', hash: '%d', data:[%q,[1,2,3],null]});</script>`, i+2, i, strings.Repeat("x", 1000))
+	}
+	b.WriteString(`<script>AF_initDataCallback({key: 'ds:1', hash: '2', data:[null,[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `["AF1QipItem%04d",["https://lh3.googleusercontent.com/pw/AP1Gcz%04d",4032,3024,null,null,null,null,null,[1,2]],%d,"caption %d",null,[["IMG_%04d.jpg",null,[52.37,4.89]]]]`,
+			i, i, 1700000000000+int64(i)*1000, i, i)
+	}
+	b.WriteString(`],null]});</script></body></html>`)
+	return b.Bytes()
+}
+
+func BenchmarkExtractAlbumData(b *testing.B) {
+	page := largeAlbumPage(300)
+	data, err := extractAlbumData(page)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if n := len(parsePhotoItems(albumItemList(data))); n != 300 {
+		b.Fatalf("fixture parsed to %d items, want 300", n)
+	}
+	b.SetBytes(int64(len(page)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := extractAlbumData(page); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtractWizTokens(b *testing.B) {
+	page := largeAlbumPage(300)
+	if tokens := extractWizTokens(page); tokens.AT != "AT:token" || tokens.BL == "" {
+		b.Fatalf("fixture tokens not found: %+v", tokens)
+	}
+	b.SetBytes(int64(len(page)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		extractWizTokens(page)
+	}
+}
+
+func BenchmarkParsePhotoItems(b *testing.B) {
+	data, err := extractAlbumData(largeAlbumPage(300))
+	if err != nil {
+		b.Fatal(err)
+	}
+	list := albumItemList(data)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parsePhotoItems(list)
+	}
+}
+
+func TestExtractLocation(t *testing.T) {
+	// Items as in the ds:1 list: [id, [url, w, h], takenAt, dedupKey, tzOffset, metadata...]
+	const head = `"AF1Qip1",["https://lh3.googleusercontent.com/pw/a",4032,3024],1700000000000,"key",3600000`
+	tests := []struct {
+		name     string
+		meta     string
+		lat, lon float64
+	}{
+		{"nested pair", `[null,[52.3702,4.8952]]`, 52.3702, 4.8952},
+		{"pair after other numbers", `[[1700000000000,3600000,-33.8688,151.2093]]`, -33.8688, 151.2093},
+		{"integer latitude", `[[48,2.3522]]`, 48, 2.3522},
+		{"null island", `[[0.0,0.0]]`, 0, 0},
+		{"integer pair", `[[12,34]]`, 0, 0},
+		{"out of range", `[[123.5,45.1]]`, 0, 0},
+		{"too deep", `[[[[52.1,4.2]]]]`, 0, 0},
+		{"no metadata", ``, 0, 0},
+	}
+	for _, tt := range tests {
+		raw := "[" + head
+		if tt.meta != "" {
+			raw += "," + tt.meta
+		}
+		raw += "]"
+		var item []interface{}
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			t.Fatalf("%s: bad fixture: %v", tt.name, err)
+		}
+		if lat, lon := extractLocation(item); lat != tt.lat || lon != tt.lon {
+			t.Errorf("%s: extractLocation = %v, %v, want %v, %v", tt.name, lat, lon, tt.lat, tt.lon)
+		}
+	}
+}
+
+func TestParsePhotoItemsTimeZone(t *testing.T) {
+	// 2023-12-31T23:30:00Z, which is already New Year's Day in Tokyo
+	const takenAt = 1704065400000
+	tests := []struct {
+		name string
+		tz   string
+		want string // Empty when the item has no usable offset
+	}{
+		{"tokyo", "32400000", "2024-01-01T08:30:00+09:00"},
+		{"new york", "-18000000", "2023-12-31T18:30:00-05:00"},
+		{"kathmandu", "20700000", "2024-01-01T05:15:00+05:45"},
+		{"missing", "null", ""},
+		{"not an offset", "1234", ""},
+		{"too far", "54000000", ""},
+	}
+	for _, tt := range tests {
+		raw := fmt.Sprintf(`[["AF1Qip1",["https://lh3.googleusercontent.com/pw/a",4032,3024],%d,"key",%s]]`, takenAt, tt.tz)
+		var list []interface{}
+		if err := json.Unmarshal([]byte(raw), &list); err != nil {
+			t.Fatalf("%s: bad fixture: %v", tt.name, err)
+		}
+		photos := parsePhotoItems(list)
+		if len(photos) != 1 {
+			t.Fatalf("%s: parsed %d items, want 1", tt.name, len(photos))
+		}
+		got := photos[0].TakenAt
+		if got.UnixMilli() != takenAt {
+			t.Errorf("%s: TakenAt is %v, want the same instant as the page", tt.name, got)
+		}
+		if tt.want == "" {
+			// Without an offset the zone is the machine's own
+			if got.Location() != time.Local {
+				t.Errorf("%s: TakenAt in %v, want time.Local", tt.name, got.Location())
+			}
+			continue
+		}
+		if s := got.Format(time.RFC3339); s != tt.want {
+			t.Errorf("%s: TakenAt = %s, want %s", tt.name, s, tt.want)
+		}
+		if d := got.Format("2006-01-02"); d != tt.want[:10] {
+			t.Errorf("%s: date = %s, want %s", tt.name, d, tt.want[:10])
+		}
+	}
+}
+
+func TestExtractTimestamp(t *testing.T) {
+	// Items shaped like the ds:1 list, with upload and edit times next to the capture time
+	const (
+		taken    = 1600000000000 // 2020-09-13
+		older    = 1500000000000 // 2017-07-14, e.g. a file date in the metadata
+		uploaded = 1700000000000 // 2023-11-14
+	)
+	future := time.Now().Add(48 * time.Hour).UnixMilli()
+	tests := []struct {
+		name string
+		item string
+		want int64 // 0 for no date
+	}{
+		{"capture time in place", fmt.Sprintf(`["id",["url",4,3],%d,"key",3600000,[%d,%d],%d]`, taken, older, uploaded, uploaded), taken},
+		{"older epoch in a neighbour field", fmt.Sprintf(`["id",["url",4,3],%d,"key",%d]`, taken, older), taken},
+		{"capture time in seconds", fmt.Sprintf(`["id",["url",4,3],%d,"key",null,%d]`, taken/1000, older), taken},
+		{"capture time in microseconds", fmt.Sprintf(`["id",["url",4,3],%d]`, taken*1000), taken},
+		{"field missing, oldest elsewhere", fmt.Sprintf(`["id",["url",4,3],null,"key",null,[%d],%d]`, older, uploaded), older},
+		{"field in the future, oldest elsewhere", fmt.Sprintf(`["id",["url",4,3],%d,"key",null,%d]`, future, uploaded), uploaded},
+		{"field before 2000", fmt.Sprintf(`["id",["url",4,3],%d,"key",null,%d]`, int64(900000000000), uploaded), uploaded},
+		{"no dates", `["id",["url",4,3],null,"key",3600000,[1,2]]`, 0},
+		{"short item", `["id",["url",4,3]]`, 0},
+	}
+	for _, tt := range tests {
+		var item []interface{}
+		if err := json.Unmarshal([]byte(tt.item), &item); err != nil {
+			t.Fatalf("%s: bad fixture: %v", tt.name, err)
+		}
+		got := extractTimestamp(item)
+		if tt.want == 0 {
+			if !got.IsZero() {
+				t.Errorf("%s: got %v, want no date", tt.name, got)
+			}
+			continue
+		}
+		if got.UnixMilli() != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got.UTC(), time.UnixMilli(tt.want).UTC())
+		}
+	}
+}
+
+func TestExtractUploader(t *testing.T) {
+	const head = `"AF1Qip1",["https://lh3.googleusercontent.com/pw/a",4032,3024],1700000000000,"key",3600000`
+	const profile = `"https://lh3.googleusercontent.com/a/ACg8ocJ1=s64-c"`
+	tests := []struct {
+		name string
+		meta string
+		want string
+	}{
+		{"multi-contributor", `null,[null,["101","Jane Doe",` + profile + `]]`, "Jane Doe"},
+		{"name after the photo", `[[` + profile + `,"102","Ann Lee"]]`, "Ann Lee"},
+		{"legacy profile path", `[["Bob",["x"],"https://lh3.googleusercontent.com/a-/AOh14G=s64"]]`, "Bob"},
+		{"deepest searched level", `[[[[` + profile + `,"Deep"]]]]`, "Deep"},
+		{"too deep", `[[[[[` + profile + `,"Deeper"]]]]]`, ""},
+		{"numeric actor ID only", `[["101",` + profile + `]]`, ""},
+		{"single owner", `["IMG_0001.jpg",[1,2,3],"A caption"]`, ""},
+		{"name without a profile photo", `[["Jane Doe","https://lh3.googleusercontent.com/pw/b"]]`, ""},
+		{"no metadata", ``, ""},
+	}
+	for _, tt := range tests {
+		raw := "[" + head
+		if tt.meta != "" {
+			raw += "," + tt.meta
+		}
+		raw += "]"
+		var item []interface{}
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			t.Fatalf("%s: bad fixture: %v", tt.name, err)
+		}
+		if got := extractUploader(item); got != tt.want {
+			t.Errorf("%s: extractUploader = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParsePhotoItemsFileName(t *testing.T) {
+	const head = `"AF1Qip1",["https://lh3.googleusercontent.com/pw/a",4032,3024],1700000000000,"ChQxNzAwMDAwMDAwMDAwLTQwMzI",3600000`
+	tests := []struct {
+		name     string
+		rest     string
+		fileName string
+		caption  string
+	}{
+		{"metadata filename", `["IMG_1234.JPG"]`, "IMG_1234.JPG", ""},
+		{"nested filename and caption", `"Sunset",[null,[1,"PXL_2023.mp4"]]`, "PXL_2023.mp4", "Sunset"},
+		{"caption looking like a filename", `"beach.jpg"`, "", "beach.jpg"},
+		{"both", `"beach.jpg",[[null,"IMG_1.HEIC"]]`, "IMG_1.HEIC", "beach.jpg"},
+		{"too deep", `[[[["IMG_1.JPG"]]]]`, "", ""},
+		{"not media", `["notes.txt","path/to/IMG_1.JPG"]`, "", ""},
+	}
+	for _, tt := range tests {
+		raw := "[[" + head + "," + tt.rest + "]]"
+		var list []interface{}
+		if err := json.Unmarshal([]byte(raw), &list); err != nil {
+			t.Fatalf("%s: bad fixture: %v", tt.name, err)
+		}
+		photos := parsePhotoItems(list)
+		if len(photos) != 1 {
+			t.Fatalf("%s: parsed %d items, want 1", tt.name, len(photos))
+		}
+		if p := photos[0]; p.FileName != tt.fileName || p.Description != tt.caption {
+			t.Errorf("%s: FileName %q, Description %q, want %q, %q", tt.name, p.FileName, p.Description, tt.fileName, tt.caption)
+		}
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Summer · 2023", "Summer · 2023"},
+		{"Boston · Marathon 2023", "Boston · Marathon 2023"},
+		{"Mar · Sea trip", "Mar · Sea trip"},
+		{"Trip · Feb 6–7", "Trip"},
+		{"Trip · Dec 30, 2022 – Jan 2, 2023", "Trip"},
+		{"Trip · 6 Feb – 7 Mar", "Trip"},
+		{"Trip · 06/02/2023 – 07/02/2023", "Trip"},
+		{"Reise · 6.–7. Feb.", "Reise"},
+		{"Reise · 30. März – 2. Apr. 2023", "Reise"},
+		{"Voyage · 6–7 févr.", "Voyage"},
+		{"Viaje · 6–7 ene", "Viaje"},
+		{"Viagem · 6–7 de fev.", "Viagem"},
+		{"Gita · 6–7 gen 2023", "Gita"},
+		{"Reis · 6–7 mrt", "Reis"},
+		{"Wycieczka · 6–7 lut", "Wycieczka"},
+		{"Поездка · 6–7 февр.", "Поездка"},
+		{"旅行 · 2月6日～7日", "旅行"},
+		{"여행 · 2월 6일~7일", "여행"},
+		{"Paris · Louvre · Feb 6", "Paris · Louvre"},
+		{"Trip 📸 · Feb 6", "Trip"},
+		{"Trip 📸", "Trip"},
+	}
+	for _, tt := range tests {
+		if got := CleanTitle(tt.title); got != tt.want {
+			t.Errorf("CleanTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestExtractTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"double quotes", `<meta property="og:title" content="Trip · Feb 6–7">`, "Trip · Feb 6–7"},
+		{"single quotes", `<meta property='og:title' content='Trip'>`, "Trip"},
+		{"mixed quotes", `<meta property="og:title" content='Say "cheese"'>`, `Say "cheese"`},
+		{"content first", `<meta content="Trip" property="og:title">`, "Trip"},
+		{"extra attributes", `<meta data-x="1" property="og:title" itemprop="name" content="Trip" />`, "Trip"},
+		{"unquoted", `<meta property=og:title content=Trip>`, "Trip"},
+		{"name instead of property", `<meta name="og:title" content="Trip">`, "Trip"},
+		{"upper case", `<META PROPERTY="og:title" CONTENT="Trip">`, "Trip"},
+		{"spread over lines", "<meta\n  property=\"og:title\"\n  content=\"Trip\">", "Trip"},
+		{"escaped quotes", `<meta property="og:title" content="Tom &quot;Tommy&quot; &amp; Ann&#39;s">`, `Tom "Tommy" & Ann's`},
+		{"other meta tags first", `<meta property="og:description" content="Nope"><meta property="og:title" content="Trip">`, "Trip"},
+		{"empty og:title, title element", `<meta property="og:title" content=" "><title>Trip - Google Photos</title>`, "Trip"},
+		{"title element", `<title>Tom &amp; Ann - Google Photos</title>`, "Tom & Ann"},
+		{"neither", `<meta property="og:description" content="Nope">`, ""},
+	}
+	for _, tt := range tests {
+		page := []byte("<html><head>" + tt.head + "</head><body></body></html>")
+		if got := extractTitle(page); got != tt.want {
+			t.Errorf("%s: extractTitle = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScrapeAlbumPagination(t *testing.T) {
+	const (
+		first  = `["a",["https://lh3.googleusercontent.com/pw/a",400,300],1700000000000,"keyA",null]`
+		second = `["b",["https://lh3.googleusercontent.com/pw/b",400,300],1700000001000,"keyB",null]`
+	)
+	tests := []struct {
+		name           string
+		status         int // Answer to the continuation request
+		wantIDs        string
+		wantIncomplete bool
+	}{
+		{"all pages", http.StatusOK, "a,b", false},
+		{"continuation fails", http.StatusInternalServerError, "a", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/share/KEY", func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, `<html><head><meta property="og:title" content="Trip"></head><body>`+
+					`<script>AF_initDataCallback({key: 'ds:1', hash: '2', data:[null,[`+first+`],"CONTINUE-TOKEN-1"]});</script></body></html>`)
+			})
+			mux.HandleFunc("/_/PhotosUi/data/batchexecute", func(w http.ResponseWriter, r *http.Request) {
+				if tt.status != http.StatusOK {
+					w.WriteHeader(tt.status)
+					return
+				}
+				payload, _ := json.Marshal([]interface{}{nil, json.RawMessage("[" + second + "]"), nil})
+				envelope, _ := json.Marshal([][]interface{}{{"wrb.fr", "snAcKc", string(payload)}})
+				fmt.Fprintf(w, ")]}'\n\n%d\n%s\n", len(envelope), envelope)
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			// The continuation request goes to photos.google.com, send it to srv too
+			target, _ := url.Parse(srv.URL)
+			client := newTestClient(Options{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				r = r.Clone(r.Context())
+				r.URL.Scheme, r.URL.Host, r.Host = target.Scheme, target.Host, target.Host
+				return http.DefaultTransport.RoundTrip(r)
+			})})
+			album, err := ScrapeAlbum(client, srv.URL+"/share/KEY")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, p := range album.Photos {
+				ids = append(ids, p.ID)
+			}
+			if strings.Join(ids, ",") != tt.wantIDs || album.Incomplete != tt.wantIncomplete {
+				t.Errorf("scraped %v, incomplete %v, want %s, incomplete %v", ids, album.Incomplete, tt.wantIDs, tt.wantIncomplete)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package googlephotos
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// ErrMediaTypeMismatch is returned by DownloadMedia (with VerifyMediaType) when
+// Google keeps serving image bytes for a video or video bytes for an image
+var ErrMediaTypeMismatch = errors.New("downloaded media type doesn't match the item")
+
+// ErrHTMLResponse is returned when a media URL answers 200 with an HTML page
+// (error or consent page) instead of media bytes. Downloads retry it.
+var ErrHTMLResponse = errors.New("Google returned an HTML page instead of media")
+
+const (
+	mediaUnknown = "unknown"
+	mediaImage   = "image"
+	mediaVideo   = "video"
+)
+
+// sniffMediaKind classifies downloaded bytes by their magic numbers.
+// Formats it doesn't recognize are reported as mediaUnknown and never treated as a mismatch.
+func sniffMediaKind(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}),
+		bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")),
+		bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return mediaImage
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")):
+		switch string(data[8:12]) {
+		case "WEBP":
+			return mediaImage
+		case "AVI ":
+			return mediaVideo
+		}
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}): // EBML: WebM / Matroska
+		return mediaVideo
+	case len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")):
+		// ISO base media file: the major brand tells HEIF/AVIF stills from MP4/MOV videos
+		switch string(data[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1", "avif", "avis":
+			return mediaImage
+		default:
+			return mediaVideo
+		}
+	}
+	return mediaUnknown
+}
+
+// looksLikeHTML reports whether a media response is really a web page, by its
+// Content-Type or by the first bytes of the body
+func looksLikeHTML(contentType string, head []byte) bool {
+	ct := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if ct == "text/html" || ct == "application/xhtml+xml" {
+		return true
+	}
+	head = bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF")) // UTF-8 BOM
+	head = bytes.ToLower(bytes.TrimLeft(head, " \t\r\n"))
+	return bytes.HasPrefix(head, []byte("<!doctype")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+// videoExtension picks the extension for downloaded video bytes. A specific video
+// Content-Type is trusted; for generic ones (an unknown video/* subtype,
+// application/octet-stream) the container is sniffed so HEVC/MOV files aren't
+// uploaded as .mp4.
+func videoExtension(contentType string, data []byte) string {
+	ct := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	switch ct {
+	case "video/mp4", "video/webm", "video/quicktime", "video/x-matroska":
+		return extensionFromContentType(ct)
+	}
+	if ext := sniffVideoContainer(data); ext != "" {
+		return ext
+	}
+	return extensionFromContentType(contentType)
+}
+
+// sniffVideoContainer returns the extension for an MP4/MOV (ftyp box) or
+// WebM/MKV (EBML header) file, or "" when the bytes aren't recognized
+func sniffVideoContainer(data []byte) string {
+	switch {
+	case len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")):
+		if string(data[8:12]) == "qt  " {
+			return ".mov"
+		}
+		if sniffMediaKind(data) == mediaVideo {
+			return ".mp4"
+		}
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// The EBML DocType sits in the first few dozen bytes of the header
+		if bytes.Contains(data[:min(len(data), 64)], []byte("webm")) {
+			return ".webm"
+		}
+		return ".mkv"
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package googlephotos
+
+import "testing"
+
+// ftyp builds the start of an ISO base media file with the given major brand
+func ftyp(brand string) []byte {
+	return append([]byte{0, 0, 0, 0x20, 'f', 't', 'y', 'p'}, brand+"\x00\x00\x02\x00isomiso2"...)
+}
+
+var (
+	webmHeader = []byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01\x42\xF7\x81\x01\x42\xF2\x81\x04\x42\xF3\x81\x08\x42\x82\x84webm")
+	mkvHeader  = []byte("\x1A\x45\xDF\xA3\xA3\x42\x86\x81\x01\x42\xF7\x81\x01\x42\xF2\x81\x04\x42\xF3\x81\x08\x42\x82\x88matroska")
+)
+
+func TestSniffMediaKind(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"mp4", ftyp("isom"), mediaVideo},
+		{"mp4 v2", ftyp("mp42"), mediaVideo},
+		{"mov", ftyp("qt  "), mediaVideo},
+		{"webm", webmHeader, mediaVideo},
+		{"mkv", mkvHeader, mediaVideo},
+		{"avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), mediaVideo},
+		{"heic", ftyp("heic"), mediaImage},
+		{"avif", ftyp("avif"), mediaImage},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE1}, mediaImage},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00"), mediaImage},
+		{"gif", []byte("GIF89a"), mediaImage},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), mediaImage},
+		{"html", []byte("<!doctype html>"), mediaUnknown},
+		{"short ftyp", []byte{0, 0, 0, 0x20, 'f', 't', 'y', 'p'}, mediaUnknown},
+		{"empty", nil, mediaUnknown},
+	}
+	for _, tt := range tests {
+		if got := sniffMediaKind(tt.data); got != tt.want {
+			t.Errorf("%s: sniffMediaKind = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVideoExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        string
+	}{
+		{"mp4, generic type", "application/octet-stream", ftyp("isom"), ".mp4"},
+		{"mov, generic type", "application/octet-stream", ftyp("qt  "), ".mov"},
+		{"mov, unknown video type", "video/x-unknown", ftyp("qt  "), ".mov"},
+		{"webm, generic type", "application/octet-stream", webmHeader, ".webm"},
+		{"mkv, generic type", "video/x-unknown", mkvHeader, ".mkv"},
+		{"specific type wins", "video/quicktime", ftyp("isom"), ".mov"},
+		{"specific type with parameters", "video/webm; codecs=vp9", webmHeader, ".webm"},
+		{"unrecognized bytes", "video/x-unknown", []byte("garbage bytes"), ".mp4"},
+	}
+	for _, tt := range tests {
+		if got := videoExtension(tt.contentType, tt.data); got != tt.want {
+			t.Errorf("%s: videoExtension(%q) = %s, want %s", tt.name, tt.contentType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package googlephotos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// sniffLen is how much of a body is kept for content sniffing (http.DetectContentType reads 512 bytes)
+const sniffLen = 512
+
+// mediaBody is a fully downloaded response body, kept in memory or, above
+// Options.SpoolThreshold, in a temp file so large videos don't have to fit in RAM.
+// Either way the size is exact, which the Immich upload needs.
+type mediaBody struct {
+	data []byte   // Whole body when kept in memory
+	file *os.File // Spooled body, nil when in memory
+	size int64
+	head []byte // First bytes, for sniffing
+}
+
+// resumeFunc re-requests a body from offset on. It returns the rest of the body,
+// or ok=false (and no body) when the server ignored the Range and would send it all.
+type resumeFunc func(offset int64) (rest io.ReadCloser, ok bool, err error)
+
+// readBody downloads r completely. Bodies larger than the spool threshold go
+// to a temp file; on error nothing is left behind. When copying into the temp
+// file fails midway, resume (if not nil) continues from the bytes already written.
+func (c *Client) readBody(r io.Reader, resume resumeFunc) (*mediaBody, error) {
+	threshold := c.opts.SpoolThreshold
+	if threshold <= 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return &mediaBody{data: data, size: int64(len(data)), head: data[:min(len(data), sniffLen)]}, nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r, threshold+1))
+	if err != nil {
+		return nil, err
+	}
+	head := buf[:min(len(buf), sniffLen)]
+	if int64(len(buf)) <= threshold {
+		return &mediaBody{data: buf, size: int64(len(buf)), head: head}, nil
+	}
+
+	f, err := os.CreateTemp(c.opts.SpoolDir, "immich-sync-*.part")
+	if err != nil {
+		return nil, fmt.Errorf("error creating spool file: %w", err)
+	}
+	fail := func(err error) (*mediaBody, error) {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Write(buf); err != nil {
+		return fail(fmt.Errorf("error writing spool file: %w", err))
+	}
+	if err := c.copyResuming(f, r, int64(len(buf)), resume); err != nil {
+		return fail(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return fail(fmt.Errorf("error writing spool file: %w", err))
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fail(fmt.Errorf("error writing spool file: %w", err))
+	}
+	return &mediaBody{file: f, size: info.Size(), head: append([]byte(nil), head...)}, nil
+}
+
+// copyResuming copies r to f, which already holds written bytes. On a read error
+// it resumes up to DownloadResumes times; if the server ignores the Range request
+// the error is returned, and retryDownload starts over from scratch.
+func (c *Client) copyResuming(f *os.File, r io.Reader, written int64, resume resumeFunc) error {
+	limit := c.opts.DownloadResumes
+	if limit == 0 {
+		limit = defaultDownloadResumes
+	}
+	n, err := io.Copy(f, r)
+	written += n
+	for i := 0; err != nil && resume != nil && i < limit; i++ {
+		c.logger.Warn("Download interrupted, resuming", "offset", written, "attempt", i+1, "error", err)
+		rest, ok, resumeErr := resume(written)
+		if resumeErr != nil {
+			return fmt.Errorf("%w (resuming at byte %d failed: %v)", err, written, resumeErr)
+		}
+		if !ok {
+			c.logger.Warn("Server doesn't support resuming, downloading again", "offset", written)
+			return err
+		}
+		n, err = io.Copy(f, rest)
+		rest.Close()
+		written += n
+	}
+	return err
+}
+
+// Reader hands the body over to the caller, who must close it (spool files are deleted on Close)
+func (b *mediaBody) Reader() io.ReadCloser {
+	if b.file != nil {
+		return &spoolFile{b.file}
+	}
+	return memBody{bytes.NewReader(b.data)}
+}
+
+// Discard releases a body that won't be used
+func (b *mediaBody) Discard() {
+	if b.file != nil {
+		b.file.Close()
+		os.Remove(b.file.Name())
+	}
+}
+
+// spoolFile is a temp file that removes itself when closed
+type spoolFile struct {
+	*os.File
+}
+
+func (f *spoolFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// memBody is an in-memory body. Like spoolFile it can be seeked, so callers can read it twice.
+type memBody struct {
+	*bytes.Reader
+}
+
+func (memBody) Close() error { return nil }
@@ -0,0 +1,163 @@
+package googlephotos
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spooled lists the files left in a spool directory
+func spooled(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// failingReader returns data, then err
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestSpoolFileRemovedAfterClose(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	tests := []struct {
+		name      string
+		threshold int64
+		spool     bool // Whether the body goes to a temp file
+	}{
+		{"spooling off", 0, false},
+		{"below threshold", int64(len(data)), false},
+		{"above threshold", int64(len(data)) - 1, true},
+		{"tiny threshold", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			client := newTestClient(Options{SpoolThreshold: tt.threshold, SpoolDir: dir})
+			body, err := client.readBody(bytes.NewReader(data), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if files := spooled(t, dir); (len(files) == 1) != tt.spool || len(files) > 1 {
+				t.Fatalf("spool dir holds %v, want a temp file: %v", files, tt.spool)
+			}
+			if body.size != int64(len(data)) {
+				t.Errorf("size %d, want %d", body.size, len(data))
+			}
+
+			r := body.Reader()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("read back %d bytes that differ from the %d written", len(got), len(data))
+			}
+			if err := r.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			if files := spooled(t, dir); len(files) != 0 {
+				t.Errorf("spool dir holds %v after Close, want it empty", files)
+			}
+		})
+	}
+}
+
+func TestSpoolFileRemovedOnFailure(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100)
+	readErr := errors.New("connection reset")
+
+	t.Run("read error", func(t *testing.T) {
+		dir := t.TempDir()
+		client := newTestClient(Options{SpoolThreshold: 10, SpoolDir: dir})
+		if _, err := client.readBody(&failingReader{data: data, err: readErr}, nil); !errors.Is(err, readErr) {
+			t.Fatalf("error %v, want %v", err, readErr)
+		}
+		if files := spooled(t, dir); len(files) != 0 {
+			t.Errorf("spool dir holds %v after a failed download, want it empty", files)
+		}
+	})
+
+	t.Run("discarded", func(t *testing.T) {
+		dir := t.TempDir()
+		client := newTestClient(Options{SpoolThreshold: 10, SpoolDir: dir})
+		body, err := client.readBody(bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body.Discard()
+		if files := spooled(t, dir); len(files) != 0 {
+			t.Errorf("spool dir holds %v after Discard, want it empty", files)
+		}
+	})
+}
+
+func TestDownloadResumes(t *testing.T) {
+	const full, resumed = "GET /vid=dv", "GET /vid=dv range"
+	tests := []struct {
+		name        string
+		opts        Options
+		ignoreRange bool
+		want        []string // GETs sent, in order
+	}{
+		{"resumed", Options{SpoolThreshold: 16}, false, []string{full, resumed}},
+		{"range ignored", Options{SpoolThreshold: 16}, true, []string{full, resumed, full}},
+		{"resuming disabled", Options{SpoolThreshold: 16, DownloadResumes: -1}, false, []string{full, full}},
+		{"not spooled", Options{}, false, []string{full, full}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newMediaServer(t, true)
+			srv.mu.Lock()
+			srv.ignoreRange = tt.ignoreRange
+			srv.mu.Unlock()
+			dir := t.TempDir()
+			opts := tt.opts
+			opts.SpoolDir, opts.DownloadRetries, opts.DownloadBackoff = dir, 1, time.Millisecond
+			client := newTestClient(opts)
+
+			r, size, _, _, err := DownloadMedia(client, srv.URL+"/vid", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, testMP4) || size != int64(len(testMP4)) {
+				t.Errorf("downloaded %d bytes (size %d), want the intact %d", len(got), size, len(testMP4))
+			}
+
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			if strings.Join(srv.gets, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("requests %v, want %v", srv.gets, tt.want)
+			}
+			if files := spooled(t, dir); len(files) != 0 {
+				t.Errorf("spool dir holds %v, want it empty", files)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package immich
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -12,26 +13,63 @@ import (
 )
 
 type Album struct {
-	AlbumName string `json:"albumName"`
-	Id        string `json:"id"`
-	OwnerId   string `json:"ownerId"`
-	Assets    []struct {
+	AlbumName             string      `json:"albumName"`
+	Id                    string      `json:"id"`
+	OwnerId               string      `json:"ownerId"`
+	Description           string      `json:"description"`
+	AlbumThumbnailAssetId string      `json:"albumThumbnailAssetId"` // Cover asset, empty when the album has none
+	AlbumUsers            []AlbumUser `json:"albumUsers"`            // Users the album is shared with, the owner excluded
+	AssetCount            int         `json:"assetCount"`            // Also set in album lists, which leave Assets empty
+	Assets                []struct {
 		Id               string `json:"id"`
 		OriginalFileName string `json:"originalFileName"`
 		OriginalMimeType string `json:"originalMimeType"`
+		DeviceAssetId    string `json:"deviceAssetId"`
+		ExifInfo         struct {
+			Description     string `json:"description"`
+			ExifImageWidth  int    `json:"exifImageWidth"`
+			ExifImageHeight int    `json:"exifImageHeight"`
+		} `json:"exifInfo"`
 	} `json:"assets"`
 }
 
+// AlbumUser is a user an album is shared with
+type AlbumUser struct {
+	User User   `json:"user"`
+	Role string `json:"role"` // "editor" or "viewer"
+}
+
+// User is the subset of Immich user fields used by the sync tool
+type User struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Asset is the subset of Immich asset fields used by the sync tool
+type Asset struct {
+	Id               string `json:"id"`
+	OriginalFileName string `json:"originalFileName"`
+	Checksum         string `json:"checksum"`
+	DeviceId         string `json:"deviceId"`
+	Type             string `json:"type"`             // "IMAGE" or "VIDEO"
+	LivePhotoVideoId string `json:"livePhotoVideoId"` // Motion part of a live photo, empty if none
+}
+
 type Client struct {
 	APIURL string
 	APIKey string
 	Client *http.Client
+
+	AlbumAddBatchSize int // Asset IDs per album add request, 0 means 250
 }
 
+const defaultAlbumAddBatchSize = 250
+
+// NewClient creates an Immich API client. apiURL may include a subpath
+// (e.g. "https://example.com/immich/api"); endpoints are joined onto it.
 func NewClient(apiURL, apiKey string) *Client {
-	if strings.HasSuffix(apiURL, "/") {
-		apiURL = apiURL[:len(apiURL)-1]
-	}
+	apiURL = strings.TrimRight(apiURL, "/")
 	return &Client{
 		APIURL: apiURL,
 		APIKey: apiKey,
@@ -46,6 +84,20 @@ func NewClient(apiURL, apiKey string) *Client {
 	}
 }
 
+// JoinURL joins URL parts with exactly one slash between them, regardless of
+// leading or trailing slashes on either side. Empty parts are skipped.
+func JoinURL(base string, parts ...string) string {
+	joined := strings.TrimRight(base, "/")
+	for _, p := range parts {
+		p = strings.Trim(p, "/")
+		if p == "" {
+			continue
+		}
+		joined += "/" + p
+	}
+	return joined
+}
+
 // request is a convenience wrapper for JSON API calls
 func (c *Client) request(method string, path string, payload []byte, contentType string) ([]byte, error) {
 	var bodyReader io.Reader
@@ -76,8 +128,12 @@ func (c *Client) GetAlbum(albumId string) (*Album, error) {
 	return &album, err
 }
 
-func (c *Client) CreateAlbum(name string) (*Album, error) {
+// CreateAlbum creates an album, the description may be empty
+func (c *Client) CreateAlbum(name, description string) (*Album, error) {
 	payload := map[string]string{"albumName": name}
+	if description != "" {
+		payload["description"] = description
+	}
 	jsonPayload, _ := json.Marshal(payload)
 	body, err := c.request("POST", "albums", jsonPayload, "")
 	if err != nil {
@@ -94,28 +150,98 @@ func (c *Client) CreateAlbum(name string) (*Album, error) {
 	return &album, err
 }
 
-func (c *Client) AddAssetsToAlbum(albumId string, assetIds []string) error {
-	const batchSize = 100 // process in chunks
+// AlbumUpdate holds album fields to change; empty fields are left untouched
+type AlbumUpdate struct {
+	AlbumName             string `json:"albumName,omitempty"`
+	Description           string `json:"description,omitempty"`
+	AlbumThumbnailAssetId string `json:"albumThumbnailAssetId,omitempty"`
+}
+
+// UpdateAlbum patches album metadata such as the cover asset
+func (c *Client) UpdateAlbum(albumId string, update AlbumUpdate) error {
+	jsonPayload, _ := json.Marshal(update)
+	_, err := c.request("PATCH", fmt.Sprintf("albums/%s", albumId), jsonPayload, "")
+	return err
+}
+
+// AlbumAddResult summarizes Immich's per-asset response to adding assets to an album
+type AlbumAddResult struct {
+	Added      int
+	Duplicates int               // Already in the album
+	Failed     map[string]string // asset ID -> reason reported by Immich (e.g. "not_found", "no_permission")
+
+	// Assets of batches whose request failed, not associated with the album; worth retrying
+	Unsent       []string
+	BatchErrors  []error // One per failed batch, naming the batch
+	BatchesTotal int
+}
+
+// AddAssetsToAlbum adds assets in batches of AlbumAddBatchSize and tallies Immich's
+// per-asset results. A failed batch doesn't stop the others: its assets end up in
+// Unsent. The error is only set when every batch failed.
+func (c *Client) AddAssetsToAlbum(albumId string, assetIds []string) (*AlbumAddResult, error) {
+	result := &AlbumAddResult{Failed: make(map[string]string)}
+	batchSize := c.AlbumAddBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultAlbumAddBatchSize
+	}
 	for i := 0; i < len(assetIds); i += batchSize {
 		end := i + batchSize
 		if end > len(assetIds) {
 			end = len(assetIds)
 		}
-		
+
 		chunk := assetIds[i:end]
+		result.BatchesTotal++
 		payload := map[string]interface{}{"ids": chunk}
 		jsonPayload, _ := json.Marshal(payload)
-		_, err := c.request("PUT", fmt.Sprintf("albums/%s/assets", albumId), jsonPayload, "")
+		body, err := c.request("PUT", fmt.Sprintf("albums/%s/assets", albumId), jsonPayload, "")
 		if err != nil {
-			return err
+			result.Unsent = append(result.Unsent, chunk...)
+			result.BatchErrors = append(result.BatchErrors, fmt.Errorf("batch %d (assets %d-%d): %w", result.BatchesTotal, i+1, end, err))
+			continue
+		}
+
+		var items []struct {
+			Id      string `json:"id"`
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &items); err != nil {
+			// Unknown response shape, assume the whole chunk went in
+			result.Added += len(chunk)
+			continue
+		}
+		for _, it := range items {
+			switch {
+			case it.Success:
+				result.Added++
+			case it.Error == "duplicate":
+				result.Duplicates++
+			default:
+				result.Failed[it.Id] = it.Error
+			}
 		}
 	}
-	return nil
+	if len(result.BatchErrors) > 0 && len(result.BatchErrors) == result.BatchesTotal {
+		return result, errors.Join(result.BatchErrors...)
+	}
+	return result, nil
 }
 
+// APIError is returned for requests Immich answered with an error status
+type APIError struct {
+	StatusCode int
+	Status     string // e.g. "400 Bad Request"
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: %s - %s", e.Status, e.Body)
+}
 
 func (c *Client) requestWithReader(method string, path string, bodyReader io.Reader, contentType string) ([]byte, error) {
-	url := fmt.Sprintf("%s/%s", c.APIURL, path)
+	url := JoinURL(c.APIURL, path)
 
 	req, err := http.NewRequest(method, url, bodyReader)
 	if err != nil {
@@ -142,13 +268,19 @@ func (c *Client) requestWithReader(method string, path string, bodyReader io.Rea
 	}
 
 	if res.StatusCode >= 400 {
-		return body, fmt.Errorf("API error: %s - %s", res.Status, string(body))
+		return body, &APIError{StatusCode: res.StatusCode, Status: res.Status, Body: string(body)}
 	}
 
 	return body, nil
 }
 
-func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64, createdAt time.Time, description string) (string, bool, error) {
+// UploadAssetStream uploads an asset. deviceAssetId is stored on the asset and
+// can be searched later; when empty it defaults to "<filename>-<size>".
+func (c *Client) UploadAssetStream(reader io.Reader, filename string, deviceAssetId string, size int64, createdAt time.Time, description string, libraryId string) (string, bool, error) {
+	if deviceAssetId == "" {
+		deviceAssetId = fmt.Sprintf("%s-%d", filename, size)
+	}
+
 	pr, pw := io.Pipe()
 	multipartWriter := multipart.NewWriter(pw)
 
@@ -157,7 +289,7 @@ func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64
 		defer multipartWriter.Close()
 		
 		// Metadata fields
-		_ = multipartWriter.WriteField("deviceAssetId", fmt.Sprintf("%s-%d", filename, size))
+		_ = multipartWriter.WriteField("deviceAssetId", deviceAssetId)
 		_ = multipartWriter.WriteField("deviceId", "immich-sync-go")
 		
 		creationTime := time.Now()
@@ -165,12 +297,16 @@ func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64
 			creationTime = createdAt
 		}
 		
+		// RFC3339 keeps createdAt's UTC offset, so the capture date doesn't shift
 		_ = multipartWriter.WriteField("fileCreatedAt", creationTime.Format(time.RFC3339))
 		_ = multipartWriter.WriteField("fileModifiedAt", creationTime.Format(time.RFC3339))
 		_ = multipartWriter.WriteField("isFavorite", "false")
 		if description != "" {
 			_ = multipartWriter.WriteField("description", description)
 		}
+		if libraryId != "" {
+			_ = multipartWriter.WriteField("libraryId", libraryId)
+		}
 
 		part, err := multipartWriter.CreateFormFile("assetData", filename)
 		if err != nil {
@@ -206,6 +342,50 @@ func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64
 	return "", false, fmt.Errorf("upload successful but no ID returned (response: %s)", string(resp))
 }
 
+// Library is the subset of Immich library fields used by the sync tool
+type Library struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"` // "UPLOAD" or "EXTERNAL", older servers only
+}
+
+// GetLibraries lists the libraries visible to the API key (admin keys see all of them)
+func (c *Client) GetLibraries() ([]Library, error) {
+	body, err := c.request("GET", "libraries", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var libraries []Library
+	err = json.Unmarshal(body, &libraries)
+	return libraries, err
+}
+
+// GetUsers lists the users of the Immich server
+func (c *Client) GetUsers() ([]User, error) {
+	body, err := c.request("GET", "users", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	err = json.Unmarshal(body, &users)
+	return users, err
+}
+
+// AddAlbumUsers shares an album with the given user IDs, role is "editor" or "viewer"
+func (c *Client) AddAlbumUsers(albumId string, userIds []string, role string) error {
+	type albumUser struct {
+		UserId string `json:"userId"`
+		Role   string `json:"role"`
+	}
+	users := make([]albumUser, len(userIds))
+	for i, id := range userIds {
+		users[i] = albumUser{UserId: id, Role: role}
+	}
+	jsonPayload, _ := json.Marshal(map[string]interface{}{"albumUsers": users})
+	_, err := c.request("PUT", fmt.Sprintf("albums/%s/users", albumId), jsonPayload, "")
+	return err
+}
+
 func (c *Client) GetUser() (string, string, error) {
 	body, err := c.request("GET", "users/me", nil, "")
 	if err != nil {
@@ -221,6 +401,7 @@ func (c *Client) GetUser() (string, string, error) {
 
 // SearchAssetsByDevice fetches all assets uploaded by the given deviceId using paginated metadata search.
 // Returns a map of originalFileName (without extension) -> asset ID for O(1) lookups.
+// Each asset's deviceAssetId is indexed in the same map.
 func (c *Client) SearchAssetsByDevice(deviceId string) (map[string]string, error) {
 	result := make(map[string]string)
 	page := 1
@@ -244,6 +425,7 @@ func (c *Client) SearchAssetsByDevice(deviceId string) (map[string]string, error
 				Items []struct {
 					Id               string `json:"id"`
 					OriginalFileName string `json:"originalFileName"`
+					DeviceAssetId    string `json:"deviceAssetId"`
 				} `json:"items"`
 				NextPage interface{} `json:"nextPage"`
 			} `json:"assets"`
@@ -258,6 +440,9 @@ func (c *Client) SearchAssetsByDevice(deviceId string) (map[string]string, error
 				name = name[:dot]
 			}
 			result[name] = asset.Id
+			if asset.DeviceAssetId != "" {
+				result[asset.DeviceAssetId] = asset.Id
+			}
 		}
 
 		// Stop if no more pages
@@ -268,4 +453,198 @@ func (c *Client) SearchAssetsByDevice(deviceId string) (map[string]string, error
 	}
 
 	return result, nil
-}
\ No newline at end of file
+}
+
+// GetAsset fetches a single asset by ID
+func (c *Client) GetAsset(assetId string) (*Asset, error) {
+	body, err := c.request("GET", fmt.Sprintf("assets/%s", assetId), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var asset Asset
+	err = json.Unmarshal(body, &asset)
+	return &asset, err
+}
+
+// DeleteAssets deletes assets by ID. With force they bypass the trash.
+func (c *Client) DeleteAssets(assetIds []string, force bool) error {
+	payload := map[string]interface{}{"ids": assetIds, "force": force}
+	jsonPayload, _ := json.Marshal(payload)
+	_, err := c.request("DELETE", "assets", jsonPayload, "")
+	return err
+}
+
+// SetAssetsFavorite marks or unmarks assets as favorites
+func (c *Client) SetAssetsFavorite(assetIds []string, favorite bool) error {
+	return c.updateAssets(assetIds, map[string]interface{}{"isFavorite": favorite})
+}
+
+// SetAssetsArchived moves assets to the archive. Servers without asset
+// visibility (before v1.133) reject the field with a 400 and get the legacy
+// isArchived flag instead.
+func (c *Client) SetAssetsArchived(assetIds []string) error {
+	err := c.SetAssetsVisibility(assetIds, "archive")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return err
+	}
+	return c.updateAssets(assetIds, map[string]interface{}{"isArchived": true})
+}
+
+// updateAssets applies the same field changes to assets in batches
+func (c *Client) updateAssets(assetIds []string, fields map[string]interface{}) error {
+	const batchSize = 100
+	for i := 0; i < len(assetIds); i += batchSize {
+		end := min(i+batchSize, len(assetIds))
+		payload := map[string]interface{}{"ids": assetIds[i:end]}
+		for k, v := range fields {
+			payload[k] = v
+		}
+		jsonPayload, _ := json.Marshal(payload)
+		if _, err := c.request("PUT", "assets", jsonPayload, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAssetsVisibility changes where assets are shown ("timeline", "archive", "locked").
+// Requires a server with asset visibility support (v1.133+); older versions reject the field.
+func (c *Client) SetAssetsVisibility(assetIds []string, visibility string) error {
+	return c.updateAssets(assetIds, map[string]interface{}{"visibility": visibility})
+}
+
+// LinkLivePhoto attaches a video asset to a still image as its live photo motion part
+func (c *Client) LinkLivePhoto(imageId, videoId string) error {
+	payload := map[string]interface{}{"livePhotoVideoId": videoId}
+	jsonPayload, _ := json.Marshal(payload)
+	_, err := c.request("PUT", fmt.Sprintf("assets/%s", imageId), jsonPayload, "")
+	return err
+}
+
+// Person is the subset of Immich person fields used by the sync tool
+type Person struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// GetPerson fetches a person by ID; fails if the person doesn't exist
+func (c *Client) GetPerson(personId string) (*Person, error) {
+	body, err := c.request("GET", fmt.Sprintf("people/%s", personId), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var person Person
+	err = json.Unmarshal(body, &person)
+	return &person, err
+}
+
+// TagPerson attaches a person to an asset by adding a manual face covering the
+// whole image (imageWidth x imageHeight). Requires Immich v1.125+.
+func (c *Client) TagPerson(assetId, personId string, imageWidth, imageHeight int) error {
+	payload := map[string]interface{}{
+		"assetId":     assetId,
+		"personId":    personId,
+		"imageWidth":  imageWidth,
+		"imageHeight": imageHeight,
+		"x":           0,
+		"y":           0,
+		"width":       imageWidth,
+		"height":      imageHeight,
+	}
+	jsonPayload, _ := json.Marshal(payload)
+	_, err := c.request("POST", "faces", jsonPayload, "")
+	return err
+}
+
+// SetAssetLocation sets an asset's GPS coordinates (decimal degrees)
+func (c *Client) SetAssetLocation(assetId string, latitude, longitude float64) error {
+	payload := map[string]interface{}{"latitude": latitude, "longitude": longitude}
+	jsonPayload, _ := json.Marshal(payload)
+	_, err := c.request("PUT", fmt.Sprintf("assets/%s", assetId), jsonPayload, "")
+	return err
+}
+
+// CreateStack groups assets into a stack with the first ID as the primary asset.
+// Requires Immich v1.128+. Returns the stack ID.
+func (c *Client) CreateStack(assetIds []string) (string, error) {
+	payload := map[string]interface{}{"assetIds": assetIds}
+	jsonPayload, _ := json.Marshal(payload)
+	body, err := c.request("POST", "stacks", jsonPayload, "")
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		Id string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+	return res.Id, nil
+}
+
+// Tag is the subset of Immich tag fields used by the sync tool
+type Tag struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"` // Full path, e.g. "Parent/Child"
+}
+
+// UpsertTags creates the named tags if they don't exist yet and returns all of
+// them. Names may be hierarchical ("Parent/Child"). Requires Immich v1.118+.
+func (c *Client) UpsertTags(names []string) ([]Tag, error) {
+	payload := map[string]interface{}{"tags": names}
+	jsonPayload, _ := json.Marshal(payload)
+	body, err := c.request("PUT", "tags", jsonPayload, "")
+	if err != nil {
+		return nil, err
+	}
+	var tags []Tag
+	err = json.Unmarshal(body, &tags)
+	return tags, err
+}
+
+// TagAssets attaches every tag to every asset
+func (c *Client) TagAssets(tagIds, assetIds []string) error {
+	const batchSize = 500
+	for i := 0; i < len(assetIds); i += batchSize {
+		end := min(i+batchSize, len(assetIds))
+		payload := map[string]interface{}{"tagIds": tagIds, "assetIds": assetIds[i:end]}
+		jsonPayload, _ := json.Marshal(payload)
+		if _, err := c.request("PUT", "tags/assets", jsonPayload, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadCheck is one file of a bulk upload check
+type UploadCheck struct {
+	Id       string `json:"id"`       // Caller's reference, echoed in the result
+	Checksum string `json:"checksum"` // SHA-1 of the file, base64 or hex
+}
+
+// UploadCheckResult tells whether Immich would accept a file or already has it
+type UploadCheckResult struct {
+	Id        string `json:"id"`
+	Action    string `json:"action"`  // "accept" or "reject"
+	Reason    string `json:"reason"`  // "duplicate" when rejected as already present
+	AssetId   string `json:"assetId"` // Existing asset when rejected as duplicate
+	IsTrashed bool   `json:"isTrashed"`
+}
+
+// BulkUploadCheck asks Immich which files it already has, by checksum,
+// so they don't need to be uploaded
+func (c *Client) BulkUploadCheck(assets []UploadCheck) ([]UploadCheckResult, error) {
+	payload := map[string]interface{}{"assets": assets}
+	jsonPayload, _ := json.Marshal(payload)
+	body, err := c.request("POST", "assets/bulk-upload-check", jsonPayload, "")
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Results []UploadCheckResult `json:"results"`
+	}
+	err = json.Unmarshal(body, &res)
+	return res.Results, err
+}
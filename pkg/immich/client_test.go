@@ -0,0 +1,300 @@
+package immich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder is an Immich stub that records every request body and answers with
+// the status its respond function picks, 200 when it is nil
+type recorder struct {
+	*httptest.Server
+	respond func(path string, body map[string]interface{}) int
+
+	mu    sync.Mutex
+	calls []recordedCall
+}
+
+type recordedCall struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+func newRecorder(t *testing.T, respond func(path string, body map[string]interface{}) int) *recorder {
+	t.Helper()
+	rec := &recorder{respond: respond}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(raw, &body)
+		rec.mu.Lock()
+		rec.calls = append(rec.calls, recordedCall{Method: r.Method, Path: r.URL.Path, Body: body})
+		rec.mu.Unlock()
+
+		status := http.StatusOK
+		if rec.respond != nil {
+			status = rec.respond(r.URL.Path, body)
+		}
+		if status != http.StatusOK {
+			http.Error(w, `{"message":"rejected"}`, status)
+			return
+		}
+		io.WriteString(w, `{}`)
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func ids(n int) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("asset%03d", i)
+	}
+	return list
+}
+
+// idsOf converts a decoded JSON ids array back to strings
+func idsOf(v interface{}) []string {
+	var list []string
+	for _, id := range v.([]interface{}) {
+		list = append(list, id.(string))
+	}
+	return list
+}
+
+func TestBulkAssetUpdatePayloads(t *testing.T) {
+	tests := []struct {
+		name  string
+		call  func(c *Client, assetIds []string) error
+		field string
+		value interface{}
+	}{
+		{"favorite", func(c *Client, ids []string) error { return c.SetAssetsFavorite(ids, true) }, "isFavorite", true},
+		{"unfavorite", func(c *Client, ids []string) error { return c.SetAssetsFavorite(ids, false) }, "isFavorite", false},
+		{"archive", func(c *Client, ids []string) error { return c.SetAssetsArchived(ids) }, "visibility", "archive"},
+		{"locked", func(c *Client, ids []string) error { return c.SetAssetsVisibility(ids, "locked") }, "visibility", "locked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newRecorder(t, nil)
+			all := ids(150)
+			if err := tt.call(NewClient(rec.URL+"/api", "key"), all); err != nil {
+				t.Fatal(err)
+			}
+			if len(rec.calls) != 2 {
+				t.Fatalf("%d requests for 150 assets, want 2 batches", len(rec.calls))
+			}
+			var sent []string
+			for _, c := range rec.calls {
+				if c.Method != "PUT" || c.Path != "/api/assets" {
+					t.Errorf("request %s %s, want PUT /api/assets", c.Method, c.Path)
+				}
+				if c.Body[tt.field] != tt.value || len(c.Body) != 2 {
+					t.Errorf("payload %v, want ids and %s=%v only", c.Body, tt.field, tt.value)
+				}
+				sent = append(sent, idsOf(c.Body["ids"])...)
+			}
+			if !reflect.DeepEqual(sent, all) {
+				t.Errorf("sent %d ids, want all 150 in order", len(sent))
+			}
+		})
+	}
+}
+
+func TestSetAssetsArchivedFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int // Answer to the visibility update
+		fallback bool
+		wantErr  bool
+	}{
+		{"supported", http.StatusOK, false, false},
+		{"old server", http.StatusBadRequest, true, false},
+		{"server error", http.StatusInternalServerError, false, true},
+		{"unauthorized", http.StatusUnauthorized, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newRecorder(t, func(path string, body map[string]interface{}) int {
+				if _, ok := body["visibility"]; ok {
+					return tt.status
+				}
+				return http.StatusOK
+			})
+			err := NewClient(rec.URL, "key").SetAssetsArchived([]string{"a1", "a2"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error: %v", err, tt.wantErr)
+			}
+			var apiErr *APIError
+			if err != nil && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status) {
+				t.Errorf("error %v, want an APIError with status %d", err, tt.status)
+			}
+
+			var legacy []recordedCall
+			for _, c := range rec.calls {
+				if _, ok := c.Body["isArchived"]; ok {
+					legacy = append(legacy, c)
+				}
+			}
+			if (len(legacy) > 0) != tt.fallback {
+				t.Fatalf("sent isArchived: %v, want %v", len(legacy) > 0, tt.fallback)
+			}
+			if tt.fallback && (legacy[0].Body["isArchived"] != true || !reflect.DeepEqual(idsOf(legacy[0].Body["ids"]), []string{"a1", "a2"})) {
+				t.Errorf("legacy payload %v, want ids [a1 a2] with isArchived true", legacy[0].Body)
+			}
+		})
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	rec := newRecorder(t, func(string, map[string]interface{}) int { return http.StatusNotFound })
+	_, err := NewClient(rec.URL, "key").GetAsset("missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error %v, want an APIError", err)
+	}
+	want := "API error: 404 Not Found - {\"message\":\"rejected\"}\n"
+	if err.Error() != want || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("error %q (status %d), want %q", err.Error(), apiErr.StatusCode, want)
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	tests := []struct {
+		base  string
+		parts []string
+		want  string
+	}{
+		{"https://immich.example.com/api", []string{"albums"}, "https://immich.example.com/api/albums"},
+		{"https://example.com/immich/api/", []string{"/albums/"}, "https://example.com/immich/api/albums"},
+		{"https://example.com/immich/api//", []string{"assets", "abc/"}, "https://example.com/immich/api/assets/abc"},
+		{"https://example.com/immich/api", []string{"", "/", "search/metadata"}, "https://example.com/immich/api/search/metadata"},
+		{"https://example.com/immich/api", nil, "https://example.com/immich/api"},
+	}
+	for _, tt := range tests {
+		if got := JoinURL(tt.base, tt.parts...); got != tt.want {
+			t.Errorf("JoinURL(%q, %q) = %q, want %q", tt.base, tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestClientUnderSubpath(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		io.WriteString(w, `{"id":"album1","albumName":"Trip"}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/immich/api/", "key")
+	if _, err := c.CreateAlbum("Trip", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetAlbum("album1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/immich/api/albums", "/immich/api/albums/album1"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+}
+
+func TestAddAssetsToAlbumBatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		assets      int
+		batchSize   int
+		failBatches map[int]bool // 1-based batches answered with 500
+		wantSizes   []int
+		wantAdded   int
+		wantUnsent  []string
+		wantErrs    []string // Batch error prefixes
+		wantErr     bool
+	}{
+		{"empty", 0, 0, nil, nil, 0, nil, nil, false},
+		{"exactly the default batch", 250, 0, nil, []int{250}, 250, nil, nil, false},
+		{"one past the default batch", 251, 0, nil, []int{250, 1}, 251, nil, nil, false},
+		{"even batches", 300, 100, nil, []int{100, 100, 100}, 300, nil, nil, false},
+		{"one past even batches", 301, 100, nil, []int{100, 100, 100, 1}, 301, nil, nil, false},
+		{"middle batch fails", 250, 100, map[int]bool{2: true}, []int{100, 100, 50}, 150, ids(250)[100:200],
+			[]string{"batch 2 (assets 101-200)"}, false},
+		{"last batch fails", 250, 100, map[int]bool{3: true}, []int{100, 100, 50}, 200, ids(250)[200:],
+			[]string{"batch 3 (assets 201-250)"}, false},
+		{"every batch fails", 150, 100, map[int]bool{1: true, 2: true}, []int{100, 50}, 0, ids(150),
+			[]string{"batch 1 (assets 1-100)", "batch 2 (assets 101-150)"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct{ Ids []string }
+				json.NewDecoder(r.Body).Decode(&req)
+				sizes = append(sizes, len(req.Ids))
+				if r.Method != "PUT" || r.URL.Path != "/albums/album1/assets" {
+					t.Errorf("request %s %s, want PUT /albums/album1/assets", r.Method, r.URL.Path)
+				}
+				if tt.failBatches[len(sizes)] {
+					http.Error(w, `{"message":"timeout"}`, http.StatusInternalServerError)
+					return
+				}
+				var res []map[string]interface{}
+				for _, id := range req.Ids {
+					res = append(res, map[string]interface{}{"id": id, "success": true})
+				}
+				json.NewEncoder(w).Encode(res)
+			}))
+			defer srv.Close()
+
+			c := NewClient(srv.URL, "key")
+			c.AlbumAddBatchSize = tt.batchSize
+			res, err := c.AddAssetsToAlbum("album1", ids(tt.assets))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error: %v", err, tt.wantErr)
+			}
+			var apiErr *APIError
+			if err != nil && !errors.As(err, &apiErr) {
+				t.Errorf("error %v doesn't wrap the APIError", err)
+			}
+			if !reflect.DeepEqual(sizes, tt.wantSizes) {
+				t.Errorf("batch sizes %v, want %v", sizes, tt.wantSizes)
+			}
+			if res.Added != tt.wantAdded || res.BatchesTotal != len(tt.wantSizes) || !reflect.DeepEqual(res.Unsent, tt.wantUnsent) {
+				t.Errorf("added %d of %d batches with %d unsent, want %d of %d with %d", res.Added, res.BatchesTotal, len(res.Unsent), tt.wantAdded, len(tt.wantSizes), len(tt.wantUnsent))
+			}
+			if len(res.BatchErrors) != len(tt.wantErrs) {
+				t.Fatalf("batch errors %v, want %q", res.BatchErrors, tt.wantErrs)
+			}
+			for i, want := range tt.wantErrs {
+				if got := res.BatchErrors[i].Error(); !strings.HasPrefix(got, want+": ") {
+					t.Errorf("batch error %q, want it to start with %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddAssetsToAlbumResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"id":"a1","success":true},{"id":"a2","success":false,"error":"duplicate"},`+
+			`{"id":"a3","success":false,"error":"not_found"},{"id":"a4","success":false,"error":"no_permission"}]`)
+	}))
+	defer srv.Close()
+
+	res, err := NewClient(srv.URL, "key").AddAssetsToAlbum("album1", []string{"a1", "a2", "a3", "a4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFailed := map[string]string{"a3": "not_found", "a4": "no_permission"}
+	if res.Added != 1 || res.Duplicates != 1 || !reflect.DeepEqual(res.Failed, wantFailed) {
+		t.Errorf("added %d, duplicates %d, failed %v; want 1, 1, %v", res.Added, res.Duplicates, res.Failed, wantFailed)
+	}
+}
@@ -0,0 +1,20 @@
+package metrics
+
+// AlbumStats are the counters reported after each album sync
+type AlbumStats struct {
+	Added           int
+	Skipped         int
+	Failed          int
+	Restricted      int
+	SkippedTooLarge int // Videos above the size limit, also counted in Skipped
+	BytesDownloaded int64
+	BytesUploaded   int64
+	// Complete is false for a sync cut short or of a partly scraped album
+	Complete bool
+}
+
+// Recorder receives per-album sync results and forwards them to a metrics backend
+type Recorder interface {
+	RecordAlbum(album string, stats AlbumStats) error
+	RecordScrapeError(album string) error
+}
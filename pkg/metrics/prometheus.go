@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prometheus keeps per-album counters in memory and serves them in the
+// Prometheus text format. Register Handler on an HTTP server to expose them.
+type Prometheus struct {
+	mu     sync.Mutex
+	prefix string
+	albums map[string]*albumCounters
+}
+
+type albumCounters struct {
+	stats        AlbumStats
+	scrapeErrors int64
+	lastSuccess  time.Time
+}
+
+// NewPrometheus creates an empty registry. Metric names start with prefix_ if set.
+func NewPrometheus(prefix string) *Prometheus {
+	return &Prometheus{prefix: strings.TrimSuffix(prefix, "_"), albums: make(map[string]*albumCounters)}
+}
+
+// RecordAlbum adds the album's counters and, for a complete sync, marks it as successful now
+func (p *Prometheus) RecordAlbum(album string, stats AlbumStats) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.album(album)
+	c.stats.Added += stats.Added
+	c.stats.Skipped += stats.Skipped
+	c.stats.Failed += stats.Failed
+	c.stats.Restricted += stats.Restricted
+	c.stats.SkippedTooLarge += stats.SkippedTooLarge
+	c.stats.BytesDownloaded += stats.BytesDownloaded
+	c.stats.BytesUploaded += stats.BytesUploaded
+	if stats.Complete {
+		c.lastSuccess = time.Now()
+	}
+	return nil
+}
+
+// RecordScrapeError counts an album that couldn't be scraped
+func (p *Prometheus) RecordScrapeError(album string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.album(album).scrapeErrors++
+	return nil
+}
+
+// album returns the counters for an album, p.mu must be held
+func (p *Prometheus) album(name string) *albumCounters {
+	c, ok := p.albums[name]
+	if !ok {
+		c = &albumCounters{}
+		p.albums[name] = c
+	}
+	return c
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (p *Prometheus) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, p.render())
+	})
+}
+
+func (p *Prometheus) render() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.albums))
+	for name := range p.albums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	families := []struct {
+		name, kind, help string
+		value            func(c *albumCounters) float64
+	}{
+		{"assets_added_total", "counter", "Assets uploaded to Immich.", func(c *albumCounters) float64 { return float64(c.stats.Added) }},
+		{"assets_skipped_total", "counter", "Items skipped, e.g. already in Immich.", func(c *albumCounters) float64 { return float64(c.stats.Skipped) }},
+		{"assets_failed_total", "counter", "Items that failed to sync.", func(c *albumCounters) float64 { return float64(c.stats.Failed) }},
+		{"assets_skipped_too_large_total", "counter", "Videos skipped for exceeding the size limit.", func(c *albumCounters) float64 { return float64(c.stats.SkippedTooLarge) }},
+		{"assets_restricted_total", "counter", "Items Google refused to serve.", func(c *albumCounters) float64 { return float64(c.stats.Restricted) }},
+		{"bytes_downloaded_total", "counter", "Bytes downloaded from Google Photos.", func(c *albumCounters) float64 { return float64(c.stats.BytesDownloaded) }},
+		{"bytes_uploaded_total", "counter", "Bytes uploaded to Immich.", func(c *albumCounters) float64 { return float64(c.stats.BytesUploaded) }},
+		{"scrape_errors_total", "counter", "Album scrapes that failed.", func(c *albumCounters) float64 { return float64(c.scrapeErrors) }},
+		{"last_success_timestamp_seconds", "gauge", "Unix time of the last completed album sync.", func(c *albumCounters) float64 {
+			if c.lastSuccess.IsZero() {
+				return 0
+			}
+			return float64(c.lastSuccess.Unix())
+		}},
+	}
+
+	var b strings.Builder
+	for _, f := range families {
+		name := f.name
+		if p.prefix != "" {
+			name = p.prefix + "_" + name
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind)
+		for _, album := range names {
+			fmt.Fprintf(&b, "%s{album=%q} %g\n", name, escapeLabel(album), f.value(p.albums[album]))
+		}
+	}
+	return b.String()
+}
+
+// escapeLabel prepares a label value for %q, which adds the quotes and escapes
+// backslashes and quotes; Prometheus additionally only knows \n as an escape
+func escapeLabel(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\n' {
+			return ' '
+		}
+		return r
+	}, s)
+}
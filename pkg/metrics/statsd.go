@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// StatsD pushes counters to a StatsD daemon over UDP. Nothing is listened on,
+// so it fits push-only environments.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+}
+
+// NewStatsD connects to a StatsD daemon at addr (host:port). Metric names are
+// prefixed with prefix (e.g. "immich_sync"), if set.
+func NewStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to statsd: %w", err)
+	}
+	return &StatsD{conn: conn, prefix: strings.TrimSuffix(prefix, ".")}, nil
+}
+
+// RecordAlbum sends the album's counters as a single UDP packet.
+// The album name is not part of the metric names to keep cardinality low.
+func (s *StatsD) RecordAlbum(album string, stats AlbumStats) error {
+	lines := []string{
+		s.counter("albums.synced", 1),
+		s.counter("items.added", int64(stats.Added)),
+		s.counter("items.skipped", int64(stats.Skipped)),
+		s.counter("items.failed", int64(stats.Failed)),
+		s.counter("items.restricted", int64(stats.Restricted)),
+		s.counter("items.skipped_too_large", int64(stats.SkippedTooLarge)),
+		s.counter("bytes.downloaded", stats.BytesDownloaded),
+		s.counter("bytes.uploaded", stats.BytesUploaded),
+	}
+	_, err := s.conn.Write([]byte(strings.Join(lines, "\n")))
+	return err
+}
+
+// RecordScrapeError counts an album that couldn't be scraped
+func (s *StatsD) RecordScrapeError(album string) error {
+	_, err := s.conn.Write([]byte(s.counter("albums.scrape_errors", 1)))
+	return err
+}
+
+func (s *StatsD) counter(name string, value int64) string {
+	if s.prefix != "" {
+		name = s.prefix + "." + name
+	}
+	return fmt.Sprintf("%s:%d|c", name, value)
+}
@@ -0,0 +1,61 @@
+package netlimit
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Limiter caps the number of HTTP requests in flight across every transport it wraps.
+// A slot is held from sending the request until the response body is closed.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// New returns a limiter allowing max concurrent requests
+func New(max int) *Limiter {
+	return &Limiter{slots: make(chan struct{}, max)}
+}
+
+// Wrap returns a RoundTripper that shares this limiter's slots. A nil base uses http.DefaultTransport.
+func (l *Limiter) Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, limiter: l}
+}
+
+type transport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.limiter.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	release := func() { <-t.limiter.slots }
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseBody frees the limiter slot once the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,215 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AlbumState holds what we remember about an album between runs
+type AlbumState struct {
+	LastItemCount int       `json:"lastItemCount"`
+	LastSync      time.Time `json:"lastSync"`
+	LastFailed    int       `json:"lastFailed"`
+	LastItemID    string    `json:"lastItemId,omitempty"` // Google ID of the album's last item, for QuickCheck
+	FilterHash    string    `json:"filterHash,omitempty"` // Fingerprint of the item filter settings of the last sync, for QuickCheck
+
+	// Immich album the Google album syncs into, so renaming either side keeps the target
+	ImmichAlbumID string `json:"immichAlbumId,omitempty"`
+
+	// Google photo ID -> Immich asset ID of items synced in earlier runs.
+	// Use Store.Processed / MarkProcessed, copies from Album share this map.
+	Processed map[string]string `json:"processed,omitempty"`
+
+	// Google photo IDs whose assets were deleted from the Immich album (RespectDeletions)
+	Deleted map[string]bool `json:"deleted,omitempty"`
+
+	// Google photo IDs Google refused to serve (HTTP 403) on their last attempt
+	Restricted map[string]bool `json:"restricted,omitempty"`
+}
+
+// Store persists per-album sync state to a JSON file.
+// With an empty path the state is kept in memory only.
+type Store struct {
+	path   string
+	mu     sync.Mutex
+	Albums map[string]*AlbumState `json:"albums"`
+}
+
+// Load reads the state file at path. A missing file yields an empty store.
+// A corrupt file also yields an empty store, together with the parse error so callers can warn.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, Albums: make(map[string]*AlbumState)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, fmt.Errorf("error reading state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		s.Albums = make(map[string]*AlbumState)
+		return s, fmt.Errorf("corrupt state file %s, starting fresh: %w", path, err)
+	}
+	if s.Albums == nil {
+		s.Albums = make(map[string]*AlbumState)
+	}
+	return s, nil
+}
+
+// Album returns a copy of the stored state for an album URL
+func (s *Store) Album(url string) AlbumState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.Albums[url]; ok {
+		return *st
+	}
+	return AlbumState{}
+}
+
+// UpdateAlbum applies fn to the album's state under the store lock
+func (s *Store) UpdateAlbum(url string, fn func(st *AlbumState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.Albums[url]
+	if !ok {
+		st = &AlbumState{}
+		s.Albums[url] = st
+	}
+	fn(st)
+}
+
+// AlbumOwner returns another album URL whose state records the Immich album,
+// "" when no album other than exceptURL does
+func (s *Store) AlbumOwner(immichAlbumID, exceptURL string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for url, st := range s.Albums {
+		if url != exceptURL && st.ImmichAlbumID == immichAlbumID {
+			return url
+		}
+	}
+	return ""
+}
+
+// Processed returns the Immich asset ID recorded for a photo of an album
+func (s *Store) Processed(url, photoID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.Albums[url]
+	if !ok {
+		return "", false
+	}
+	id, ok := st.Processed[photoID]
+	return id, ok
+}
+
+// MarkProcessed records that a photo of an album is synced as the given asset
+func (s *Store) MarkProcessed(url, photoID, assetID string) {
+	s.UpdateAlbum(url, func(st *AlbumState) {
+		if st.Processed == nil {
+			st.Processed = make(map[string]string)
+		}
+		st.Processed[photoID] = assetID
+		delete(st.Restricted, photoID)
+	})
+}
+
+// Restricted reports whether Google refused to serve a photo on its last attempt
+func (s *Store) Restricted(url, photoID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.Albums[url]
+	return ok && st.Restricted[photoID]
+}
+
+// MarkRestricted records that Google refused to serve a photo
+func (s *Store) MarkRestricted(url, photoID string) {
+	s.UpdateAlbum(url, func(st *AlbumState) {
+		if st.Restricted == nil {
+			st.Restricted = make(map[string]bool)
+		}
+		st.Restricted[photoID] = true
+	})
+}
+
+// Deleted reports whether a photo's asset was deleted from the Immich album
+func (s *Store) Deleted(url, photoID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.Albums[url]
+	return ok && st.Deleted[photoID]
+}
+
+// MarkDeleted moves a processed photo to the album's deleted list
+func (s *Store) MarkDeleted(url, photoID string) {
+	s.UpdateAlbum(url, func(st *AlbumState) {
+		if st.Deleted == nil {
+			st.Deleted = make(map[string]bool)
+		}
+		st.Deleted[photoID] = true
+		delete(st.Processed, photoID)
+	})
+}
+
+// PruneProcessed forgets processed, deleted and restricted photos that are no longer in the album
+func (s *Store) PruneProcessed(url string, keep map[string]bool) {
+	s.UpdateAlbum(url, func(st *AlbumState) {
+		for id := range st.Processed {
+			if !keep[id] {
+				delete(st.Processed, id)
+			}
+		}
+		for id := range st.Deleted {
+			if !keep[id] {
+				delete(st.Deleted, id)
+			}
+		}
+		for id := range st.Restricted {
+			if !keep[id] {
+				delete(st.Restricted, id)
+			}
+		}
+	})
+}
+
+// Save writes the state atomically (temp file + rename). No-op without a path.
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	return nil
+}